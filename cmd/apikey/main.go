@@ -0,0 +1,172 @@
+// apikey - управление ключами API из командной строки, в том числе выпуск первого ключа admin.
+// Работает напрямую с БД из того же config.yaml, что и сервер.
+//
+//	apikey create -name ops-bot -role operator
+//	apikey create -name desk-a-bot -role operator -tags desk-a -clients 7,9
+//	apikey list
+//	apikey revoke -id 3
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/CyrilSbrodov/syncService/cmd/loggers"
+	"github.com/CyrilSbrodov/syncService/internal/auth"
+	"github.com/CyrilSbrodov/syncService/internal/config"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/CyrilSbrodov/syncService/internal/storage/postgres"
+	"github.com/CyrilSbrodov/syncService/internal/validation"
+)
+
+const usage = `usage:
+  apikey create -name NAME -role viewer|operator|admin [-clients ID,...] [-tags TAG,...]
+  apikey list
+  apikey revoke -id ID`
+
+func main() {
+	if len(os.Args) < 2 {
+		fail(usage)
+	}
+	cfg := config.NewConfig()
+	store, err := postgres.NewPGStore(cfg, loggers.SetupLogger(cfg.Env))
+	if err != nil {
+		fail(err)
+	}
+	ctx, cancel := context.WithTimeout(model.WithOrigin(context.Background(), model.Origin{Actor: "apikey-cli"}), 30*time.Second)
+	defer cancel()
+
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "create":
+		err = create(ctx, store, args)
+	case "list":
+		err = list(ctx, store)
+	case "revoke":
+		err = revoke(ctx, store, args)
+	default:
+		fail(usage)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+// create - выпуск ключа, сам ключ печатается один раз в stdout
+func create(ctx context.Context, store *postgres.PGStore, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	name := fs.String("name", "", "key owner, written to logs and audit")
+	role := fs.String("role", string(model.RoleViewer), "viewer, operator or admin")
+	clients := fs.String("clients", "", "comma-separated client ids the key is limited to")
+	tags := fs.String("tags", "", "comma-separated client tags the key is limited to")
+	fs.Parse(args)
+
+	if *name == "" {
+		return fmt.Errorf("-name is required")
+	}
+	if !model.Role(*role).Valid() {
+		return fmt.Errorf("unknown role %q", *role)
+	}
+	scope, err := parseScope(*clients, *tags)
+	if err != nil {
+		return err
+	}
+	key, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		return err
+	}
+	k := model.APIKey{Name: *name, Prefix: prefix, Role: model.Role(*role), Scope: scope}
+	if err := store.AddAPIKey(ctx, &k, hash); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "created key %d %q with role %s, it is shown only once\n", k.ID, k.Name, k.Role)
+	fmt.Println(key)
+	return nil
+}
+
+// list - все ключи без самих ключей
+func list(ctx context.Context, store *postgres.PGStore) error {
+	keys, err := store.GetAPIKeys(ctx)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tROLE\tSCOPE\tCREATED\tLAST USED\tREVOKED")
+	for _, k := range keys {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", k.ID, k.Name, k.Prefix, k.Role, formatScope(k.Scope),
+			k.CreatedAt.Format(time.RFC3339), formatTime(k.LastUsedAt), formatTime(k.RevokedAt))
+	}
+	return tw.Flush()
+}
+
+// revoke - отзыв ключа по id
+func revoke(ctx context.Context, store *postgres.PGStore, args []string) error {
+	fs := flag.NewFlagSet("revoke", flag.ExitOnError)
+	id := fs.Int64("id", 0, "key id from list")
+	fs.Parse(args)
+
+	if *id <= 0 {
+		return fmt.Errorf("-id is required")
+	}
+	if err := store.RevokeAPIKey(ctx, *id); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "revoked key %d\n", *id)
+	return nil
+}
+
+// parseScope - область видимости ключа из списков через запятую
+func parseScope(clients, tags string) (model.Scope, error) {
+	var scope model.Scope
+	for _, s := range splitList(clients) {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return scope, fmt.Errorf("-clients: %q is not a client id", s)
+		}
+		scope.ClientIDs = append(scope.ClientIDs, id)
+	}
+	scope.Tags = splitList(tags)
+	return scope, validation.Scope(&scope)
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func formatScope(s model.Scope) string {
+	if s.Unrestricted() {
+		return "all"
+	}
+	var parts []string
+	for _, id := range s.ClientIDs {
+		parts = append(parts, "client:"+strconv.FormatInt(id, 10))
+	}
+	for _, t := range s.Tags {
+		parts = append(parts, "tag:"+t)
+	}
+	return strings.Join(parts, ",")
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func fail(v any) {
+	fmt.Fprintln(os.Stderr, v)
+	os.Exit(1)
+}
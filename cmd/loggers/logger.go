@@ -1,24 +1,188 @@
 package loggers
 
 import (
+	"context"
+	"fmt"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"io"
 	"log/slog"
 	"os"
 )
 
+// Logger - логгер сервиса. Уровень общий для логгера и всех производных от него через With
+// и меняется во время работы через SetLevel. Нулевой Logger и nil пишут в slog.Default().
 type Logger struct {
 	*slog.Logger
+	level *slog.LevelVar
+	file  io.Closer
 }
 
-// SetupLogger создание нового логгера.
+// Options - настройки логгера
+type Options struct {
+	// Env - окружение local, dev или prod, задаёт формат и уровень по умолчанию
+	Env string
+	// Level - уровень (debug, info, warn, error), пусто - уровень окружения
+	Level string
+	File  FileOptions
+}
+
+// FileOptions - запись лога в файл с ротацией в дополнение к stdout, пустой Path - без файла
+type FileOptions struct {
+	Path       string
+	MaxSizeMB  int
+	MaxBackups int
+	MaxAgeDays int
+	Compress   bool
+}
+
+// SetupLogger создание нового логгера. Неизвестное окружение получает настройки prod.
 func SetupLogger(env string) *Logger {
-	var logger *slog.Logger
-	switch env {
+	l, _ := NewLogger(Options{Env: env})
+	return l
+}
+
+// NewLogger - логгер по настройкам. Ошибка - неизвестный уровень.
+// Неизвестное окружение не ошибка: логгер получает настройки prod и пишет об этом предупреждение.
+func NewLogger(opts Options) (*Logger, error) {
+	level := new(slog.LevelVar)
+	useJSON, known := true, true
+	switch opts.Env {
 	case "local":
-		logger = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		useJSON = false
+		level.Set(slog.LevelDebug)
 	case "dev":
-		logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		level.Set(slog.LevelDebug)
 	case "prod":
-		logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		level.Set(slog.LevelInfo)
+	default:
+		known = false
+		level.Set(slog.LevelInfo)
+	}
+	if opts.Level != "" {
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(opts.Level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q", opts.Level)
+		}
+		level.Set(l)
+	}
+
+	logger := &Logger{level: level}
+	var out io.Writer = os.Stdout
+	if opts.File.Path != "" {
+		file := &lumberjack.Logger{
+			Filename:   opts.File.Path,
+			MaxSize:    opts.File.MaxSizeMB,
+			MaxBackups: opts.File.MaxBackups,
+			MaxAge:     opts.File.MaxAgeDays,
+			Compress:   opts.File.Compress,
+		}
+		out = io.MultiWriter(os.Stdout, file)
+		logger.file = file
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	if useJSON {
+		logger.Logger = slog.New(slog.NewJSONHandler(out, handlerOpts))
+	} else {
+		logger.Logger = slog.New(slog.NewTextHandler(out, handlerOpts))
+	}
+	if !known {
+		logger.Warn("unknown env, using prod logger settings", slog.String("env", opts.Env))
+	}
+	return logger, nil
+}
+
+// Level - текущий уровень логгера
+func (l *Logger) Level() slog.Level {
+	if l == nil || l.level == nil {
+		return slog.LevelInfo
+	}
+	return l.level.Level()
+}
+
+// SetLevel - смена уровня логгера и всех производных от него логгеров.
+// Логгер, созданный не через NewLogger, уровень не меняет.
+func (l *Logger) SetLevel(level slog.Level) {
+	if l != nil && l.level != nil {
+		l.level.Set(level)
+	}
+}
+
+// Close - закрытие файла лога, если он есть
+func (l *Logger) Close() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// loggerKey - ключ логгера запроса в контексте
+type loggerKey struct{}
+
+// WithContext - контекст с логгером запроса
+func WithContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext - логгер запроса из ctx с его id и атрибутами, без него - fallback
+func FromContext(ctx context.Context, fallback *Logger) *Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return l
+	}
+	return fallback
+}
+
+// With - логгер с дополнительными атрибутами и тем же уровнем
+func (l *Logger) With(args ...any) *Logger {
+	if l == nil {
+		return &Logger{Logger: slog.Default().With(args...)}
+	}
+	return &Logger{Logger: l.slog().With(args...), level: l.level, file: l.file}
+}
+
+// slog - логгер slog для записи, у нулевого Logger - slog.Default()
+func (l *Logger) slog() *slog.Logger {
+	if l == nil || l.Logger == nil {
+		return slog.Default()
 	}
-	return &Logger{Logger: logger}
+	return l.Logger
+}
+
+// Debug - запись на уровне debug
+func (l *Logger) Debug(msg string, args ...any) {
+	l.slog().Debug(msg, args...)
+}
+
+// Info - запись на уровне info
+func (l *Logger) Info(msg string, args ...any) {
+	l.slog().Info(msg, args...)
+}
+
+// Warn - запись на уровне warn
+func (l *Logger) Warn(msg string, args ...any) {
+	l.slog().Warn(msg, args...)
+}
+
+// Error - запись на уровне error
+func (l *Logger) Error(msg string, args ...any) {
+	l.slog().Error(msg, args...)
+}
+
+// LogAttrs - запись с атрибутами на заданном уровне
+func (l *Logger) LogAttrs(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	l.slog().LogAttrs(ctx, level, msg, attrs...)
+}
+
+// Err - ошибка в атрибуте error
+func Err(err error) slog.Attr {
+	return slog.Any("error", err)
+}
+
+// ClientID - id клиента в атрибуте client
+func ClientID(id int64) slog.Attr {
+	return slog.Int64("client", id)
+}
+
+// Algorithm - тип алгоритма в атрибуте algorithm
+func Algorithm(t string) slog.Attr {
+	return slog.String("algorithm", t)
 }
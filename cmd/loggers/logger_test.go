@@ -0,0 +1,112 @@
+package loggers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          Options
+		expectedLevel slog.Level
+		expectErr     bool
+	}{
+		{name: "local", opts: Options{Env: "local"}, expectedLevel: slog.LevelDebug},
+		{name: "prod", opts: Options{Env: "prod"}, expectedLevel: slog.LevelInfo},
+		{name: "unknown env falls back to prod", opts: Options{Env: "staging"}, expectedLevel: slog.LevelInfo},
+		{name: "empty env falls back to prod", opts: Options{}, expectedLevel: slog.LevelInfo},
+		{name: "level overrides env", opts: Options{Env: "local", Level: "warn"}, expectedLevel: slog.LevelWarn},
+		{name: "invalid level", opts: Options{Env: "prod", Level: "verbose"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := NewLogger(tt.opts)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, l.Logger)
+			assert.Equal(t, tt.expectedLevel, l.Level())
+		})
+	}
+}
+
+func TestSetupLogger_UnknownEnv(t *testing.T) {
+	l := SetupLogger("qa")
+	require.NotNil(t, l.Logger)
+	assert.NotPanics(t, func() { l.Info("works") })
+}
+
+func TestLogger_SetLevel(t *testing.T) {
+	var buf bytes.Buffer
+	level := new(slog.LevelVar)
+	l := &Logger{Logger: slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: level})), level: level}
+	child := l.With(slog.String("request_id", "abc"))
+
+	child.Debug("hidden")
+	l.SetLevel(slog.LevelDebug)
+	child.Debug("shown")
+
+	assert.Equal(t, slog.LevelDebug, child.Level())
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "shown")
+}
+
+func TestLogger_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "syncservice.log")
+	l, err := NewLogger(Options{Env: "prod", File: FileOptions{Path: path, MaxSizeMB: 1}})
+	require.NoError(t, err)
+
+	l.Info("to file")
+	require.NoError(t, l.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"msg":"to file"`)
+}
+
+func TestAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	l := &Logger{Logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+
+	l.Error("failed", Err(errors.New("boom")), ClientID(7), Algorithm("hft"))
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "boom", line["error"])
+	assert.Equal(t, float64(7), line["client"])
+	assert.Equal(t, "hft", line["algorithm"])
+	assert.NotContains(t, buf.String(), "!BADKEY")
+}
+
+func TestLogger_Zero(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	var l *Logger
+	for _, l := range []*Logger{{}, l} {
+		assert.NotPanics(t, func() {
+			l.Info("zero", ClientID(7))
+			l.With(slog.String("request_id", "abc")).Error("child", Err(errors.New("boom")))
+			l.SetLevel(slog.LevelDebug)
+			assert.Equal(t, slog.LevelInfo, l.Level())
+			assert.NoError(t, l.Close())
+		})
+	}
+
+	assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte(`"msg":"zero"`)))
+	assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte(`"request_id":"abc"`)))
+}
@@ -1,6 +1,10 @@
 package main
 
-import "github.com/CyrilSbrodov/syncService/internal/app"
+import (
+	"github.com/CyrilSbrodov/syncService/internal/app"
+	// часовые пояса расписаний торговых сессий не зависят от tzdata в образе
+	_ "time/tzdata"
+)
 
 func main() {
 	srv := app.NewServerApp()
@@ -4,12 +4,18 @@ import (
 	"context"
 	"errors"
 	"github.com/CyrilSbrodov/syncService/cmd/loggers"
+	"github.com/CyrilSbrodov/syncService/internal/auth"
 	"github.com/CyrilSbrodov/syncService/internal/config"
 	"github.com/CyrilSbrodov/syncService/internal/deployer/kubernetes"
 	"github.com/CyrilSbrodov/syncService/internal/handlers"
+	"github.com/CyrilSbrodov/syncService/internal/metrics"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/CyrilSbrodov/syncService/internal/scheduler"
 	"github.com/CyrilSbrodov/syncService/internal/storage/postgres"
 	"github.com/CyrilSbrodov/syncService/internal/syncer"
+	"github.com/CyrilSbrodov/syncService/internal/tracing"
 	"github.com/gorilla/mux"
+	"log"
 	"log/slog"
 	"net/http"
 	"os"
@@ -29,7 +35,20 @@ type ServerApp struct {
 func NewServerApp() *ServerApp {
 	cfg := config.NewConfig()
 	router := mux.NewRouter()
-	logger := loggers.SetupLogger(cfg.Env)
+	logger, err := loggers.NewLogger(loggers.Options{
+		Env:   cfg.Env,
+		Level: cfg.Log.Level,
+		File: loggers.FileOptions{
+			Path:       cfg.Log.File.Path,
+			MaxSizeMB:  cfg.Log.File.MaxSizeMB,
+			MaxBackups: cfg.Log.File.MaxBackups,
+			MaxAgeDays: cfg.Log.File.MaxAgeDays,
+			Compress:   cfg.Log.File.Compress,
+		},
+	})
+	if err != nil {
+		log.Fatalf("cannot setup logger: %s", err)
+	}
 
 	return &ServerApp{
 		router: router,
@@ -40,26 +59,67 @@ func NewServerApp() *ServerApp {
 
 // Run - функция запуска сервера с gracefully shutdown
 func (a *ServerApp) Run() {
-	db, err := postgres.NewPGStore(&a.cfg, a.logger)
+	shutdownTracing, err := tracing.Setup(context.Background(), &a.cfg)
 	if err != nil {
-		a.logger.Error("failed to start pg store", err)
+		a.logger.Error("failed to start tracing", loggers.Err(err))
 		return
 	}
-	h := handlers.NewHandler(&a.cfg, a.logger, db)
 
-	h.Register(a.router)
+	db, err := postgres.NewPGStore(&a.cfg, a.logger)
+	if err != nil {
+		a.logger.Error("failed to start pg store", loggers.Err(err))
+		return
+	}
 
 	k8s, err := kubernetes.NewKubernetesDeployer()
 	if err != nil {
-		a.logger.Error("failed to start k8s", err)
+		a.logger.Error("failed to start k8s", loggers.Err(err))
 		return
 	}
-	sync := syncer.NewSyncer(k8s, db, a.logger, a.cfg)
+	// вызовы хранилища и API кластера пишутся в метрики и трассы
+	store := metrics.NewStorage(tracing.NewStorage(db))
+	sync := syncer.NewSyncer(metrics.NewDeployer(tracing.NewDeployer(k8s)), store, a.logger, a.cfg)
 	go sync.Start()
 
+	sched := scheduler.NewScheduler(store, sync, a.logger, a.cfg)
+	go sched.Start()
+
+	var tokens handlers.TokenVerifier
+	if a.cfg.Auth.JWKSFile != "" {
+		v, err := auth.NewJWTVerifier(a.cfg.Auth.JWKSFile, a.cfg.Auth.Issuer, a.cfg.Auth.Audience, a.cfg.Auth.RoleClaim)
+		if err != nil {
+			a.logger.Error("failed to load jwks", loggers.Err(err))
+			return
+		}
+		tokens = v
+	}
+	if !a.cfg.Auth.Enabled {
+		a.logger.Warn("authentication is disabled, every request runs as admin")
+	}
+
+	approvals, err := model.NewApprovalPolicy(a.cfg.Approval.Algorithms, a.cfg.Approval.ApproverRole, a.cfg.Approval.TTL)
+	if err != nil {
+		a.logger.Error("invalid approval policy", loggers.Err(err))
+		return
+	}
+
+	h := handlers.NewHandler(&a.cfg, a.logger, store, sync, tokens, approvals)
+	h.AddCheck("database", db.Ping)
+	h.AddCheck("schema", db.CheckSchema)
+	h.AddCheck("kubernetes", k8s.Ping)
+
+	h.Register(a.router)
+
+	// /metrics и пробы отдаются без аутентификации API, остальные пути - роутеру
+	root := http.NewServeMux()
+	root.Handle("/metrics", metrics.Handler())
+	root.Handle("/healthz", h.Healthz())
+	root.Handle("/readyz", h.Readyz())
+	root.Handle("/", a.router)
+
 	srv := &http.Server{
 		Addr:         a.cfg.Listener.Addr,
-		Handler:      a.router,
+		Handler:      root,
 		ReadTimeout:  a.cfg.Listener.Timeout,
 		WriteTimeout: a.cfg.Listener.Timeout,
 		IdleTimeout:  a.cfg.Listener.IdleTimeout,
@@ -67,7 +127,7 @@ func (a *ServerApp) Run() {
 
 	go func() {
 		if err = srv.ListenAndServe(); err != nil && !errors.Is(http.ErrServerClosed, err) {
-			a.logger.Error("server not started", err, "server")
+			a.logger.Error("server not started", loggers.Err(err))
 		}
 	}()
 	a.logger.Info("server starting", slog.String("server", a.cfg.Listener.Addr))
@@ -77,12 +137,21 @@ func (a *ServerApp) Run() {
 
 	<-c
 
+	// пока балансировщик не увидел неготовность, реплика продолжает принимать запросы
+	h.Drain()
+	a.logger.Info("draining", slog.Duration("delay", a.cfg.Listener.DrainDelay))
+	time.Sleep(a.cfg.Listener.DrainDelay)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err = srv.Shutdown(ctx); err != nil {
-		a.logger.Error("server", "failed to shutting down gracefully", err)
+		a.logger.Error("failed to shut down gracefully", slog.String("server", a.cfg.Listener.Addr), loggers.Err(err))
 		return
 	}
+	if err = shutdownTracing(ctx); err != nil {
+		a.logger.Error("failed to flush traces", loggers.Err(err))
+	}
 	a.logger.Info("shutting down", slog.String("server", a.cfg.Listener.Addr))
+	a.logger.Close()
 	os.Exit(0)
 }
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	// KeyPrefix - начало ключей API, по нему ключ отличается от JWT в заголовке Authorization
+	KeyPrefix = "ssk_"
+	// displayPrefix - сколько первых символов ключа хранится для опознания в списках
+	displayPrefix = len(KeyPrefix) + 8
+)
+
+// NewAPIKey - новый случайный ключ API. Возвращает сам ключ, его префикс для списков и хеш для БД.
+func NewAPIKey() (key, prefix, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	key = KeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, key[:displayPrefix], HashAPIKey(key), nil
+}
+
+// HashAPIKey - хеш ключа для хранения и поиска в БД.
+// Ключи случайные и длинные, поэтому медленная хеш-функция не нужна.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey - похожа ли строка на ключ API
+func IsAPIKey(s string) bool {
+	return strings.HasPrefix(s, KeyPrefix)
+}
@@ -0,0 +1,25 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	key, prefix, hash, err := NewAPIKey()
+	require.NoError(t, err)
+
+	assert.True(t, IsAPIKey(key))
+	assert.True(t, strings.HasPrefix(key, prefix))
+	assert.Len(t, prefix, displayPrefix)
+	assert.Equal(t, HashAPIKey(key), hash)
+	assert.Len(t, hash, 64)
+	assert.NotContains(t, hash, key)
+
+	other, _, _, err := NewAPIKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+}
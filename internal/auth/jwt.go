@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// leeway - допустимое расхождение часов с издателем токенов
+	leeway = 30 * time.Second
+	// clientIDsClaim, clientTagsClaim - claims области видимости, без них доступны все клиенты
+	clientIDsClaim  = "client_ids"
+	clientTagsClaim = "client_tags"
+)
+
+// signingMethods - алгоритмы подписи, которые принимает сервис. Симметричные и none запрещены.
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// JWTVerifier - проверка JWT по публичным ключам из локального JWKS файла
+type JWTVerifier struct {
+	keys      map[string]any
+	parser    *jwt.Parser
+	roleClaim string
+}
+
+// jwk - публичный ключ из JWKS (RFC 7517), поддерживаются RSA и EC
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// NewJWTVerifier - конструктор проверки JWT. Пустые issuer и audience не проверяются,
+// роль берётся из claim roleClaim (строка или список, выбирается старшая роль),
+// область видимости - из claims client_ids и client_tags.
+func NewJWTVerifier(jwksFile, issuer, audience, roleClaim string) (*JWTVerifier, error) {
+	data, err := os.ReadFile(jwksFile)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("jwks %s: %w", jwksFile, err)
+	}
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+	return &JWTVerifier{keys: keys, parser: jwt.NewParser(opts...), roleClaim: roleClaim}, nil
+}
+
+// Verify - проверка подписи и claims токена, вызывающий - subject токена с префиксом jwt:
+func (v *JWTVerifier) Verify(token string) (*model.Principal, error) {
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.key); err != nil {
+		return nil, err
+	}
+	sub, err := claims.GetSubject()
+	if err != nil || sub == "" {
+		return nil, errors.New("token has no subject")
+	}
+	role := roleFromClaim(claims[v.roleClaim])
+	if role == "" {
+		return nil, fmt.Errorf("token has no known role in claim %q", v.roleClaim)
+	}
+	scope, err := scopeFromClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Principal{Subject: model.JWTSubject(sub), Role: role, Method: model.AuthJWT, Scope: scope}, nil
+}
+
+// key - публичный ключ по kid из заголовка токена. Без kid подходит только единственный ключ JWKS.
+func (v *JWTVerifier) key(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys {
+			return k, nil
+		}
+	}
+	k, ok := v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return k, nil
+}
+
+// roleFromClaim - старшая известная роль из claim
+func roleFromClaim(claim any) model.Role {
+	var names []string
+	switch c := claim.(type) {
+	case string:
+		names = []string{c}
+	case []any:
+		for _, n := range c {
+			if s, ok := n.(string); ok {
+				names = append(names, s)
+			}
+		}
+	}
+	var best model.Role
+	for _, n := range names {
+		if r := model.Role(n); r.Valid() && (best == "" || r.Allows(best)) {
+			best = r
+		}
+	}
+	return best
+}
+
+// scopeFromClaims - область видимости из client_ids (числа) и client_tags (строки)
+func scopeFromClaims(claims jwt.MapClaims) (model.Scope, error) {
+	var scope model.Scope
+	if v, ok := claims[clientIDsClaim]; ok {
+		ids, ok := v.([]any)
+		if !ok {
+			return scope, fmt.Errorf("claim %q must be an array", clientIDsClaim)
+		}
+		for _, id := range ids {
+			n, ok := id.(float64)
+			if !ok || n <= 0 || n != float64(int64(n)) {
+				return scope, fmt.Errorf("claim %q must contain positive integers", clientIDsClaim)
+			}
+			scope.ClientIDs = append(scope.ClientIDs, int64(n))
+		}
+	}
+	if v, ok := claims[clientTagsClaim]; ok {
+		tags, ok := v.([]any)
+		if !ok {
+			return scope, fmt.Errorf("claim %q must be an array", clientTagsClaim)
+		}
+		for _, t := range tags {
+			tag, ok := t.(string)
+			if !ok || tag == "" {
+				return scope, fmt.Errorf("claim %q must contain non-empty strings", clientTagsClaim)
+			}
+			scope.Tags = append(scope.Tags, tag)
+		}
+	}
+	return scope, nil
+}
+
+// parseJWKS - публичные ключи подписи из JWKS по kid
+func parseJWKS(data []byte) (map[string]any, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = pub
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeJWKS - JWKS файл с публичным ключом RSA
+func writeJWKS(t *testing.T, kid string, pub *rsa.PublicKey) string {
+	t.Helper()
+	set := map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}}
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestJWTVerifier_Verify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	v, err := NewJWTVerifier(writeJWKS(t, "k1", &key.PublicKey), "https://idp", "syncservice", "role")
+	require.NoError(t, err)
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"sub":  "alice",
+			"iss":  "https://idp",
+			"aud":  "syncservice",
+			"exp":  time.Now().Add(time.Hour).Unix(),
+			"role": "operator",
+		}
+	}
+	sign := func(claims jwt.MapClaims, kid string) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		tok.Header["kid"] = kid
+		s, err := tok.SignedString(key)
+		require.NoError(t, err)
+		return s
+	}
+
+	tests := []struct {
+		name     string
+		token    func() string
+		expected *model.Principal
+	}{
+		{
+			name:     "valid",
+			token:    func() string { return sign(valid(), "k1") },
+			expected: &model.Principal{Subject: "jwt:alice", Role: model.RoleOperator, Method: model.AuthJWT},
+		},
+		{
+			name: "highest role from list",
+			token: func() string {
+				c := valid()
+				c["role"] = []any{"viewer", "admin", "unknown"}
+				return sign(c, "k1")
+			},
+			expected: &model.Principal{Subject: "jwt:alice", Role: model.RoleAdmin, Method: model.AuthJWT},
+		},
+		{
+			name: "scope",
+			token: func() string {
+				c := valid()
+				c["client_ids"] = []any{1, 2}
+				c["client_tags"] = []any{"desk-a"}
+				return sign(c, "k1")
+			},
+			expected: &model.Principal{Subject: "jwt:alice", Role: model.RoleOperator, Method: model.AuthJWT,
+				Scope: model.Scope{ClientIDs: []int64{1, 2}, Tags: []string{"desk-a"}}},
+		},
+		{
+			name: "bad scope",
+			token: func() string {
+				c := valid()
+				c["client_ids"] = "1,2"
+				return sign(c, "k1")
+			},
+		},
+		{
+			name: "expired",
+			token: func() string {
+				c := valid()
+				c["exp"] = time.Now().Add(-time.Hour).Unix()
+				return sign(c, "k1")
+			},
+		},
+		{
+			name: "no exp",
+			token: func() string {
+				c := valid()
+				delete(c, "exp")
+				return sign(c, "k1")
+			},
+		},
+		{
+			name: "wrong issuer",
+			token: func() string {
+				c := valid()
+				c["iss"] = "https://other"
+				return sign(c, "k1")
+			},
+		},
+		{
+			name: "wrong audience",
+			token: func() string {
+				c := valid()
+				c["aud"] = "other"
+				return sign(c, "k1")
+			},
+		},
+		{
+			name:  "unknown kid",
+			token: func() string { return sign(valid(), "k2") },
+		},
+		{
+			name: "no role",
+			token: func() string {
+				c := valid()
+				c["role"] = "superuser"
+				return sign(c, "k1")
+			},
+		},
+		{
+			name: "hmac rejected",
+			token: func() string {
+				tok := jwt.NewWithClaims(jwt.SigningMethodHS256, valid())
+				tok.Header["kid"] = "k1"
+				s, err := tok.SignedString([]byte("secret"))
+				require.NoError(t, err)
+				return s
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := v.Verify(tt.token())
+			if tt.expected == nil {
+				assert.Error(t, err)
+				assert.Nil(t, p)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, p)
+		})
+	}
+}
+
+func TestNewJWTVerifier_NoKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"keys":[]}`), 0o600))
+
+	_, err := NewJWTVerifier(path, "", "", "role")
+	assert.Error(t, err)
+}
@@ -1,8 +1,15 @@
 package deployer
 
-// Deployer - интерфейс взаимодействия с кубернетисом
+import (
+	"context"
+
+	"github.com/CyrilSbrodov/syncService/internal/model"
+)
+
+// Deployer - интерфейс взаимодействия с кубернетисом. ctx ограничивает вызов API кластера и несёт трассировку.
+// Pod создаётся по спецификации клиента: образ, версия и ресурсы берутся из pod.
 type Deployer interface {
-	CreatePod(name string) error
-	DeletePod(name string) error
-	GetPodList() ([]string, error)
+	CreatePod(ctx context.Context, pod model.Pod) error
+	DeletePod(ctx context.Context, name string) error
+	GetPodList(ctx context.Context) ([]string, error)
 }
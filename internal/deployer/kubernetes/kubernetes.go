@@ -4,9 +4,15 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"strconv"
+	"time"
 
+	"github.com/CyrilSbrodov/syncService/internal/model"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
@@ -39,41 +45,97 @@ func NewKubernetesDeployer() (*KubernetesDeployer, error) {
 	return &KubernetesDeployer{clientset: clientset}, nil
 }
 
-// CreatePod - создание нового pod'a с проверкой на уже существующий с таким же именем
-func (d *KubernetesDeployer) CreatePod(name string) error {
-	_, err := d.clientset.CoreV1().Pods("default").Get(context.Background(), name, metav1.GetOptions{})
+// defaultImage - образ pod'а клиента без заданного образа
+const defaultImage = "algorithm-image"
+
+// terminatingTimeout - сколько CreatePod ждёт удаления pod'а с тем же именем, который ещё завершается
+const terminatingTimeout = 2 * time.Minute
+
+// CreatePod - создание нового pod'a по спецификации клиента с проверкой на уже существующий с таким же именем.
+// Если pod с тем же именем ещё завершается после удаления, новый создаётся, когда старый исчезнет.
+func (d *KubernetesDeployer) CreatePod(ctx context.Context, pod model.Pod) error {
+	existing, err := d.clientset.CoreV1().Pods("default").Get(ctx, pod.Name, metav1.GetOptions{})
+	if err == nil {
+		if existing.DeletionTimestamp == nil {
+			return nil
+		}
+		if err := d.waitDeleted(ctx, pod.Name); err != nil {
+			return fmt.Errorf("pod %s is terminating: %w", pod.Name, err)
+		}
+	}
+
+	resources, err := podResources(pod)
 	if err != nil {
-		pod := &corev1.Pod{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: name,
+		return err
+	}
+	image := pod.Image
+	if image == "" {
+		image = defaultImage
+	}
+	p := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: pod.Name,
+			Labels: map[string]string{
+				"client":  strconv.FormatInt(pod.ClientID, 10),
+				"version": strconv.Itoa(pod.Version),
 			},
-			Spec: corev1.PodSpec{
-				Containers: []corev1.Container{
-					{
-						Name:  "algorithm-container",
-						Image: "algorithm-image",
-					},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:      "algorithm-container",
+					Image:     image,
+					Resources: resources,
 				},
 			},
+		},
+	}
+	_, err = d.clientset.CoreV1().Pods("default").Create(ctx, p, metav1.CreateOptions{})
+	return err
+}
+
+// podResources - запросы и лимиты контейнера из ресурсов клиента, пустой ресурс не задаётся
+func podResources(pod model.Pod) (corev1.ResourceRequirements, error) {
+	list := corev1.ResourceList{}
+	for name, value := range map[corev1.ResourceName]string{corev1.ResourceCPU: pod.CPU, corev1.ResourceMemory: pod.Memory} {
+		if value == "" {
+			continue
 		}
-		_, err := d.clientset.CoreV1().Pods("default").Create(context.Background(), pod, metav1.CreateOptions{})
-		return err
+		q, err := resource.ParseQuantity(value)
+		if err != nil {
+			return corev1.ResourceRequirements{}, fmt.Errorf("invalid %s %q of pod %s: %w", name, value, pod.Name, err)
+		}
+		list[name] = q
 	}
-	return nil
+	if len(list) == 0 {
+		return corev1.ResourceRequirements{}, nil
+	}
+	return corev1.ResourceRequirements{Requests: list, Limits: list}, nil
+}
+
+// waitDeleted - ожидание, пока завершающийся pod исчезнет из кластера
+func (d *KubernetesDeployer) waitDeleted(ctx context.Context, name string) error {
+	return wait.PollUntilContextTimeout(ctx, time.Second, terminatingTimeout, true, func(ctx context.Context) (bool, error) {
+		_, err := d.clientset.CoreV1().Pods("default").Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
 }
 
 // DeletePod - удаление существующего pod'a, если такого нет, то выходит из функции
-func (d *KubernetesDeployer) DeletePod(name string) error {
-	_, err := d.clientset.CoreV1().Pods("default").Get(context.Background(), name, metav1.GetOptions{})
+func (d *KubernetesDeployer) DeletePod(ctx context.Context, name string) error {
+	_, err := d.clientset.CoreV1().Pods("default").Get(ctx, name, metav1.GetOptions{})
 	if err == nil {
-		return d.clientset.CoreV1().Pods("default").Delete(context.Background(), name, metav1.DeleteOptions{})
+		return d.clientset.CoreV1().Pods("default").Delete(ctx, name, metav1.DeleteOptions{})
 	}
 	return nil
 }
 
 // GetPodList - функция получения всех pod
-func (d *KubernetesDeployer) GetPodList() ([]string, error) {
-	pods, err := d.clientset.CoreV1().Pods("default").List(context.Background(), metav1.ListOptions{})
+func (d *KubernetesDeployer) GetPodList(ctx context.Context) ([]string, error) {
+	pods, err := d.clientset.CoreV1().Pods("default").List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -84,3 +146,8 @@ func (d *KubernetesDeployer) GetPodList() ([]string, error) {
 	}
 	return podNames, nil
 }
+
+// Ping - проверка доступности API кластера запросом версии сервера
+func (d *KubernetesDeployer) Ping(ctx context.Context) error {
+	return d.clientset.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Error()
+}
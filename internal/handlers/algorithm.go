@@ -1,24 +1,204 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/gorilla/mux"
 	"net/http"
 )
 
-// UpdateAlgorithmStatus - ручка обновления статусов алгоритмов
+// UpdateAlgorithmStatus - ручка обновления статусов алгоритмов и внеплановой синхронизации.
+// Включение алгоритма из политики подтверждения создаёт запрос на подтверждение всего изменения (202).
 func (h *Handler) UpdateAlgorithmStatus() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var as model.AlgorithmStatus
-		if err := json.NewDecoder(r.Body).Decode(&as); err != nil {
-			http.Error(w, "invalid request body", http.StatusBadRequest)
+		if err := decodeBody(r, &as); err != nil {
+			writeError(w, r, err)
 			return
 		}
+		patch := model.AlgorithmPatch{VWAP: &as.VWAP, TWAP: &as.TWAP, HFT: &as.HFT}
 		if err := h.storage.UpdateAlgorithmStatus(r.Context(), &as); err != nil {
-			http.Error(w, "internal server error", http.StatusInternalServerError)
+			if errors.Is(err, model.ErrorApprovalRequired) {
+				h.requestApproval(w, r, as.ClientID, &patch)
+				return
+			}
+			writeError(w, r, err)
 			return
 		}
+		h.triggerSync(r.Context())
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(as)
 	}
 }
+
+// PatchAlgorithmStatus - ручка изменения отдельных флагов алгоритмов клиента (JSON Merge Patch), например {"hft": true},
+// и внеплановой синхронизации. Включение алгоритма из политики подтверждения создаёт запрос на подтверждение (202).
+func (h *Handler) PatchAlgorithmStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeError(w, r, model.ErrorInvalidID)
+			return
+		}
+		var patch model.AlgorithmPatch
+		if err := decodeMergePatch(r, &patch); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		as, err := h.storage.PatchAlgorithmStatus(r.Context(), id, &patch)
+		if errors.Is(err, model.ErrorApprovalRequired) {
+			h.requestApproval(w, r, id, &patch)
+			return
+		}
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		h.triggerSync(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(as)
+	}
+}
+
+// GetClientAlgorithms - ручка получения желаемого и наблюдаемого состояния алгоритмов клиента
+func (h *Handler) GetClientAlgorithms() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeError(w, r, model.ErrorInvalidID)
+			return
+		}
+		as, err := h.storage.GetClientAlgorithms(r.Context(), id)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(h.algorithmState(r.Context(), as))
+	}
+}
+
+// EnableAlgorithm - ручка включения одного алгоритма клиента
+func (h *Handler) EnableAlgorithm() http.HandlerFunc {
+	return h.setAlgorithm(true)
+}
+
+// DisableAlgorithm - ручка выключения одного алгоритма клиента
+func (h *Handler) DisableAlgorithm() http.HandlerFunc {
+	return h.setAlgorithm(false)
+}
+
+// setAlgorithm - изменение флага алгоритма {type} клиента {id} одним UPDATE, остальные флаги не меняются
+func (h *Handler) setAlgorithm(enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeError(w, r, model.ErrorInvalidID)
+			return
+		}
+		t := model.AlgorithmType(mux.Vars(r)["type"])
+		if !t.Valid() {
+			writeError(w, r, model.ErrorUnknownAlgorithm)
+			return
+		}
+		patch := model.NewAlgorithmPatch(t, enabled)
+		as, err := h.storage.PatchAlgorithmStatus(r.Context(), id, patch)
+		if errors.Is(err, model.ErrorApprovalRequired) {
+			h.requestApproval(w, r, id, patch)
+			return
+		}
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		h.triggerSync(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(h.algorithmState(r.Context(), as))
+	}
+}
+
+// algorithmState - желаемое состояние алгоритмов клиента вместе с наблюдаемыми pod'ами.
+// Ошибка получения pod'ов не скрывает желаемое состояние - она возвращается в observe_error.
+func (h *Handler) algorithmState(ctx context.Context, as *model.AlgorithmStatus) model.AlgorithmState {
+	state := model.AlgorithmState{Desired: *as, Observed: []model.PodState{}}
+	if h.sync == nil {
+		return state
+	}
+	pods, err := h.sync.Observe(ctx, *as)
+	if err != nil {
+		state.ObserveError = err.Error()
+		return state
+	}
+	state.Observed = pods
+	return state
+}
+
+// maxBulkChanges - максимальное количество изменений в одном пакете
+const maxBulkChanges = 1000
+
+// BulkUpdateAlgorithms - ручка пакетного изменения алгоритмов в одной транзакции.
+// 200 - применены все изменения, 207 - в режиме best_effort часть изменений не применена,
+// 409 - в режиме atomic пакет откачен. Синхронизация запускается один раз после фиксации пакета.
+func (h *Handler) BulkUpdateAlgorithms() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req model.BulkAlgorithmRequest
+		if err := decodeBody(r, &req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if req.Mode == "" {
+			req.Mode = model.BulkAtomic
+		}
+		if err := h.validateBulk(&req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		result, err := h.storage.BulkUpdateAlgorithms(r.Context(), req.Mode, req.Changes)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		status, failed := http.StatusOK, result.Failed()
+		switch {
+		case !result.Applied:
+			status = http.StatusConflict
+		case failed > 0:
+			status = http.StatusMultiStatus
+		}
+		if result.Applied && failed < len(result.Results) {
+			h.triggerSync(r.Context())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// validateBulk - проверка режима и всех изменений пакета до обращения к БД.
+// Включение алгоритмов из политики подтверждения пакетом запрещено.
+func (h *Handler) validateBulk(req *model.BulkAlgorithmRequest) error {
+	var v model.ValidationError
+	if req.Mode != model.BulkAtomic && req.Mode != model.BulkBestEffort {
+		v.Add("mode", "must be atomic or best_effort")
+	}
+	if len(req.Changes) == 0 || len(req.Changes) > maxBulkChanges {
+		v.Add("changes", fmt.Sprintf("must contain from 1 to %d changes", maxBulkChanges))
+	}
+	for i, c := range req.Changes {
+		if c.ClientID <= 0 {
+			v.Add(fmt.Sprintf("changes[%d].client_id", i), "must be positive")
+		}
+		if !c.Algorithm.Valid() {
+			v.Add(fmt.Sprintf("changes[%d].algorithm", i), "must be vwap, twap or hft")
+		}
+		h.gatedEnable(&v, fmt.Sprintf("changes[%d].enabled", i), c.Algorithm, c.Enabled)
+	}
+	return v.Err()
+}
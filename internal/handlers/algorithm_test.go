@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
@@ -19,6 +21,7 @@ func TestHandler_UpdateAlgorithmStatus(t *testing.T) {
 		mockUpdateFunc     func(ctx context.Context, as *model.AlgorithmStatus) error
 		expectedStatusCode int
 		expectedResponse   string
+		expectedCode       string
 	}{
 		{
 			name: "ok",
@@ -29,17 +32,29 @@ func TestHandler_UpdateAlgorithmStatus(t *testing.T) {
 				HFT:      true,
 			},
 			mockUpdateFunc: func(ctx context.Context, as *model.AlgorithmStatus) error {
+				as.AlgorithmID = 3
 				return nil
 			},
 			expectedStatusCode: http.StatusOK,
-			expectedResponse:   "",
+			expectedResponse:   `{"algorithm_id":3,"client_id":1,"vwap":true,"twap":false,"hft":true}` + "\n",
 		},
 		{
 			name:               "400",
 			inputBody:          "invalid body",
 			mockUpdateFunc:     nil,
 			expectedStatusCode: http.StatusBadRequest,
-			expectedResponse:   "invalid request body\n",
+			expectedCode:       "invalid_body",
+		},
+		{
+			name: "404",
+			inputBody: model.AlgorithmStatus{
+				ClientID: 2,
+			},
+			mockUpdateFunc: func(ctx context.Context, as *model.AlgorithmStatus) error {
+				return model.ErrorClientNotFound
+			},
+			expectedStatusCode: http.StatusNotFound,
+			expectedCode:       "client_not_found",
 		},
 		{
 			name: "500",
@@ -53,7 +68,7 @@ func TestHandler_UpdateAlgorithmStatus(t *testing.T) {
 				return fmt.Errorf("error from db")
 			},
 			expectedStatusCode: http.StatusInternalServerError,
-			expectedResponse:   "internal server error\n",
+			expectedCode:       "internal",
 		},
 	}
 
@@ -75,7 +90,252 @@ func TestHandler_UpdateAlgorithmStatus(t *testing.T) {
 			handler.UpdateAlgorithmStatus()(rr, req)
 
 			assert.Equal(t, tt.expectedStatusCode, rr.Code)
-			assert.Equal(t, tt.expectedResponse, rr.Body.String())
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+			} else {
+				assert.Equal(t, tt.expectedResponse, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_PatchAlgorithmStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		storageError   error
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:           "200",
+			body:           `{"hft":true}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "400",
+			body:           `{"hft":"yes"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_body",
+		},
+		{
+			name:           "422",
+			body:           `{"hft":null}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+		},
+		{
+			name:           "404",
+			body:           `{"hft":true}`,
+			storageError:   model.ErrorClientNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "client_not_found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &mockStorage{
+				patchAlgorithmStatus: func(ctx context.Context, clientID int64, patch *model.AlgorithmPatch) (*model.AlgorithmStatus, error) {
+					if tt.storageError != nil {
+						return nil, tt.storageError
+					}
+					assert.Nil(t, patch.VWAP)
+					assert.Nil(t, patch.TWAP)
+					return &model.AlgorithmStatus{AlgorithmID: 3, ClientID: clientID, VWAP: true, HFT: *patch.HFT}, nil
+				},
+			}
+			handler := &Handler{storage: storage}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPatch, "/api/client/1/algorithms", bytes.NewBufferString(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+			handler.PatchAlgorithmStatus()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+			} else {
+				assert.Equal(t, `{"algorithm_id":3,"client_id":1,"vwap":true,"twap":false,"hft":true}`+"\n", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_SetAlgorithm(t *testing.T) {
+	pods := []model.PodState{
+		{Algorithm: model.AlgorithmVWAP, Pod: "vmap-3", Running: true},
+		{Algorithm: model.AlgorithmTWAP, Pod: "twap-3"},
+		{Algorithm: model.AlgorithmHFT, Pod: "hft-3"},
+	}
+	tests := []struct {
+		name            string
+		method          string
+		algorithm       string
+		observeErr      error
+		storageError    error
+		expectedStatus  int
+		expectedCode    string
+		expectedBody    string
+		expectedTrigger int
+	}{
+		{
+			name:           "200 enable",
+			method:         http.MethodPut,
+			algorithm:      "hft",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"desired":{"algorithm_id":3,"client_id":1,"vwap":true,"twap":false,"hft":true},` +
+				`"observed":[{"algorithm":"vwap","pod":"vmap-3","running":true},{"algorithm":"twap","pod":"twap-3","running":false},` +
+				`{"algorithm":"hft","pod":"hft-3","running":false}]}` + "\n",
+			expectedTrigger: 1,
+		},
+		{
+			name:           "200 disable, pods unknown",
+			method:         http.MethodDelete,
+			algorithm:      "vwap",
+			observeErr:     errors.New("k8s is down"),
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"desired":{"algorithm_id":3,"client_id":1,"vwap":false,"twap":false,"hft":false},` +
+				`"observed":[],"observe_error":"k8s is down"}` + "\n",
+			expectedTrigger: 1,
+		},
+		{
+			name:           "404 algorithm",
+			method:         http.MethodPut,
+			algorithm:      "scalping",
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "algorithm_not_found",
+		},
+		{
+			name:           "404 client",
+			method:         http.MethodPut,
+			algorithm:      "hft",
+			storageError:   model.ErrorClientNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "client_not_found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &mockStorage{
+				patchAlgorithmStatus: func(ctx context.Context, clientID int64, patch *model.AlgorithmPatch) (*model.AlgorithmStatus, error) {
+					if tt.storageError != nil {
+						return nil, tt.storageError
+					}
+					as := &model.AlgorithmStatus{AlgorithmID: 3, ClientID: clientID, VWAP: true}
+					if patch.HFT != nil {
+						as.HFT = *patch.HFT
+					}
+					if patch.VWAP != nil {
+						as.VWAP = *patch.VWAP
+					}
+					assert.Nil(t, patch.TWAP)
+					return as, nil
+				},
+			}
+			syncer := &mockSyncer{pods: pods, observeErr: tt.observeErr}
+			handler := &Handler{storage: storage, sync: syncer}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, "/api/client/1/algorithms/"+tt.algorithm, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": "1", "type": tt.algorithm})
+
+			if tt.method == http.MethodPut {
+				handler.EnableAlgorithm()(rr, req)
+			} else {
+				handler.DisableAlgorithm()(rr, req)
+			}
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedTrigger, syncer.calls)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+			} else {
+				assert.Equal(t, tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_BulkUpdateAlgorithms(t *testing.T) {
+	tests := []struct {
+		name            string
+		body            string
+		missing         int64
+		expectedStatus  int
+		expectedCode    string
+		expectedMode    model.BulkMode
+		expectedTrigger int
+	}{
+		{
+			name:            "200",
+			body:            `{"changes":[{"client_id":1,"algorithm":"hft","enabled":true},{"client_id":2,"algorithm":"vwap","enabled":false}]}`,
+			expectedStatus:  http.StatusOK,
+			expectedMode:    model.BulkAtomic,
+			expectedTrigger: 1,
+		},
+		{
+			name:            "207",
+			body:            `{"mode":"best_effort","changes":[{"client_id":1,"algorithm":"hft","enabled":true},{"client_id":2,"algorithm":"vwap"}]}`,
+			missing:         2,
+			expectedStatus:  http.StatusMultiStatus,
+			expectedMode:    model.BulkBestEffort,
+			expectedTrigger: 1,
+		},
+		{
+			name:           "409",
+			body:           `{"mode":"atomic","changes":[{"client_id":1,"algorithm":"hft","enabled":true},{"client_id":2,"algorithm":"vwap"}]}`,
+			missing:        2,
+			expectedStatus: http.StatusConflict,
+			expectedMode:   model.BulkAtomic,
+		},
+		{
+			name:           "422",
+			body:           `{"mode":"some","changes":[{"client_id":0,"algorithm":"scalping"}]}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+		},
+		{
+			name:           "422 empty",
+			body:           `{"changes":[]}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &mockStorage{
+				bulkUpdateAlgorithms: func(ctx context.Context, mode model.BulkMode, changes []model.AlgorithmChange) (*model.BulkResult, error) {
+					assert.Equal(t, tt.expectedMode, mode)
+					res := &model.BulkResult{Mode: mode, Applied: true}
+					for i, c := range changes {
+						item := model.BulkItemResult{Index: i, AlgorithmChange: c, Status: model.BulkApplied}
+						if c.ClientID == tt.missing {
+							item.Status, item.Code = model.BulkFailed, "client_not_found"
+							res.Applied = mode == model.BulkBestEffort
+						}
+						res.Results = append(res.Results, item)
+					}
+					return res, nil
+				},
+			}
+			syncer := &mockSyncer{}
+			handler := &Handler{storage: storage, sync: syncer}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/algorithms/bulk", bytes.NewBufferString(tt.body))
+
+			handler.BulkUpdateAlgorithms()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedTrigger, syncer.calls)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+				return
+			}
+			var res model.BulkResult
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+			assert.Len(t, res.Results, 2)
 		})
 	}
 }
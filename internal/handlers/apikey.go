@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/CyrilSbrodov/syncService/internal/auth"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/CyrilSbrodov/syncService/internal/validation"
+	"net/http"
+)
+
+// maxKeyName - длина колонки api_keys.name
+const maxKeyName = 100
+
+// GetAPIKeys - ручка получения ключей API, сами ключи не возвращаются
+func (h *Handler) GetAPIKeys() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys, err := h.storage.GetAPIKeys(r.Context())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if keys == nil {
+			keys = []model.APIKey{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(keys)
+	}
+}
+
+// CreateAPIKey - ручка выпуска ключа API. Ключ возвращается один раз, в БД хранится только его хеш.
+func (h *Handler) CreateAPIKey() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req model.CreateAPIKeyRequest
+		if err := decodeBody(r, &req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if err := validateAPIKey(&req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		key, prefix, hash, err := auth.NewAPIKey()
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		created := model.CreatedAPIKey{
+			APIKey: model.APIKey{Name: req.Name, Prefix: prefix, Role: req.Role, Scope: req.Scope},
+			Key:    key,
+		}
+		if err := h.storage.AddAPIKey(r.Context(), &created.APIKey, hash); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+	}
+}
+
+// RevokeAPIKey - ручка отзыва ключа API
+func (h *Handler) RevokeAPIKey() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeError(w, r, model.ErrorInvalidID)
+			return
+		}
+		if err := h.storage.RevokeAPIKey(r.Context(), id); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// validateAPIKey - проверка имени и роли выпускаемого ключа
+func validateAPIKey(req *model.CreateAPIKeyRequest) error {
+	var v model.ValidationError
+	if req.Name == "" {
+		v.Add("name", "is required")
+	} else if len(req.Name) > maxKeyName {
+		v.Add("name", "must be at most 100 characters")
+	}
+	if !req.Role.Valid() {
+		v.Add("role", "must be one of viewer, operator, admin")
+	}
+	var scope *model.ValidationError
+	if errors.As(validation.Scope(&req.Scope), &scope) {
+		v.Fields = append(v.Fields, scope.Fields...)
+	}
+	return v.Err()
+}
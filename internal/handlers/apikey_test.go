@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CyrilSbrodov/syncService/internal/auth"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	tests := []struct {
+		name           string
+		inputBody      string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:           "201",
+			inputBody:      `{"name":"ops-bot","role":"operator"}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "422 role",
+			inputBody:      `{"name":"ops-bot","role":"root"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+		},
+		{
+			name:           "422 name",
+			inputBody:      `{"role":"viewer"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+		},
+		{
+			name:           "201 scoped",
+			inputBody:      `{"name":"ops-bot","role":"operator","scope":{"client_ids":[1],"tags":["desk-a"]}}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "422 scope",
+			inputBody:      `{"name":"ops-bot","role":"operator","scope":{"client_ids":[0],"tags":["Desk A"]}}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+		},
+		{
+			name:           "400",
+			inputBody:      `{"name":"ops-bot","role":"viewer","key":"mine"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_body",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var storedHash string
+			mockStorage := &mockStorage{
+				addAPIKey: func(ctx context.Context, key *model.APIKey, hash string) error {
+					key.ID = 5
+					storedHash = hash
+					return nil
+				},
+			}
+			h := &Handler{storage: mockStorage}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/keys", bytes.NewBufferString(tt.inputBody))
+
+			h.CreateAPIKey()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+				return
+			}
+			var created model.CreatedAPIKey
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+			assert.Equal(t, int64(5), created.ID)
+			assert.Equal(t, model.RoleOperator, created.Role)
+			assert.True(t, auth.IsAPIKey(created.Key))
+			assert.Equal(t, created.Key[:len(created.Prefix)], created.Prefix)
+			assert.Equal(t, auth.HashAPIKey(created.Key), storedHash)
+			assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		storageError   error
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"204", "3", nil, http.StatusNoContent, ""},
+		{"404", "4", model.ErrorAPIKeyNotFound, http.StatusNotFound, "api_key_not_found"},
+		{"400", "abc", nil, http.StatusBadRequest, "invalid_id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := &mockStorage{
+				revokeAPIKey: func(ctx context.Context, id int64) error {
+					return tt.storageError
+				},
+			}
+			h := &Handler{storage: mockStorage}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodDelete, "/api/admin/keys/"+tt.id, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+
+			h.RevokeAPIKey()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+			}
+		})
+	}
+}
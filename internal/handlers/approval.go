@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/CyrilSbrodov/syncService/cmd/loggers"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// requestApproval - ответ на изменение, которое хранилище отклонило с ErrorApprovalRequired: изменение включает
+// выключенный алгоритм из политики подтверждения. Решение принимается в транзакции изменения, здесь только
+// создаётся запрос на подтверждение всего изменения и возвращается 202 со ссылкой на него.
+func (h *Handler) requestApproval(w http.ResponseWriter, r *http.Request, clientID int64, patch *model.AlgorithmPatch) {
+	a := model.ApprovalRequest{
+		ClientID:    clientID,
+		Changes:     *patch,
+		RequestedBy: actor(r, anonymous.Subject),
+		ExpiresAt:   time.Now().Add(h.approvals.TTL),
+	}
+	if err := h.storage.AddApproval(r.Context(), &a); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.log(r).Info("approval requested", slog.Int64("approval", a.ID), loggers.ClientID(clientID),
+		slog.Any("algorithms", h.approvals.Requires(model.AlgorithmStatus{}, *patch)),
+		slog.String("requested_by", a.RequestedBy))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/api/approvals/%d", a.ID))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(a)
+}
+
+// GetApprovals - ручка получения запросов на подтверждение, фильтры ?client_id=42&status=pending
+func (h *Handler) GetApprovals() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var clientID int64
+		if v := r.URL.Query().Get("client_id"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				writeError(w, r, model.ErrorInvalidQuery)
+				return
+			}
+			clientID = id
+		}
+		status := model.ApprovalStatus(r.URL.Query().Get("status"))
+		switch status {
+		case "", model.ApprovalPending, model.ApprovalApproved, model.ApprovalRejected, model.ApprovalExpired:
+		default:
+			writeError(w, r, model.ErrorInvalidQuery)
+			return
+		}
+		approvals, err := h.storage.GetApprovals(r.Context(), clientID, status)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if approvals == nil {
+			approvals = []model.ApprovalRequest{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(approvals)
+	}
+}
+
+// GetApproval - ручка получения запроса на подтверждение
+func (h *Handler) GetApproval() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeError(w, r, model.ErrorInvalidID)
+			return
+		}
+		a, err := h.storage.GetApproval(r.Context(), id)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(a)
+	}
+}
+
+// ApproveChange - ручка подтверждения запроса, изменение сразу применяется к algorithm_status
+func (h *Handler) ApproveChange() http.HandlerFunc {
+	return h.decideApproval(model.ApprovalApproved)
+}
+
+// RejectChange - ручка отклонения запроса
+func (h *Handler) RejectChange() http.HandlerFunc {
+	return h.decideApproval(model.ApprovalRejected)
+}
+
+// decideApproval - перевод запроса {id} из pending в status. Подтверждающий - вызывающий, а не автор запроса.
+func (h *Handler) decideApproval(status model.ApprovalStatus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeError(w, r, model.ErrorInvalidID)
+			return
+		}
+		var d model.ApprovalDecision
+		if err := decodeBody(r, &d); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		d.DecidedBy = actor(r, d.DecidedBy)
+		if d.DecidedBy == "" {
+			var v model.ValidationError
+			v.Add("decided_by", "is required")
+			writeError(w, r, &v)
+			return
+		}
+		a, err := h.storage.DecideApproval(r.Context(), id, status, d.DecidedBy, d.Comment)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		h.log(r).Info("approval decided", slog.Int64("approval", a.ID), loggers.ClientID(a.ClientID),
+			slog.String("status", string(a.Status)), slog.String("decided_by", a.DecidedBy))
+		if status == model.ApprovalApproved {
+			h.triggerSync(r.Context())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(a)
+	}
+}
+
+// gatedEnable - ошибка поля для включения алгоритма, требующего подтверждения, в обход запроса на подтверждение
+func (h *Handler) gatedEnable(v *model.ValidationError, field string, t model.AlgorithmType, enabled bool) {
+	if enabled && h.approvals.Gated(t) {
+		v.Add(field, fmt.Sprintf("enabling %s requires approval, use PUT /api/client/{id}/algorithms/%s", t, t))
+	}
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// hftApproval - политика: включение hft подтверждает второй admin
+var hftApproval = model.ApprovalPolicy{
+	Algorithms:   []model.AlgorithmType{model.AlgorithmHFT},
+	ApproverRole: model.RoleAdmin,
+	TTL:          time.Hour,
+}
+
+func TestRequestApproval(t *testing.T) {
+	tests := []struct {
+		name             string
+		patchErr         error
+		expectedStatus   int
+		expectedApproval bool
+		expectedSync     int
+	}{
+		{
+			name:             "storage requires approval",
+			patchErr:         model.ErrorApprovalRequired,
+			expectedStatus:   http.StatusAccepted,
+			expectedApproval: true,
+		},
+		{
+			name:           "applied",
+			expectedStatus: http.StatusOK,
+			expectedSync:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var added *model.ApprovalRequest
+			storage := &mockStorage{
+				addApproval: func(ctx context.Context, a *model.ApprovalRequest) error {
+					a.ID, a.Status = 7, model.ApprovalPending
+					added = a
+					return nil
+				},
+				patchAlgorithmStatus: func(ctx context.Context, clientID int64, patch *model.AlgorithmPatch) (*model.AlgorithmStatus, error) {
+					if tt.patchErr != nil {
+						return nil, tt.patchErr
+					}
+					return &model.AlgorithmStatus{ClientID: clientID, HFT: true}, nil
+				},
+			}
+			sync := &mockSyncer{}
+			h := &Handler{storage: storage, sync: sync, logger: discardLogger(), approvals: hftApproval}
+
+			for _, do := range []func() *httptest.ResponseRecorder{
+				func() *httptest.ResponseRecorder {
+					rr := httptest.NewRecorder()
+					req := httptest.NewRequest(http.MethodPut, "/api/client/42/algorithms/hft", nil)
+					h.EnableAlgorithm()(rr, mux.SetURLVars(req, map[string]string{"id": "42", "type": "hft"}))
+					return rr
+				},
+				func() *httptest.ResponseRecorder {
+					rr := httptest.NewRecorder()
+					req := httptest.NewRequest(http.MethodPatch, "/api/client/42/algorithms", bytes.NewBufferString(`{"hft":true}`))
+					h.PatchAlgorithmStatus()(rr, mux.SetURLVars(req, map[string]string{"id": "42"}))
+					return rr
+				},
+			} {
+				added, sync.calls = nil, 0
+				rr := do()
+
+				assert.Equal(t, tt.expectedStatus, rr.Code)
+				assert.Equal(t, tt.expectedSync, sync.calls)
+				if !tt.expectedApproval {
+					assert.Nil(t, added)
+					continue
+				}
+				assert.Equal(t, "/api/approvals/7", rr.Header().Get("Location"))
+				assert.Equal(t, int64(42), added.ClientID)
+				assert.True(t, added.Changes.Enables(model.AlgorithmHFT))
+				assert.Equal(t, "anonymous", added.RequestedBy)
+				assert.True(t, added.ExpiresAt.After(time.Now()))
+			}
+		})
+	}
+}
+
+func TestHandler_DecideApproval(t *testing.T) {
+	tests := []struct {
+		name           string
+		approve        bool
+		body           string
+		storageError   error
+		expectedStatus int
+		expectedCode   string
+		expectedSync   int
+	}{
+		{
+			name:           "approve",
+			approve:        true,
+			body:           `{"decided_by":"bob"}`,
+			expectedStatus: http.StatusOK,
+			expectedSync:   1,
+		},
+		{
+			name:           "reject",
+			body:           `{"decided_by":"bob","comment":"not during the auction"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "self approval",
+			approve:        true,
+			body:           `{"decided_by":"alice"}`,
+			storageError:   model.ErrorSelfApproval,
+			expectedStatus: http.StatusForbidden,
+			expectedCode:   "self_approval",
+		},
+		{
+			name:           "already decided",
+			approve:        true,
+			body:           `{"decided_by":"bob"}`,
+			storageError:   model.ErrorApprovalNotPending,
+			expectedStatus: http.StatusConflict,
+			expectedCode:   "approval_not_pending",
+		},
+		{
+			name:           "no decided_by",
+			approve:        true,
+			body:           `{}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &mockStorage{
+				decideApproval: func(ctx context.Context, id int64, status model.ApprovalStatus, decidedBy, comment string) (*model.ApprovalRequest, error) {
+					if tt.storageError != nil {
+						return nil, tt.storageError
+					}
+					return &model.ApprovalRequest{ID: id, ClientID: 42, Status: status, RequestedBy: "alice",
+						DecidedBy: decidedBy, Comment: comment}, nil
+				},
+			}
+			sync := &mockSyncer{}
+			h := &Handler{storage: storage, sync: sync, logger: discardLogger(), approvals: hftApproval}
+			handler := h.RejectChange()
+			if tt.approve {
+				handler = h.ApproveChange()
+			}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/approvals/7", bytes.NewBufferString(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": "7"})
+
+			handler(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedSync, sync.calls)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+				return
+			}
+			var a model.ApprovalRequest
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &a))
+			assert.Equal(t, "bob", a.DecidedBy)
+		})
+	}
+}
+
+func TestGatedEnable_Bulk(t *testing.T) {
+	h := &Handler{approvals: hftApproval}
+	req := model.BulkAlgorithmRequest{Mode: model.BulkAtomic, Changes: []model.AlgorithmChange{
+		{ClientID: 1, Algorithm: model.AlgorithmHFT, Enabled: false},
+		{ClientID: 1, Algorithm: model.AlgorithmVWAP, Enabled: true},
+		{ClientID: 2, Algorithm: model.AlgorithmHFT, Enabled: true},
+	}}
+
+	err := h.validateBulk(&req)
+
+	var v *model.ValidationError
+	assert.ErrorAs(t, err, &v)
+	assert.Len(t, v.Fields, 1)
+	assert.Equal(t, "changes[2].enabled", v.Fields[0].Field)
+}
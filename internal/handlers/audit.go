@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// auditDefaultLimit, auditMaxLimit - размер страницы журнала аудита по умолчанию и наибольший
+	auditDefaultLimit = 100
+	auditMaxLimit     = 1000
+)
+
+// GetAuditLog - ручка получения журнала аудита, новые записи первыми.
+// Фильтры ?client_id=42&actor=alice&from=...&to=... (RFC 3339), страницы - ?before=<id последней записи>&limit=100.
+func (h *Handler) GetAuditLog() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, err := auditFilter(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		entries, err := h.storage.GetAuditLog(r.Context(), f)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if entries == nil {
+			entries = []model.AuditEntry{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(entries)
+	}
+}
+
+// auditFilter - фильтр журнала аудита из параметров запроса
+func auditFilter(r *http.Request) (model.AuditFilter, error) {
+	q := r.URL.Query()
+	f := model.AuditFilter{Actor: q.Get("actor"), Limit: auditDefaultLimit}
+	for name, dst := range map[string]*int64{"client_id": &f.ClientID, "before": &f.BeforeID} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n <= 0 {
+				return f, model.ErrorInvalidQuery
+			}
+			*dst = n
+		}
+	}
+	for name, dst := range map[string]*time.Time{"from": &f.From, "to": &f.To} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, model.ErrorInvalidQuery
+			}
+			*dst = t
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > auditMaxLimit {
+			return f, model.ErrorInvalidQuery
+		}
+		f.Limit = n
+	}
+	return f, nil
+}
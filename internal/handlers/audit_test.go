@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_GetAuditLog(t *testing.T) {
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedFilter model.AuditFilter
+	}{
+		{
+			name:           "default limit",
+			query:          "",
+			expectedStatus: http.StatusOK,
+			expectedFilter: model.AuditFilter{Limit: 100},
+		},
+		{
+			name:           "filters",
+			query:          "?client_id=42&actor=alice&from=2026-10-01T00:00:00Z&before=90&limit=20",
+			expectedStatus: http.StatusOK,
+			expectedFilter: model.AuditFilter{ClientID: 42, Actor: "alice", From: from, BeforeID: 90, Limit: 20},
+		},
+		{
+			name:           "bad time",
+			query:          "?to=yesterday",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "limit too large",
+			query:          "?limit=5000",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "bad client id",
+			query:          "?client_id=-1",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got model.AuditFilter
+			storage := &mockStorage{
+				getAuditLog: func(ctx context.Context, f model.AuditFilter) ([]model.AuditEntry, error) {
+					got = f
+					return []model.AuditEntry{{ID: 91, Actor: "alice", Action: model.AuditPatch, Entity: model.EntityClient,
+						Diff: map[string]model.AuditChange{"memory": {Before: "1Gi", After: "2Gi"}}}}, nil
+				},
+			}
+			h := &Handler{storage: storage}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/audit"+tt.query, nil)
+
+			h.GetAuditLog()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tt.expectedFilter, got)
+			var entries []model.AuditEntry
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&entries))
+			assert.Equal(t, "2Gi", entries[0].Diff["memory"].After)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/CyrilSbrodov/syncService/cmd/loggers"
+	"github.com/CyrilSbrodov/syncService/internal/auth"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+	bearerPrefix = "Bearer "
+)
+
+// TokenVerifier - проверка bearer токенов, не являющихся ключами API (JWT)
+type TokenVerifier interface {
+	Verify(token string) (*model.Principal, error)
+}
+
+// anonymous - вызывающий при выключенной аутентификации
+var anonymous = model.Principal{Subject: "anonymous", Role: model.RoleAdmin, Method: model.AuthNone}
+
+// principalKey - ключ вызывающего в контексте запроса
+type principalKey struct{}
+
+// principal - вызывающий из контекста запроса, nil - запрос не прошёл через Authenticate
+func principal(r *http.Request) *model.Principal {
+	p, _ := r.Context().Value(principalKey{}).(*model.Principal)
+	return p
+}
+
+// actor - кто выполняет действие для журналов: аутентифицированный вызывающий, иначе значение из тела запроса
+func actor(r *http.Request, claimed string) string {
+	if p := principal(r); p != nil && p.Method != model.AuthNone {
+		return p.Subject
+	}
+	return claimed
+}
+
+// Authenticate - middleware аутентификации по ключу API (X-API-Key или Authorization: Bearer ssk_...)
+// либо по JWT в Authorization: Bearer. Вызывающий и его область видимости кладутся в контекст запроса,
+// по области хранилище ограничивает запросы, вызывающий и X-Request-ID пишутся в журнал аудита и лог доступа.
+// Без учётных данных - 401.
+func (h *Handler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := h.authenticate(r)
+		if err != nil {
+			if errors.Is(err, model.ErrorUnauthorized) {
+				h.log(r).Warn("authentication failed", slog.String("method", r.Method),
+					slog.String("path", r.URL.Path), loggers.Err(err))
+				w.Header().Set("WWW-Authenticate", `Bearer realm="syncService"`)
+			}
+			writeError(w, r, err)
+			return
+		}
+		setAccessPrincipal(r, p)
+		ctx := model.WithScope(context.WithValue(r.Context(), principalKey{}, p), p.Scope)
+		ctx = model.WithOrigin(ctx, model.Origin{Actor: p.Subject, RequestID: requestID(w, r)})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticate - вызывающий по учётным данным запроса.
+// Ошибки учётных данных оборачивают ErrorUnauthorized, ошибки БД возвращаются как есть.
+func (h *Handler) authenticate(r *http.Request) (*model.Principal, error) {
+	if !h.cfg.Auth.Enabled {
+		return &anonymous, nil
+	}
+	token := r.Header.Get(apiKeyHeader)
+	if token == "" {
+		token, _ = strings.CutPrefix(r.Header.Get("Authorization"), bearerPrefix)
+	}
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("%w: no credentials", model.ErrorUnauthorized)
+	case auth.IsAPIKey(token):
+		k, err := h.storage.AuthenticateAPIKey(r.Context(), auth.HashAPIKey(token))
+		if errors.Is(err, model.ErrorAPIKeyNotFound) {
+			return nil, fmt.Errorf("%w: unknown or revoked api key", model.ErrorUnauthorized)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &model.Principal{Subject: model.APIKeySubject(k.ID, k.Name), Role: k.Role, Method: model.AuthAPIKey, Scope: k.Scope}, nil
+	case h.tokens != nil:
+		p, err := h.tokens.Verify(token)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", model.ErrorUnauthorized, err)
+		}
+		return p, nil
+	}
+	return nil, fmt.Errorf("%w: bearer tokens are not configured", model.ErrorUnauthorized)
+}
+
+// allow - ручка, доступная вызывающим с ролью не ниже role
+func (h *Handler) allow(role model.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := principal(r)
+		if p == nil || !p.Role.Allows(role) {
+			attrs := []any{slog.String("method", r.Method), slog.String("path", r.URL.Path),
+				slog.String("required", string(role))}
+			if p != nil {
+				attrs = append(attrs, slog.String("subject", p.Subject), slog.String("role", string(p.Role)))
+			}
+			h.log(r).Warn("access denied", attrs...)
+			writeError(w, r, model.ErrorForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// allowGlobal - ручка, действующая на всех клиентов: кроме роли требуется доступ ко всем клиентам
+func (h *Handler) allowGlobal(role model.Role, next http.HandlerFunc) http.HandlerFunc {
+	return h.allow(role, func(w http.ResponseWriter, r *http.Request) {
+		if !model.ScopeFromContext(r.Context()).Unrestricted() {
+			writeError(w, r, model.ErrorOutOfScope)
+			return
+		}
+		next(w, r)
+	})
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CyrilSbrodov/syncService/cmd/loggers"
+	"github.com/CyrilSbrodov/syncService/internal/auth"
+	"github.com/CyrilSbrodov/syncService/internal/config"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockVerifier struct {
+	principal *model.Principal
+}
+
+func (m *mockVerifier) Verify(token string) (*model.Principal, error) {
+	if token != "jwt-token" {
+		return nil, errors.New("bad signature")
+	}
+	return m.principal, nil
+}
+
+// discardLogger - логгер для тестов ручек, которые пишут в лог
+func discardLogger() *loggers.Logger {
+	return &loggers.Logger{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+}
+
+func authConfig(enabled bool) *config.Config {
+	cfg := &config.Config{}
+	cfg.Auth.Enabled = enabled
+	return cfg
+}
+
+func TestAuthenticate(t *testing.T) {
+	const key = auth.KeyPrefix + "secret"
+	keys := &mockStorage{
+		authenticateAPIKey: func(ctx context.Context, hash string) (*model.APIKey, error) {
+			switch hash {
+			case auth.HashAPIKey(key):
+				return &model.APIKey{ID: 1, Name: "ops-bot", Role: model.RoleOperator}, nil
+			case auth.HashAPIKey(auth.KeyPrefix + "down"):
+				return nil, driver.ErrBadConn
+			}
+			return nil, model.ErrorAPIKeyNotFound
+		},
+	}
+	jwtAdmin := &mockVerifier{principal: &model.Principal{Subject: "jwt:alice", Role: model.RoleAdmin, Method: model.AuthJWT}}
+
+	tests := []struct {
+		name           string
+		enabled        bool
+		tokens         TokenVerifier
+		method         string
+		role           model.Role
+		headers        map[string]string
+		expectedStatus int
+		expectedCode   string
+		expectedCaller string
+	}{
+		{
+			name:           "no credentials",
+			enabled:        true,
+			method:         http.MethodGet,
+			role:           model.RoleViewer,
+			expectedStatus: http.StatusUnauthorized,
+			expectedCode:   "unauthorized",
+		},
+		{
+			name:           "api key in X-API-Key",
+			enabled:        true,
+			method:         http.MethodPost,
+			role:           model.RoleOperator,
+			headers:        map[string]string{apiKeyHeader: key},
+			expectedStatus: http.StatusOK,
+			expectedCaller: "apikey:1:ops-bot",
+		},
+		{
+			name:           "api key as bearer",
+			enabled:        true,
+			method:         http.MethodGet,
+			role:           model.RoleViewer,
+			headers:        map[string]string{"Authorization": "Bearer " + key},
+			expectedStatus: http.StatusOK,
+			expectedCaller: "apikey:1:ops-bot",
+		},
+		{
+			name:           "role too low",
+			enabled:        true,
+			method:         http.MethodPost,
+			role:           model.RoleAdmin,
+			headers:        map[string]string{apiKeyHeader: key},
+			expectedStatus: http.StatusForbidden,
+			expectedCode:   "forbidden",
+		},
+		{
+			name:           "revoked key",
+			enabled:        true,
+			method:         http.MethodGet,
+			role:           model.RoleViewer,
+			headers:        map[string]string{apiKeyHeader: auth.KeyPrefix + "revoked"},
+			expectedStatus: http.StatusUnauthorized,
+			expectedCode:   "unauthorized",
+		},
+		{
+			name:           "db unavailable",
+			enabled:        true,
+			method:         http.MethodGet,
+			role:           model.RoleViewer,
+			headers:        map[string]string{apiKeyHeader: auth.KeyPrefix + "down"},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCode:   "unavailable",
+		},
+		{
+			name:           "jwt",
+			enabled:        true,
+			tokens:         jwtAdmin,
+			method:         http.MethodPost,
+			role:           model.RoleAdmin,
+			headers:        map[string]string{"Authorization": "Bearer jwt-token"},
+			expectedStatus: http.StatusOK,
+			expectedCaller: "jwt:alice",
+		},
+		{
+			name:           "invalid jwt",
+			enabled:        true,
+			tokens:         jwtAdmin,
+			method:         http.MethodGet,
+			role:           model.RoleViewer,
+			headers:        map[string]string{"Authorization": "Bearer forged"},
+			expectedStatus: http.StatusUnauthorized,
+			expectedCode:   "unauthorized",
+		},
+		{
+			name:           "jwt not configured",
+			enabled:        true,
+			method:         http.MethodGet,
+			role:           model.RoleViewer,
+			headers:        map[string]string{"Authorization": "Bearer jwt-token"},
+			expectedStatus: http.StatusUnauthorized,
+			expectedCode:   "unauthorized",
+		},
+		{
+			name:           "auth disabled",
+			enabled:        false,
+			method:         http.MethodPost,
+			role:           model.RoleAdmin,
+			expectedStatus: http.StatusOK,
+			expectedCaller: "claimed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{cfg: authConfig(tt.enabled), logger: discardLogger(), storage: keys, tokens: tt.tokens}
+			var caller string
+			next := h.allow(tt.role, func(w http.ResponseWriter, r *http.Request) {
+				caller = actor(r, "claimed")
+			})
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, "/api/client", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			h.Authenticate(next).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+			}
+			if tt.expectedStatus == http.StatusUnauthorized {
+				assert.NotEmpty(t, rr.Header().Get("WWW-Authenticate"))
+			}
+			assert.Equal(t, tt.expectedCaller, caller)
+		})
+	}
+}
+
+func TestAllowGlobal(t *testing.T) {
+	scoped := model.Scope{Tags: []string{"desk-a"}}
+	tests := []struct {
+		name           string
+		key            *model.APIKey
+		global         bool
+		expectedStatus int
+		expectedCode   string
+		expectedScope  model.Scope
+	}{
+		{
+			name:           "scoped key on client route",
+			key:            &model.APIKey{Name: "desk-a-bot", Role: model.RoleAdmin, Scope: scoped},
+			expectedStatus: http.StatusOK,
+			expectedScope:  scoped,
+		},
+		{
+			name:           "scoped key on global route",
+			key:            &model.APIKey{Name: "desk-a-bot", Role: model.RoleAdmin, Scope: scoped},
+			global:         true,
+			expectedStatus: http.StatusForbidden,
+			expectedCode:   "out_of_scope",
+		},
+		{
+			name:           "unrestricted key on global route",
+			key:            &model.APIKey{Name: "ops-bot", Role: model.RoleAdmin},
+			global:         true,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := &mockStorage{
+				authenticateAPIKey: func(ctx context.Context, hash string) (*model.APIKey, error) {
+					return tt.key, nil
+				},
+			}
+			h := &Handler{cfg: authConfig(true), logger: discardLogger(), storage: keys}
+			var scope model.Scope
+			next := func(w http.ResponseWriter, r *http.Request) {
+				scope = model.ScopeFromContext(r.Context())
+			}
+			wrap := h.allow
+			if tt.global {
+				wrap = h.allowGlobal
+			}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/sync/freeze", nil)
+			req.Header.Set(apiKeyHeader, auth.KeyPrefix+"secret")
+
+			h.Authenticate(wrap(model.RoleOperator, next)).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+			}
+			assert.Equal(t, tt.expectedScope, scope)
+		})
+	}
+}
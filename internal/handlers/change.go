@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AddScheduledChange - ручка создания отложенного изменения статуса алгоритма.
+// Отложенное включение алгоритма из политики подтверждения запрещено.
+func (h *Handler) AddScheduledChange() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var c model.ScheduledChange
+		if err := decodeBody(r, &c); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		c.CreatedBy = actor(r, c.CreatedBy)
+		var v model.ValidationError
+		if c.ClientID <= 0 {
+			v.Add("client_id", "must be positive")
+		}
+		if !c.Algorithm.Valid() {
+			v.Add("algorithm", "unknown algorithm")
+		}
+		if !c.ApplyAt.After(time.Now()) {
+			v.Add("apply_at", "must be in the future")
+		}
+		h.gatedEnable(&v, "enabled", c.Algorithm, c.Enabled)
+		if err := v.Err(); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		c.AppliedAt, c.Error = nil, ""
+		if err := h.storage.AddScheduledChange(r.Context(), &c); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", fmt.Sprintf("/api/scheduled-changes/%d", c.ID))
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(c)
+	}
+}
+
+// GetScheduledChanges - ручка получения отложенных изменений, фильтры ?client_id=42&status=pending
+func (h *Handler) GetScheduledChanges() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var clientID int64
+		if v := r.URL.Query().Get("client_id"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				writeError(w, r, model.ErrorInvalidQuery)
+				return
+			}
+			clientID = id
+		}
+		status := model.ChangeStatus(r.URL.Query().Get("status"))
+		changes, err := h.storage.GetScheduledChanges(r.Context(), clientID, status)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if changes == nil {
+			changes = []model.ScheduledChange{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(changes)
+	}
+}
+
+// CancelScheduledChange - ручка отмены ещё не применённого изменения
+func (h *Handler) CancelScheduledChange() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeError(w, r, model.ErrorInvalidID)
+			return
+		}
+		if err := h.storage.CancelScheduledChange(r.Context(), id); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+	}
+}
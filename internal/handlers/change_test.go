@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_AddScheduledChange(t *testing.T) {
+	tests := []struct {
+		name           string
+		inputBody      string
+		storageError   error
+		expectedStatus int
+		expectedBody   string
+		expectedCode   string
+		expectedLoc    string
+	}{
+		{
+			name:           "201",
+			inputBody:      `{"client_id":42,"algorithm":"hft","enabled":true,"apply_at":"2999-11-02T07:00:00Z"}`,
+			expectedStatus: http.StatusCreated,
+			expectedLoc:    "/api/scheduled-changes/5",
+		},
+		{
+			name:           "422 algorithm",
+			inputBody:      `{"client_id":42,"algorithm":"foo","enabled":true,"apply_at":"2999-11-02T07:00:00Z"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+		},
+		{
+			name:           "422 past",
+			inputBody:      `{"client_id":42,"algorithm":"twap","apply_at":"2000-01-01T00:00:00Z"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+		},
+		{
+			name:           "404",
+			inputBody:      `{"client_id":42,"algorithm":"hft","enabled":true,"apply_at":"2999-11-02T07:00:00Z"}`,
+			storageError:   model.ErrorClientNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "client_not_found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &mockStorage{
+				addScheduledChange: func(ctx context.Context, c *model.ScheduledChange) error {
+					c.ID = 5
+					return tt.storageError
+				},
+			}
+			handler := &Handler{storage: storage}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/scheduled-changes", bytes.NewBufferString(tt.inputBody))
+
+			handler.AddScheduledChange()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedLoc, rr.Header().Get("Location"))
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+			} else if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_GetScheduledChanges(t *testing.T) {
+	storage := &mockStorage{
+		getScheduledChanges: func(ctx context.Context, clientID int64, status model.ChangeStatus) ([]model.ScheduledChange, error) {
+			return nil, nil
+		},
+	}
+	handler := &Handler{storage: storage}
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/scheduled-changes?status=pending", nil)
+
+	handler.GetScheduledChanges()(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "[]\n", rr.Body.String())
+}
+
+func TestHandler_CancelScheduledChange(t *testing.T) {
+	tests := []struct {
+		name           string
+		storageError   error
+		expectedStatus int
+	}{
+		{name: "200", expectedStatus: http.StatusOK},
+		{name: "404", storageError: model.ErrorNoPendingChange, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &mockStorage{
+				cancelScheduledChange: func(ctx context.Context, id int64) error {
+					return tt.storageError
+				},
+			}
+			handler := &Handler{storage: storage}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodDelete, "/api/scheduled-changes/3", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": "3"})
+
+			handler.CancelScheduledChange()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+}
@@ -2,62 +2,269 @@ package handlers
 
 import (
 	"encoding/json"
-	"errors"
+	"fmt"
+	"github.com/CyrilSbrodov/syncService/cmd/loggers"
 	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/CyrilSbrodov/syncService/internal/validation"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // AddClient - ручка добавления нового клиента
 func (h *Handler) AddClient() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var client model.Client
-		if err := json.NewDecoder(r.Body).Decode(&client); err != nil {
-			http.Error(w, "invalid request body", http.StatusBadRequest)
+		var req model.CreateClientRequest
+		if err := decodeBody(r, &req); err != nil {
+			writeError(w, r, err)
 			return
 		}
-		if err := h.storage.AddClient(r.Context(), &client); err != nil {
-			if errors.Is(err, model.ErrorClientConflict) {
-				http.Error(w, "client_name is already exists", http.StatusConflict)
-				return
-			}
-			http.Error(w, "internal server error", http.StatusInternalServerError)
+		client := req.Client()
+		if err := validation.Client(client); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if err := h.storage.AddClient(r.Context(), client); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", fmt.Sprintf("/api/client/%d", client.ID))
+		w.Header().Set("ETag", clientETag(client.Revision))
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(model.NewClientResponse(client))
+	}
+}
+
+// GetClient - ручка получения клиента
+func (h *Handler) GetClient() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeError(w, r, model.ErrorInvalidID)
+			return
+		}
+		client, err := h.storage.GetClient(r.Context(), id)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", clientETag(client.Revision))
 		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(model.NewClientResponse(client))
 	}
 }
 
-// UpdateClient - ручка изменения клиента
+// UpdateClient - ручка изменения клиента.
+// С заголовком If-Match клиент меняется, только если его ревизия не изменилась с момента чтения, иначе 412.
 func (h *Handler) UpdateClient() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var client model.Client
-		if err := json.NewDecoder(r.Body).Decode(&client); err != nil {
-			http.Error(w, "invalid request body", http.StatusBadRequest)
+		var req model.UpdateClientRequest
+		if err := decodeBody(r, &req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		client := req.Client()
+		if err := validation.ClientUpdate(client); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		revision, err := ifMatchRevision(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		client.Revision = revision
+		if err := h.storage.UpdateClient(r.Context(), client); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", clientETag(client.Revision))
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(model.NewClientResponse(client))
+	}
+}
+
+// PatchClient - ручка частичного изменения клиента (JSON Merge Patch).
+// Проверяется клиент после применения изменения, в БД пишутся только переданные поля. If-Match - как у UpdateClient.
+func (h *Handler) PatchClient() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeError(w, r, model.ErrorInvalidID)
+			return
+		}
+		revision, err := ifMatchRevision(r)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
-		if err := h.storage.UpdateClient(r.Context(), &client); err != nil {
-			http.Error(w, "internal server error", http.StatusInternalServerError)
+		var patch model.ClientPatch
+		if err := decodeMergePatch(r, &patch); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		current, err := h.storage.GetClient(r.Context(), id)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if revision != 0 && current.Revision != revision {
+			writeError(w, r, model.ErrorRevisionMismatch)
+			return
+		}
+		patch.Apply(current)
+		if err := validation.ClientUpdate(current); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		client, err := h.storage.PatchClient(r.Context(), id, revision, &patch)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", clientETag(client.Revision))
 		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(model.NewClientResponse(client))
 	}
 }
 
-// DeleteClient - ручка удаления клиента
+// DeleteClient - ручка удаления клиента. Клиент удаляется мягко: pod'ы удаляет синкер,
+// а конфигурацию можно восстановить через RestoreClient в течение cfg.Clients.DeletionGrace.
 func (h *Handler) DeleteClient() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var client model.Client
-		if err := json.NewDecoder(r.Body).Decode(&client); err != nil {
-			http.Error(w, "invalid request body", http.StatusBadRequest)
+		id, err := pathID(r)
+		if err != nil {
+			writeError(w, r, model.ErrorInvalidID)
 			return
 		}
-		if err := h.storage.DeleteClient(r.Context(), &client); err != nil {
-			http.Error(w, "internal server error", http.StatusInternalServerError)
+		if err := h.storage.DeleteClient(r.Context(), id); err != nil {
+			writeError(w, r, err)
 			return
 		}
+		h.log(r).Info("client deleted", loggers.ClientID(id),
+			slog.String("by", model.OriginFromContext(r.Context()).Actor))
+		h.triggerSync(r.Context())
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+// RestoreClient - ручка восстановления удалённого клиента до его окончательного удаления
+func (h *Handler) RestoreClient() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeError(w, r, model.ErrorInvalidID)
+			return
+		}
+		client, err := h.storage.RestoreClient(r.Context(), id)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		h.log(r).Info("client restored", loggers.ClientID(id),
+			slog.String("by", model.OriginFromContext(r.Context()).Actor))
+		h.triggerSync(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", clientETag(client.Revision))
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(model.NewClientResponse(client))
+	}
+}
+
+// SetDeletionProtection - ручка установки и снятия защиты клиента от удаления
+func (h *Handler) SetDeletionProtection() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeError(w, r, model.ErrorInvalidID)
+			return
+		}
+		var req model.DeletionProtection
+		if err := decodeBody(r, &req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		client, err := h.storage.SetDeletionProtection(r.Context(), id, req.Protected)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", clientETag(client.Revision))
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(model.NewClientResponse(client))
+	}
+}
+
+// SetClientSuspension - ручка приостановки синхронизации клиента.
+// Пока приостановка действует, синкер не создаёт и не удаляет pod'ы клиента.
+func (h *Handler) SetClientSuspension() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeError(w, r, model.ErrorInvalidID)
+			return
+		}
+		var s model.Suspension
+		if err := decodeBody(r, &s); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if err := checkUntil(s.Until); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		s.ClientID = id
+		if !s.Suspended {
+			s.Until, s.Reason = nil, ""
+		}
+		if err := h.storage.SetClientSuspension(r.Context(), &s); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if !s.Suspended {
+			h.triggerSync(r.Context())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(s)
+	}
+}
+
+// checkUntil - время автоматического снятия должно быть в будущем
+func checkUntil(until *time.Time) error {
+	var v model.ValidationError
+	if until != nil && !until.After(time.Now()) {
+		v.Add("until", "must be in the future")
+	}
+	return v.Err()
+}
+
+// clientETag - ETag клиента по ревизии строки
+func clientETag(revision int64) string {
+	return strconv.Quote(strconv.FormatInt(revision, 10))
+}
+
+// ifMatchRevision - ревизия из заголовка If-Match, 0 если заголовка нет или он равен "*"
+func ifMatchRevision(r *http.Request) (int64, error) {
+	v := strings.TrimPrefix(strings.TrimSpace(r.Header.Get("If-Match")), "W/")
+	if v == "" || v == "*" {
+		return 0, nil
+	}
+	unquoted, err := strconv.Unquote(v)
+	if err != nil {
+		return 0, model.ErrorInvalidIfMatch
+	}
+	revision, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || revision <= 0 {
+		return 0, model.ErrorInvalidIfMatch
+	}
+	return revision, nil
+}
@@ -8,29 +8,126 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 )
 
 type mockStorage struct {
 	addClient             func(ctx context.Context, client *model.Client) error
+	getClient             func(ctx context.Context, id int64) (*model.Client, error)
 	updateClient          func(ctx context.Context, client *model.Client) error
-	deleteClient          func(ctx context.Context, client *model.Client) error
+	getClients            func(ctx context.Context) ([]model.Client, error)
+	setClientsSpawned     func(ctx context.Context, ids []int64) error
+	setClientsRestarted   func(ctx context.Context, clients []model.Client) error
+	patchClient           func(ctx context.Context, id, revision int64, patch *model.ClientPatch) (*model.Client, error)
+	patchAlgorithmStatus  func(ctx context.Context, clientID int64, patch *model.AlgorithmPatch) (*model.AlgorithmStatus, error)
+	getClientAlgorithms   func(ctx context.Context, clientID int64) (*model.AlgorithmStatus, error)
+	bulkUpdateAlgorithms  func(ctx context.Context, mode model.BulkMode, changes []model.AlgorithmChange) (*model.BulkResult, error)
+	deleteClient          func(ctx context.Context, id int64) error
+	restoreClient         func(ctx context.Context, id int64) (*model.Client, error)
+	setDeletionProtection func(ctx context.Context, id int64, protected bool) (*model.Client, error)
+	purgeDeletedClients   func(ctx context.Context, before time.Time) (int64, error)
 	updateAlgorithmStatus func(ctx context.Context, a *model.AlgorithmStatus) error
 	getAlgorithmStatus    func(ctx context.Context) ([]model.AlgorithmStatus, error)
+	getDeletedAlgorithms  func(ctx context.Context) ([]int64, error)
+	getSyncGuard          func(ctx context.Context) (*model.SyncGuard, error)
+	raiseSyncGuard        func(ctx context.Context, guard *model.SyncGuard) error
+	confirmSyncGuard      func(ctx context.Context) error
+	resetSyncGuard        func(ctx context.Context) error
+	addHalt               func(ctx context.Context, halt *model.Halt) error
+	getHalts              func(ctx context.Context, activeOnly bool) ([]model.Halt, error)
+	resumeHalt            func(ctx context.Context, id int64, resumedBy string) error
+	setClientSuspension   func(ctx context.Context, s *model.Suspension) error
+	getSuspendedClients   func(ctx context.Context) ([]model.Suspension, error)
+	getFreeze             func(ctx context.Context) (*model.Freeze, error)
+	setFreeze             func(ctx context.Context, f *model.Freeze) error
+	getSyncStatus         func(ctx context.Context) (*model.SyncStatus, error)
+	saveSyncStatus        func(ctx context.Context, st *model.SyncStatus) error
+	tryLockSync           func(ctx context.Context) (bool, error)
+	notifySync            func(ctx context.Context, event model.SyncEvent) error
+	listenSync            func(ctx context.Context) (<-chan model.SyncEvent, error)
+	getSchedules          func(ctx context.Context, clientID int64) ([]model.Schedule, error)
+	setSchedule           func(ctx context.Context, s *model.Schedule) error
+	deleteSchedule        func(ctx context.Context, clientID int64, algorithm model.AlgorithmType) error
+	addScheduledChange    func(ctx context.Context, c *model.ScheduledChange) error
+	getScheduledChanges   func(ctx context.Context, clientID int64, status model.ChangeStatus) ([]model.ScheduledChange, error)
+	cancelScheduledChange func(ctx context.Context, id int64) error
+	applyDueChanges       func(ctx context.Context) ([]model.ScheduledChange, error)
+	reserveIdempotencyKey func(ctx context.Context, rec *model.IdempotencyRecord) (*model.IdempotencyRecord, error)
+	saveIdempotency       func(ctx context.Context, rec *model.IdempotencyRecord) error
+	deleteIdempotencyKey  func(ctx context.Context, key string) error
+	purgeIdempotencyKeys  func(ctx context.Context) (int64, error)
+	addAPIKey             func(ctx context.Context, key *model.APIKey, hash string) error
+	getAPIKeys            func(ctx context.Context) ([]model.APIKey, error)
+	authenticateAPIKey    func(ctx context.Context, hash string) (*model.APIKey, error)
+	revokeAPIKey          func(ctx context.Context, id int64) error
+	addApproval           func(ctx context.Context, a *model.ApprovalRequest) error
+	getApproval           func(ctx context.Context, id int64) (*model.ApprovalRequest, error)
+	getApprovals          func(ctx context.Context, clientID int64, status model.ApprovalStatus) ([]model.ApprovalRequest, error)
+	decideApproval        func(ctx context.Context, id int64, status model.ApprovalStatus, decidedBy, comment string) (*model.ApprovalRequest, error)
+	getAuditLog           func(ctx context.Context, f model.AuditFilter) ([]model.AuditEntry, error)
+	getClientRevisions    func(ctx context.Context, clientID int64) ([]model.ClientRevision, error)
+	getClientRevision     func(ctx context.Context, clientID, revision int64) (*model.ClientRevision, error)
+	rollbackClient        func(ctx context.Context, clientID, revision int64) (*model.ClientRevision, error)
 }
 
 func (m *mockStorage) AddClient(ctx context.Context, client *model.Client) error {
 	return m.addClient(ctx, client)
 }
 
+func (m *mockStorage) GetClient(ctx context.Context, id int64) (*model.Client, error) {
+	return m.getClient(ctx, id)
+}
+
 func (m *mockStorage) UpdateClient(ctx context.Context, client *model.Client) error {
 	return m.updateClient(ctx, client)
 }
 
-func (m *mockStorage) DeleteClient(ctx context.Context, client *model.Client) error {
-	return m.deleteClient(ctx, client)
+func (m *mockStorage) PatchClient(ctx context.Context, id, revision int64, patch *model.ClientPatch) (*model.Client, error) {
+	return m.patchClient(ctx, id, revision, patch)
+}
+
+func (m *mockStorage) PatchAlgorithmStatus(ctx context.Context, clientID int64, patch *model.AlgorithmPatch) (*model.AlgorithmStatus, error) {
+	return m.patchAlgorithmStatus(ctx, clientID, patch)
+}
+
+func (m *mockStorage) GetClientAlgorithms(ctx context.Context, clientID int64) (*model.AlgorithmStatus, error) {
+	return m.getClientAlgorithms(ctx, clientID)
+}
+
+func (m *mockStorage) BulkUpdateAlgorithms(ctx context.Context, mode model.BulkMode, changes []model.AlgorithmChange) (*model.BulkResult, error) {
+	return m.bulkUpdateAlgorithms(ctx, mode, changes)
+}
+
+func (m *mockStorage) GetClients(ctx context.Context) ([]model.Client, error) {
+	return m.getClients(ctx)
+}
+
+func (m *mockStorage) SetClientsSpawned(ctx context.Context, ids []int64) error {
+	return m.setClientsSpawned(ctx, ids)
+}
+
+func (m *mockStorage) SetClientsRestarted(ctx context.Context, clients []model.Client) error {
+	return m.setClientsRestarted(ctx, clients)
+}
+
+func (m *mockStorage) DeleteClient(ctx context.Context, id int64) error {
+	return m.deleteClient(ctx, id)
+}
+
+func (m *mockStorage) RestoreClient(ctx context.Context, id int64) (*model.Client, error) {
+	return m.restoreClient(ctx, id)
+}
+
+func (m *mockStorage) SetDeletionProtection(ctx context.Context, id int64, protected bool) (*model.Client, error) {
+	return m.setDeletionProtection(ctx, id, protected)
+}
+
+func (m *mockStorage) PurgeDeletedClients(ctx context.Context, before time.Time) (int64, error) {
+	return m.purgeDeletedClients(ctx, before)
 }
 
 func (m *mockStorage) UpdateAlgorithmStatus(ctx context.Context, a *model.AlgorithmStatus) error {
@@ -41,38 +138,235 @@ func (m *mockStorage) GetAlgorithmStatus(ctx context.Context) ([]model.Algorithm
 	return m.getAlgorithmStatus(ctx)
 }
 
+func (m *mockStorage) GetDeletedAlgorithms(ctx context.Context) ([]int64, error) {
+	return m.getDeletedAlgorithms(ctx)
+}
+
+func (m *mockStorage) GetSyncGuard(ctx context.Context) (*model.SyncGuard, error) {
+	return m.getSyncGuard(ctx)
+}
+
+func (m *mockStorage) RaiseSyncGuard(ctx context.Context, guard *model.SyncGuard) error {
+	return m.raiseSyncGuard(ctx, guard)
+}
+
+func (m *mockStorage) ConfirmSyncGuard(ctx context.Context) error {
+	return m.confirmSyncGuard(ctx)
+}
+
+func (m *mockStorage) ResetSyncGuard(ctx context.Context) error {
+	return m.resetSyncGuard(ctx)
+}
+
+func (m *mockStorage) AddHalt(ctx context.Context, halt *model.Halt) error {
+	return m.addHalt(ctx, halt)
+}
+
+func (m *mockStorage) GetHalts(ctx context.Context, activeOnly bool) ([]model.Halt, error) {
+	return m.getHalts(ctx, activeOnly)
+}
+
+func (m *mockStorage) ResumeHalt(ctx context.Context, id int64, resumedBy string) error {
+	return m.resumeHalt(ctx, id, resumedBy)
+}
+
+func (m *mockStorage) SetClientSuspension(ctx context.Context, s *model.Suspension) error {
+	return m.setClientSuspension(ctx, s)
+}
+
+func (m *mockStorage) GetSuspendedClients(ctx context.Context) ([]model.Suspension, error) {
+	return m.getSuspendedClients(ctx)
+}
+
+func (m *mockStorage) GetFreeze(ctx context.Context) (*model.Freeze, error) {
+	return m.getFreeze(ctx)
+}
+
+func (m *mockStorage) SetFreeze(ctx context.Context, f *model.Freeze) error {
+	return m.setFreeze(ctx, f)
+}
+
+func (m *mockStorage) GetSyncStatus(ctx context.Context) (*model.SyncStatus, error) {
+	return m.getSyncStatus(ctx)
+}
+
+func (m *mockStorage) SaveSyncStatus(ctx context.Context, st *model.SyncStatus) error {
+	return m.saveSyncStatus(ctx, st)
+}
+
+func (m *mockStorage) TryLockSync(ctx context.Context) (bool, error) {
+	return m.tryLockSync(ctx)
+}
+
+func (m *mockStorage) NotifySync(ctx context.Context, event model.SyncEvent) error {
+	return m.notifySync(ctx, event)
+}
+
+func (m *mockStorage) ListenSync(ctx context.Context) (<-chan model.SyncEvent, error) {
+	return m.listenSync(ctx)
+}
+
+func (m *mockStorage) GetSchedules(ctx context.Context, clientID int64) ([]model.Schedule, error) {
+	return m.getSchedules(ctx, clientID)
+}
+
+func (m *mockStorage) SetSchedule(ctx context.Context, s *model.Schedule) error {
+	return m.setSchedule(ctx, s)
+}
+
+func (m *mockStorage) DeleteSchedule(ctx context.Context, clientID int64, algorithm model.AlgorithmType) error {
+	return m.deleteSchedule(ctx, clientID, algorithm)
+}
+
+func (m *mockStorage) AddScheduledChange(ctx context.Context, c *model.ScheduledChange) error {
+	return m.addScheduledChange(ctx, c)
+}
+
+func (m *mockStorage) GetScheduledChanges(ctx context.Context, clientID int64, status model.ChangeStatus) ([]model.ScheduledChange, error) {
+	return m.getScheduledChanges(ctx, clientID, status)
+}
+
+func (m *mockStorage) CancelScheduledChange(ctx context.Context, id int64) error {
+	return m.cancelScheduledChange(ctx, id)
+}
+
+func (m *mockStorage) ApplyDueChanges(ctx context.Context) ([]model.ScheduledChange, error) {
+	return m.applyDueChanges(ctx)
+}
+
+func (m *mockStorage) ReserveIdempotencyKey(ctx context.Context, rec *model.IdempotencyRecord) (*model.IdempotencyRecord, error) {
+	return m.reserveIdempotencyKey(ctx, rec)
+}
+
+func (m *mockStorage) SaveIdempotencyResponse(ctx context.Context, rec *model.IdempotencyRecord) error {
+	return m.saveIdempotency(ctx, rec)
+}
+
+func (m *mockStorage) DeleteIdempotencyKey(ctx context.Context, key string) error {
+	return m.deleteIdempotencyKey(ctx, key)
+}
+
+func (m *mockStorage) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	return m.purgeIdempotencyKeys(ctx)
+}
+
+func (m *mockStorage) AddAPIKey(ctx context.Context, key *model.APIKey, hash string) error {
+	return m.addAPIKey(ctx, key, hash)
+}
+
+func (m *mockStorage) GetAPIKeys(ctx context.Context) ([]model.APIKey, error) {
+	return m.getAPIKeys(ctx)
+}
+
+func (m *mockStorage) AuthenticateAPIKey(ctx context.Context, hash string) (*model.APIKey, error) {
+	return m.authenticateAPIKey(ctx, hash)
+}
+
+func (m *mockStorage) RevokeAPIKey(ctx context.Context, id int64) error {
+	return m.revokeAPIKey(ctx, id)
+}
+
+func (m *mockStorage) AddApproval(ctx context.Context, a *model.ApprovalRequest) error {
+	return m.addApproval(ctx, a)
+}
+
+func (m *mockStorage) GetApproval(ctx context.Context, id int64) (*model.ApprovalRequest, error) {
+	return m.getApproval(ctx, id)
+}
+
+func (m *mockStorage) GetApprovals(ctx context.Context, clientID int64, status model.ApprovalStatus) ([]model.ApprovalRequest, error) {
+	return m.getApprovals(ctx, clientID, status)
+}
+
+func (m *mockStorage) DecideApproval(ctx context.Context, id int64, status model.ApprovalStatus, decidedBy, comment string) (*model.ApprovalRequest, error) {
+	return m.decideApproval(ctx, id, status, decidedBy, comment)
+}
+
+func (m *mockStorage) GetAuditLog(ctx context.Context, f model.AuditFilter) ([]model.AuditEntry, error) {
+	return m.getAuditLog(ctx, f)
+}
+
+func (m *mockStorage) GetClientRevisions(ctx context.Context, clientID int64) ([]model.ClientRevision, error) {
+	return m.getClientRevisions(ctx, clientID)
+}
+
+func (m *mockStorage) GetClientRevision(ctx context.Context, clientID, revision int64) (*model.ClientRevision, error) {
+	return m.getClientRevision(ctx, clientID, revision)
+}
+
+func (m *mockStorage) RollbackClient(ctx context.Context, clientID, revision int64) (*model.ClientRevision, error) {
+	return m.rollbackClient(ctx, clientID, revision)
+}
+
+// problemCode - код ошибки из ответа application/problem+json
+func problemCode(t *testing.T, rr *httptest.ResponseRecorder) string {
+	t.Helper()
+	assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+	assert.NotEmpty(t, rr.Header().Get(requestIDHeader))
+	var p model.Problem
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &p))
+	assert.Equal(t, rr.Code, p.Status)
+	return p.Code
+}
+
+func validClient(name string) *model.Client {
+	return &model.Client{ID: 1, ClientName: name, Image: "algo/hft:1.0", CPU: "500m", Memory: "1Gi"}
+}
+
+func validSpec(name string) model.ClientSpec {
+	return model.ClientSpec{ClientName: name, Image: "algo/hft:1.0", CPU: "500m", Memory: "1Gi"}
+}
+
 func TestAddClient(t *testing.T) {
 	tests := []struct {
 		name           string
-		inputBody      *model.Client
+		inputBody      any
 		storageError   error
 		expectedStatus int
 		expectedBody   string
+		expectedCode   string
 	}{
 		{
-			name:           "200",
-			inputBody:      &model.Client{ClientName: "Client"},
-			expectedStatus: http.StatusOK,
+			name:           "201",
+			inputBody:      model.CreateClientRequest{ClientSpec: validSpec("client")},
+			expectedStatus: http.StatusCreated,
+			expectedBody: `{"id":5,"client_name":"client","version":0,"image":"algo/hft:1.0","cpu":"500m","memory":"1Gi",` +
+				`"priority":0,"needRestart":false,"deletion_protected":false,` +
+				`"created_at":"2026-10-01T12:00:00Z","updated_at":"2026-10-01T12:00:00Z","revision":1}` + "\n",
+		},
+		{
+			name:           "400 server field",
+			inputBody:      map[string]any{"id": 7, "client_name": "client", "created_at": "2020-01-01T00:00:00Z"},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_body",
 		},
 		{
 			name:           "400",
 			inputBody:      nil,
 			expectedStatus: http.StatusBadRequest,
-			expectedBody:   "invalid request body\n",
+			expectedCode:   "invalid_body",
+		},
+		{
+			name: "422",
+			inputBody: model.CreateClientRequest{ClientSpec: model.ClientSpec{
+				ClientName: "client", Image: "algo", CPU: "banana", Memory: "1Gi", Priority: -1,
+			}},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
 		},
 		{
 			name:           "409",
-			inputBody:      &model.Client{ClientName: "Client1"},
+			inputBody:      model.CreateClientRequest{ClientSpec: validSpec("client1")},
 			storageError:   model.ErrorClientConflict,
 			expectedStatus: http.StatusConflict,
-			expectedBody:   "client_name is already exists\n",
+			expectedCode:   "client_conflict",
 		},
 		{
 			name:           "500",
-			inputBody:      &model.Client{ClientName: "Client2"},
+			inputBody:      model.CreateClientRequest{ClientSpec: validSpec("client2")},
 			storageError:   errors.New("error"),
 			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   "internal server error\n",
+			expectedCode:   "internal",
 		},
 	}
 
@@ -80,7 +374,15 @@ func TestAddClient(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			storage := &mockStorage{
 				addClient: func(ctx context.Context, client *model.Client) error {
-					return tt.storageError
+					assert.Zero(t, client.ID)
+					if tt.storageError != nil {
+						return tt.storageError
+					}
+					client.ID = 5
+					client.CreatedAt = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+					client.UpdatedAt = client.CreatedAt
+					client.Revision = 1
+					return nil
 				},
 			}
 			handler := &Handler{storage: storage}
@@ -97,38 +399,155 @@ func TestAddClient(t *testing.T) {
 			handler.AddClient()(rr, req)
 
 			assert.Equal(t, tt.expectedStatus, rr.Code)
-			if tt.expectedBody != "" {
+			if rr.Code == http.StatusCreated {
+				assert.Equal(t, "/api/client/5", rr.Header().Get("Location"))
+				assert.Equal(t, `"1"`, rr.Header().Get("ETag"))
+			}
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+			} else if tt.expectedBody != "" {
 				assert.Equal(t, tt.expectedBody, rr.Body.String())
 			}
 		})
 	}
 }
 
+func TestGetClient(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		storageError   error
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:           "200",
+			id:             "1",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "400",
+			id:             "abc",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_id",
+		},
+		{
+			name:           "404",
+			id:             "2",
+			storageError:   model.ErrorClientNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "client_not_found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &mockStorage{
+				getClient: func(ctx context.Context, id int64) (*model.Client, error) {
+					if tt.storageError != nil {
+						return nil, tt.storageError
+					}
+					c := validClient("client")
+					c.Revision = 7
+					return c, nil
+				},
+			}
+			handler := &Handler{storage: storage}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/client/"+tt.id, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+
+			handler.GetClient()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+				return
+			}
+			var c model.ClientResponse
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &c))
+			assert.Equal(t, "client", c.ClientName)
+			assert.Equal(t, `"7"`, rr.Header().Get("ETag"))
+		})
+	}
+}
+
 func TestUpdateClient(t *testing.T) {
 	tests := []struct {
 		name           string
-		inputBody      *model.Client
+		inputBody      any
+		ifMatch        string
 		storageError   error
 		expectedStatus int
 		expectedBody   string
+		expectedCode   string
 	}{
 		{
 			name:           "200",
-			inputBody:      &model.Client{ClientName: "Client"},
+			inputBody:      model.UpdateClientRequest{ID: 1, ClientSpec: validSpec("client")},
+			ifMatch:        `"3"`,
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"id":1,"client_name":"client","version":0,"image":"algo/hft:1.0","cpu":"500m","memory":"1Gi",` +
+				`"priority":0,"needRestart":false,"deletion_protected":false,` +
+				`"created_at":"2026-10-01T12:00:00Z","updated_at":"2026-10-02T12:00:00Z","revision":4}` + "\n",
+		},
+		{
+			name:           "200 without If-Match",
+			inputBody:      model.UpdateClientRequest{ID: 1, ClientSpec: validSpec("client")},
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name:           "412",
+			inputBody:      model.UpdateClientRequest{ID: 1, ClientSpec: validSpec("client")},
+			ifMatch:        `"2"`,
+			expectedStatus: http.StatusPreconditionFailed,
+			expectedCode:   "revision_mismatch",
+		},
+		{
+			name:           "412 invalid If-Match",
+			inputBody:      model.UpdateClientRequest{ID: 1, ClientSpec: validSpec("client")},
+			ifMatch:        "3",
+			expectedStatus: http.StatusPreconditionFailed,
+			expectedCode:   "invalid_if_match",
+		},
+		{
+			name:           "400 server field",
+			inputBody:      map[string]any{"id": 1, "client_name": "client", "spawned_at": "2020-01-01T00:00:00Z"},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_body",
+		},
 		{
 			name:           "400",
 			inputBody:      nil,
 			expectedStatus: http.StatusBadRequest,
-			expectedBody:   "invalid request body\n",
+			expectedCode:   "invalid_body",
+		},
+		{
+			name:           "422",
+			inputBody:      model.UpdateClientRequest{ClientSpec: validSpec("client")},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+		},
+		{
+			name:           "404",
+			inputBody:      model.UpdateClientRequest{ID: 1, ClientSpec: validSpec("client1")},
+			storageError:   model.ErrorClientNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "client_not_found",
+		},
+		{
+			name:           "409",
+			inputBody:      model.UpdateClientRequest{ID: 1, ClientSpec: validSpec("client1")},
+			storageError:   model.ErrorClientConflict,
+			expectedStatus: http.StatusConflict,
+			expectedCode:   "client_conflict",
 		},
 		{
 			name:           "500",
-			inputBody:      &model.Client{ClientName: "Client1"},
+			inputBody:      model.UpdateClientRequest{ID: 1, ClientSpec: validSpec("client1")},
 			storageError:   errors.New("error"),
 			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   "internal server error\n",
+			expectedCode:   "internal",
 		},
 	}
 
@@ -136,7 +555,16 @@ func TestUpdateClient(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			storage := &mockStorage{
 				updateClient: func(ctx context.Context, client *model.Client) error {
-					return tt.storageError
+					if tt.storageError != nil {
+						return tt.storageError
+					}
+					if client.Revision != 0 && client.Revision != 3 {
+						return model.ErrorRevisionMismatch
+					}
+					client.CreatedAt = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+					client.UpdatedAt = client.CreatedAt.Add(24 * time.Hour)
+					client.Revision = 4
+					return nil
 				},
 			}
 			handler := &Handler{storage: storage}
@@ -149,69 +577,350 @@ func TestUpdateClient(t *testing.T) {
 			} else {
 				req = httptest.NewRequest(http.MethodPut, "/client", nil)
 			}
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
 
 			handler.UpdateClient()(rr, req)
 
 			assert.Equal(t, tt.expectedStatus, rr.Code)
-			if tt.expectedBody != "" {
+			if rr.Code == http.StatusOK {
+				assert.Equal(t, `"4"`, rr.Header().Get("ETag"))
+			}
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+			} else if tt.expectedBody != "" {
 				assert.Equal(t, tt.expectedBody, rr.Body.String())
 			}
 		})
 	}
 }
 
+func TestPatchClient(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		ifMatch        string
+		expectedStatus int
+		expectedCode   string
+		expectedPatch  bool
+	}{
+		{
+			name:           "200",
+			body:           `{"memory":"2Gi"}`,
+			expectedStatus: http.StatusOK,
+			expectedPatch:  true,
+		},
+		{
+			name:           "200 If-Match",
+			body:           `{"memory":"2Gi"}`,
+			ifMatch:        `"3"`,
+			expectedStatus: http.StatusOK,
+			expectedPatch:  true,
+		},
+		{
+			name:           "412",
+			body:           `{"memory":"2Gi"}`,
+			ifMatch:        `"2"`,
+			expectedStatus: http.StatusPreconditionFailed,
+			expectedCode:   "revision_mismatch",
+		},
+		{
+			name:           "400 unknown field",
+			body:           `{"revision":5}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_body",
+		},
+		{
+			name:           "422 null",
+			body:           `{"image":null}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+		},
+		{
+			name:           "422 merged",
+			body:           `{"cpu":"banana"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patched *model.ClientPatch
+			storage := &mockStorage{
+				getClient: func(ctx context.Context, id int64) (*model.Client, error) {
+					c := validClient("client")
+					c.Revision = 3
+					return c, nil
+				},
+				patchClient: func(ctx context.Context, id, revision int64, patch *model.ClientPatch) (*model.Client, error) {
+					patched = patch
+					c := validClient("client")
+					patch.Apply(c)
+					c.Revision = 4
+					return c, nil
+				},
+			}
+			handler := &Handler{storage: storage}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPatch, "/api/client/1", bytes.NewBufferString(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+
+			handler.PatchClient()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedPatch, patched != nil)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+				return
+			}
+			assert.Nil(t, patched.Image)
+			var c model.ClientResponse
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &c))
+			assert.Equal(t, "2Gi", c.Memory)
+			assert.Equal(t, "algo/hft:1.0", c.Image)
+			assert.Equal(t, `"4"`, rr.Header().Get("ETag"))
+		})
+	}
+}
+
 func TestDeleteClient(t *testing.T) {
 	tests := []struct {
 		name           string
-		inputBody      *model.Client
+		id             string
 		storageError   error
 		expectedStatus int
-		expectedBody   string
+		expectedCode   string
+		expectedSync   int
 	}{
 		{
 			name:           "200",
-			inputBody:      &model.Client{ClientName: "Client"},
+			id:             "1",
 			expectedStatus: http.StatusOK,
+			expectedSync:   1,
 		},
 		{
-			name:           "400",
-			inputBody:      nil,
+			name:           "invalid id",
+			id:             "abc",
 			expectedStatus: http.StatusBadRequest,
-			expectedBody:   "invalid request body\n",
+			expectedCode:   "invalid_id",
+		},
+		{
+			name:           "protected",
+			id:             "1",
+			storageError:   model.ErrorDeletionProtected,
+			expectedStatus: http.StatusConflict,
+			expectedCode:   "deletion_protected",
 		},
 		{
 			name:           "500",
-			inputBody:      &model.Client{ClientName: "Client1"},
+			id:             "1",
 			storageError:   errors.New("error"),
 			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   "internal server error\n",
+			expectedCode:   "internal",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			var deleted int64
 			storage := &mockStorage{
-				deleteClient: func(ctx context.Context, client *model.Client) error {
+				deleteClient: func(ctx context.Context, id int64) error {
+					deleted = id
 					return tt.storageError
 				},
 			}
+			sync := &mockSyncer{}
+			handler := &Handler{storage: storage, sync: sync, logger: discardLogger()}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodDelete, "/api/client/"+tt.id, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+
+			handler.DeleteClient()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedSync, sync.calls)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+				return
+			}
+			assert.Equal(t, int64(1), deleted)
+		})
+	}
+}
+
+func TestRestoreClient(t *testing.T) {
+	tests := []struct {
+		name           string
+		storageError   error
+		expectedStatus int
+		expectedCode   string
+		expectedSync   int
+	}{
+		{
+			name:           "200",
+			expectedStatus: http.StatusOK,
+			expectedSync:   1,
+		},
+		{
+			name:           "not deleted or purged",
+			storageError:   model.ErrorClientNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "client_not_found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &mockStorage{
+				restoreClient: func(ctx context.Context, id int64) (*model.Client, error) {
+					if tt.storageError != nil {
+						return nil, tt.storageError
+					}
+					return &model.Client{ID: id, ClientName: "Client", Revision: 3}, nil
+				},
+			}
+			sync := &mockSyncer{}
+			handler := &Handler{storage: storage, sync: sync, logger: discardLogger()}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/client/1/restore", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+			handler.RestoreClient()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedSync, sync.calls)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+				return
+			}
+			assert.Equal(t, `"3"`, rr.Header().Get("ETag"))
+		})
+	}
+}
+
+func TestSetDeletionProtection(t *testing.T) {
+	tests := []struct {
+		name              string
+		body              string
+		expectedStatus    int
+		expectedCode      string
+		expectedProtected bool
+	}{
+		{
+			name:              "protect",
+			body:              `{"protected": true}`,
+			expectedStatus:    http.StatusOK,
+			expectedProtected: true,
+		},
+		{
+			name:           "clear",
+			body:           `{"protected": false}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unknown field",
+			body:           `{"enabled": true}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_body",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &mockStorage{
+				setDeletionProtection: func(ctx context.Context, id int64, protected bool) (*model.Client, error) {
+					return &model.Client{ID: id, DeletionProtected: protected, Revision: 2}, nil
+				},
+			}
 			handler := &Handler{storage: storage}
 			rr := httptest.NewRecorder()
-			var req *http.Request
-			if tt.inputBody != nil {
-				body, err := json.Marshal(tt.inputBody)
-				assert.NoError(t, err)
-				req = httptest.NewRequest(http.MethodDelete, "/client", bytes.NewBuffer(body))
-			} else {
-				req = httptest.NewRequest(http.MethodDelete, "/client", nil)
+			req := httptest.NewRequest(http.MethodPut, "/api/client/1/protection", bytes.NewBufferString(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+			handler.SetDeletionProtection()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+				return
 			}
+			var resp model.ClientResponse
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+			assert.Equal(t, tt.expectedProtected, resp.DeletionProtected)
+		})
+	}
+}
 
-			handler.DeleteClient()(rr, req)
+func TestSetClientSuspension(t *testing.T) {
+	tests := []struct {
+		name            string
+		id              string
+		inputBody       string
+		storageError    error
+		expectedStatus  int
+		expectedBody    string
+		expectedCode    string
+		expectedTrigger int
+	}{
+		{
+			name:           "200 suspend",
+			id:             "1",
+			inputBody:      `{"suspended":true,"reason":"manual debug"}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"client_id":1,"suspended":true,"reason":"manual debug"}` + "\n",
+		},
+		{
+			name:            "200 resume",
+			id:              "1",
+			inputBody:       `{"suspended":false,"reason":"ignored"}`,
+			expectedStatus:  http.StatusOK,
+			expectedBody:    `{"client_id":1,"suspended":false}` + "\n",
+			expectedTrigger: 1,
+		},
+		{
+			name:           "422 until",
+			id:             "1",
+			inputBody:      `{"suspended":true,"until":"2000-01-01T00:00:00Z"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+		},
+		{
+			name:           "404",
+			id:             "2",
+			inputBody:      `{"suspended":true}`,
+			storageError:   model.ErrorClientNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "client_not_found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &mockStorage{
+				setClientSuspension: func(ctx context.Context, s *model.Suspension) error {
+					return tt.storageError
+				},
+			}
+			syncer := &mockSyncer{}
+			handler := &Handler{storage: storage, sync: syncer}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "/api/client/"+tt.id+"/suspension", bytes.NewBufferString(tt.inputBody))
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+
+			handler.SetClientSuspension()(rr, req)
 
 			assert.Equal(t, tt.expectedStatus, rr.Code)
-			if tt.expectedBody != "" {
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+			} else {
 				assert.Equal(t, tt.expectedBody, rr.Body.String())
 			}
+			assert.Equal(t, tt.expectedTrigger, syncer.calls)
 		})
 	}
 }
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// requestIDHeader - заголовок с id запроса
+const requestIDHeader = "X-Request-ID"
+
+// statuses - HTTP статусы категорий ошибок
+var statuses = map[model.ErrorKind]int{
+	model.KindBadRequest:   http.StatusBadRequest,
+	model.KindTooLarge:     http.StatusRequestEntityTooLarge,
+	model.KindUnauthorized: http.StatusUnauthorized,
+	model.KindForbidden:    http.StatusForbidden,
+	model.KindNotFound:     http.StatusNotFound,
+	model.KindMethod:       http.StatusMethodNotAllowed,
+	model.KindConflict:     http.StatusConflict,
+	model.KindValidation:   http.StatusUnprocessableEntity,
+	model.KindPrecondition: http.StatusPreconditionFailed,
+	model.KindUnavailable:  http.StatusServiceUnavailable,
+	model.KindInternal:     http.StatusInternalServerError,
+}
+
+// writeError - ответ об ошибке в формате application/problem+json.
+// Ошибки без категории отдаются как internal без подробностей, недоступность БД - как unavailable.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	p := model.Problem{RequestID: requestID(w, r)}
+	var (
+		e *model.Error
+		v *model.ValidationError
+	)
+	switch {
+	case errors.As(err, &v):
+		p.Status = statuses[model.KindValidation]
+		p.Code = "validation_failed"
+		p.Detail = "request validation failed"
+		p.Errors = v.Fields
+	case errors.As(err, &e):
+		p.Status = statuses[e.Kind]
+		p.Code = e.Code
+		p.Detail = e.Message
+	case isUnavailable(err):
+		p.Status = statuses[model.KindUnavailable]
+		p.Code = model.ErrorUnavailable.Code
+		p.Detail = model.ErrorUnavailable.Message
+	default:
+		p.Status = statuses[model.KindInternal]
+		p.Code = model.ErrorInternal.Code
+		p.Detail = model.ErrorInternal.Message
+	}
+	if p.Status == 0 {
+		p.Status = http.StatusInternalServerError
+	}
+	p.Type = "urn:syncservice:error:" + p.Code
+	p.Title = http.StatusText(p.Status)
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// isUnavailable - ошибка связи с БД или истёкший таймаут
+func isUnavailable(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr)
+}
+
+// requestID - id запроса из заголовка X-Request-ID, либо новый. Id возвращается клиенту в том же заголовке.
+// Id длиннее maxRequestID или с символами кроме букв, цифр и -_.: заменяется новым, чтобы не попасть в логи как есть.
+func requestID(w http.ResponseWriter, r *http.Request) string {
+	id := w.Header().Get(requestIDHeader)
+	if id == "" && validRequestID(r.Header.Get(requestIDHeader)) {
+		id = r.Header.Get(requestIDHeader)
+	}
+	if id == "" {
+		b := make([]byte, 16)
+		rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+	w.Header().Set(requestIDHeader, id)
+	return id
+}
+
+// maxRequestID - наибольшая длина X-Request-ID из запроса
+const maxRequestID = 128
+
+// validRequestID - можно ли принять X-Request-ID из запроса
+func validRequestID(id string) bool {
+	if len(id) > maxRequestID {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', strings.ContainsRune("-_.:", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"not found", model.ErrorClientNotFound, http.StatusNotFound, "client_not_found"},
+		{"wrapped", fmt.Errorf("update: %w", model.ErrorClientConflict), http.StatusConflict, "client_conflict"},
+		{"validation", &model.ValidationError{Fields: []model.FieldError{{Field: "cpu", Reason: "bad"}}}, http.StatusUnprocessableEntity, "validation_failed"},
+		{"bad conn", driver.ErrBadConn, http.StatusServiceUnavailable, "unavailable"},
+		{"deadline", context.DeadlineExceeded, http.StatusServiceUnavailable, "unavailable"},
+		{"unknown", errors.New("pq: secret details"), http.StatusInternalServerError, "internal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+			writeError(rr, req, tt.err)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+			assert.NotContains(t, rr.Body.String(), "secret")
+		})
+	}
+}
+
+func TestWriteError_RequestID(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(requestIDHeader, "abc-123")
+
+	writeError(rr, req, model.ErrorInvalidBody)
+
+	assert.Equal(t, "abc-123", rr.Header().Get(requestIDHeader))
+	assert.Contains(t, rr.Body.String(), `"request_id":"abc-123"`)
+}
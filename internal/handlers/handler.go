@@ -1,33 +1,189 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"github.com/CyrilSbrodov/syncService/cmd/loggers"
 	"github.com/CyrilSbrodov/syncService/internal/config"
+	"github.com/CyrilSbrodov/syncService/internal/model"
 	"github.com/CyrilSbrodov/syncService/internal/storage"
 	"github.com/gorilla/mux"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
 )
 
 type Handlers interface {
 	Register(router *mux.Router)
 }
 
+// Syncer - интерфейс управления синкером из API
+type Syncer interface {
+	Trigger(ctx context.Context)
+	Kill(ctx context.Context, halt *model.Halt) ([]string, []string, error)
+	Observe(ctx context.Context, as model.AlgorithmStatus) ([]model.PodState, error)
+	Leader() bool
+}
+
 type Handler struct {
 	cfg     *config.Config
 	logger  *loggers.Logger
 	storage storage.Storage
+	sync    Syncer
+	tokens  TokenVerifier
+	// approvals - политика подтверждения включения алгоритмов
+	approvals model.ApprovalPolicy
+	// checks - проверки зависимостей для /readyz, draining - реплика останавливается
+	checks   []Check
+	draining atomic.Bool
 }
 
-func NewHandler(cfg *config.Config, logger *loggers.Logger, storage storage.Storage) *Handler {
+// NewHandler - конструктор ручек, tokens - проверка JWT, nil - принимаются только ключи API
+func NewHandler(cfg *config.Config, logger *loggers.Logger, storage storage.Storage, sync Syncer,
+	tokens TokenVerifier, approvals model.ApprovalPolicy) *Handler {
 	return &Handler{
-		cfg:     cfg,
-		logger:  logger,
-		storage: storage,
+		cfg:       cfg,
+		logger:    logger,
+		storage:   storage,
+		sync:      sync,
+		tokens:    tokens,
+		approvals: approvals,
 	}
 }
 
+// Register - регистрация ручек с ролью, необходимой для вызова. Ручки allowGlobal действуют на всех клиентов
+// и недоступны вызывающим с ограниченной областью видимости.
+// Ненайденный маршрут и неподходящий метод mux обрабатывает без Use, поэтому они оборачиваются той же цепочкой сами.
 func (h *Handler) Register(r *mux.Router) {
-	r.HandleFunc("/api/client", h.AddClient()).Methods("POST")
-	r.HandleFunc("/api/client", h.UpdateClient()).Methods("PUT")
-	r.HandleFunc("/api/client/{id}", h.DeleteClient()).Methods("DELETE")
-	r.HandleFunc("/api/algorithms", h.UpdateAlgorithmStatus()).Methods("POST")
+	r.Use(h.Trace, h.RequestLog, h.Metrics, h.Recover, h.LimitBody, h.Authenticate, h.Idempotency)
+	r.NotFoundHandler = h.unmatched(model.ErrorRouteNotFound)
+	r.MethodNotAllowedHandler = h.unmatched(model.ErrorMethodNotAllowed)
+	r.HandleFunc("/api/client", h.allow(model.RoleOperator, h.AddClient())).Methods("POST")
+	r.HandleFunc("/api/client", h.allow(model.RoleOperator, h.UpdateClient())).Methods("PUT")
+	r.HandleFunc("/api/client/{id}", h.allow(model.RoleViewer, h.GetClient())).Methods("GET")
+	r.HandleFunc("/api/client/{id}", h.allow(model.RoleOperator, h.PatchClient())).Methods("PATCH")
+	r.HandleFunc("/api/client/{id}", h.allow(model.RoleOperator, h.DeleteClient())).Methods("DELETE")
+	r.HandleFunc("/api/client/{id}/restore", h.allow(model.RoleOperator, h.RestoreClient())).Methods("POST")
+	r.HandleFunc("/api/client/{id}/protection", h.allow(model.RoleOperator, h.SetDeletionProtection())).Methods("PUT")
+	r.HandleFunc("/api/client/{id}/revisions", h.allow(model.RoleViewer, h.GetClientRevisions())).Methods("GET")
+	r.HandleFunc("/api/client/{id}/rollback", h.allow(model.RoleOperator, h.RollbackClient())).Methods("POST")
+	r.HandleFunc("/api/client/{id}/algorithms", h.allow(model.RoleViewer, h.GetClientAlgorithms())).Methods("GET")
+	r.HandleFunc("/api/client/{id}/algorithms", h.allow(model.RoleOperator, h.PatchAlgorithmStatus())).Methods("PATCH")
+	r.HandleFunc("/api/client/{id}/algorithms/{type}", h.allow(model.RoleOperator, h.EnableAlgorithm())).Methods("PUT")
+	r.HandleFunc("/api/client/{id}/algorithms/{type}", h.allow(model.RoleOperator, h.DisableAlgorithm())).Methods("DELETE")
+	r.HandleFunc("/api/client/{id}/suspension", h.allow(model.RoleOperator, h.SetClientSuspension())).Methods("PUT")
+	r.HandleFunc("/api/client/{id}/schedules", h.allow(model.RoleViewer, h.GetClientSchedules())).Methods("GET")
+	r.HandleFunc("/api/client/{id}/schedule", h.allow(model.RoleOperator, h.SetSchedule())).Methods("PUT")
+	r.HandleFunc("/api/client/{id}/schedule", h.allow(model.RoleOperator, h.DeleteSchedule())).Methods("DELETE")
+	r.HandleFunc("/api/schedules", h.allow(model.RoleViewer, h.GetSchedules())).Methods("GET")
+	r.HandleFunc("/api/scheduled-changes", h.allow(model.RoleOperator, h.AddScheduledChange())).Methods("POST")
+	r.HandleFunc("/api/scheduled-changes", h.allow(model.RoleViewer, h.GetScheduledChanges())).Methods("GET")
+	r.HandleFunc("/api/scheduled-changes/{id}", h.allow(model.RoleOperator, h.CancelScheduledChange())).Methods("DELETE")
+	r.HandleFunc("/api/algorithms", h.allow(model.RoleOperator, h.UpdateAlgorithmStatus())).Methods("POST")
+	r.HandleFunc("/api/algorithms/bulk", h.allow(model.RoleOperator, h.BulkUpdateAlgorithms())).Methods("POST")
+	r.HandleFunc("/api/approvals", h.allow(model.RoleViewer, h.GetApprovals())).Methods("GET")
+	r.HandleFunc("/api/approvals/{id}", h.allow(model.RoleViewer, h.GetApproval())).Methods("GET")
+	r.HandleFunc("/api/approvals/{id}/approve", h.allow(h.approvals.ApproverRole, h.ApproveChange())).Methods("POST")
+	r.HandleFunc("/api/approvals/{id}/reject", h.allow(h.approvals.ApproverRole, h.RejectChange())).Methods("POST")
+	r.HandleFunc("/api/audit", h.allow(model.RoleViewer, h.GetAuditLog())).Methods("GET")
+	r.HandleFunc("/api/sync/status", h.allowGlobal(model.RoleViewer, h.GetSyncStatus())).Methods("GET")
+	r.HandleFunc("/api/sync/freeze", h.allow(model.RoleViewer, h.GetFreeze())).Methods("GET")
+	r.HandleFunc("/api/sync/freeze", h.allowGlobal(model.RoleOperator, h.SetFreeze())).Methods("PUT")
+	r.HandleFunc("/api/sync/guard", h.allowGlobal(model.RoleViewer, h.GetSyncGuard())).Methods("GET")
+	r.HandleFunc("/api/sync/guard/confirm", h.allowGlobal(model.RoleAdmin, h.ConfirmSyncGuard())).Methods("POST")
+	r.HandleFunc("/api/killswitch", h.allow(model.RoleOperator, h.ActivateKillSwitch())).Methods("POST")
+	r.HandleFunc("/api/killswitch", h.allow(model.RoleViewer, h.GetHalts())).Methods("GET")
+	r.HandleFunc("/api/killswitch/{id}/resume", h.allow(model.RoleAdmin, h.ResumeHalt())).Methods("POST")
+	r.HandleFunc("/api/admin/keys", h.allowGlobal(model.RoleAdmin, h.GetAPIKeys())).Methods("GET")
+	r.HandleFunc("/api/admin/keys", h.allowGlobal(model.RoleAdmin, h.CreateAPIKey())).Methods("POST")
+	r.HandleFunc("/api/admin/keys/{id}", h.allowGlobal(model.RoleAdmin, h.RevokeAPIKey())).Methods("DELETE")
+	r.HandleFunc("/api/admin/log-level", h.allowGlobal(model.RoleAdmin, h.GetLogLevel())).Methods("GET")
+	r.HandleFunc("/api/admin/log-level", h.allowGlobal(model.RoleAdmin, h.SetLogLevel())).Methods("PUT")
+}
+
+// unmatched - ответ на запрос без маршрута через middleware запроса: id, лог доступа, метрики и восстановление после паники.
+// Аутентификация не нужна, ответ не раскрывает ничего, кроме отсутствия маршрута.
+func (h *Handler) unmatched(err error) http.Handler {
+	var next http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, err)
+	})
+	for _, mw := range []mux.MiddlewareFunc{h.Recover, h.Metrics, h.RequestLog, h.Trace} {
+		next = mw(next)
+	}
+	return next
+}
+
+// triggerSync - внеплановый запуск синхронизации, если синкер подключен. Span запроса из ctx связывается с проходом.
+func (h *Handler) triggerSync(ctx context.Context) {
+	if h.sync != nil {
+		h.sync.Trigger(ctx)
+	}
+}
+
+// decodeBody - чтение JSON тела запроса, неизвестные поля запрещены. Тело больше maxBody - ErrorBodyTooLarge.
+func decodeBody(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return invalidBody(err)
+	}
+	return nil
+}
+
+// decodeOptionalBody - decodeBody для необязательного тела: пустое тело оставляет v без изменений
+func decodeOptionalBody(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return invalidBody(err)
+	}
+	return nil
+}
+
+// decodeMergePatch - чтение тела JSON Merge Patch (RFC 7396) в структуру с полями-указателями.
+// Поля ресурсов не удаляются, поэтому null вместо значения - ошибка валидации.
+func decodeMergePatch(r *http.Request, v any) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return invalidBody(err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return invalidBody(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return invalidBody(err)
+	}
+	names := make([]string, 0, len(fields))
+	for name, raw := range fields {
+		if string(raw) == "null" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var verr model.ValidationError
+	for _, name := range names {
+		verr.Add(name, "must not be null")
+	}
+	return verr.Err()
+}
+
+// invalidBody - ошибка разбора тела запроса с причиной
+func invalidBody(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return model.ErrorBodyTooLarge
+	}
+	return model.NewError(model.KindBadRequest, model.ErrorInvalidBody.Code, model.ErrorInvalidBody.Message+": "+err.Error())
+}
+
+// pathID - id из пути запроса
+func pathID(r *http.Request) (int64, error) {
+	return strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 }
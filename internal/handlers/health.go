@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Check - проверка зависимости для /readyz, ошибка Run делает реплику неготовой
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// AddCheck - добавление проверки зависимости для /readyz
+func (h *Handler) AddCheck(name string, run func(ctx context.Context) error) {
+	h.checks = append(h.checks, Check{Name: name, Run: run})
+}
+
+// Drain - реплика останавливается: /readyz отвечает 503, чтобы балансировщик перестал присылать запросы
+func (h *Handler) Drain() {
+	h.draining.Store(true)
+}
+
+// Healthz - ручка проверки живости процесса, зависимости не проверяются
+func (h *Handler) Healthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	}
+}
+
+// Readyz - ручка готовности реплики. Проверки зависимостей выполняются параллельно с таймаутом
+// cfg.Health.CheckTimeout, 503 - реплика останавливается или хотя бы одна проверка не прошла.
+// Состояние синхронизации только сообщается и на готовность не влияет.
+func (h *Handler) Readyz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ready := model.Readiness{Ready: true, Draining: h.draining.Load(), Checks: map[string]model.CheckResult{}}
+		if ready.Draining {
+			ready.Ready = false
+		} else {
+			ready.Checks = h.runChecks(r.Context())
+			for _, c := range ready.Checks {
+				if c.Status != model.CheckOK {
+					ready.Ready = false
+				}
+			}
+		}
+		ready.Sync = h.syncReadiness(r.Context())
+
+		status := http.StatusOK
+		if !ready.Ready {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(ready)
+	}
+}
+
+// runChecks - параллельное выполнение проверок зависимостей
+func (h *Handler) runChecks(ctx context.Context) map[string]model.CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.Health.CheckTimeout)
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]model.CheckResult, len(h.checks))
+	)
+	for _, c := range h.checks {
+		wg.Add(1)
+		go func(c Check) {
+			defer wg.Done()
+			start := time.Now()
+			err := c.Run(ctx)
+			res := model.CheckResult{Status: model.CheckOK, LatencyMS: time.Since(start).Milliseconds()}
+			if err != nil {
+				res.Status = model.CheckFail
+				res.Error = err.Error()
+			}
+			mu.Lock()
+			results[c.Name] = res
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+	return results
+}
+
+// syncReadiness - ведущая ли реплика и итог последнего прохода из БД. Ошибка чтения итога уже видна в проверках.
+func (h *Handler) syncReadiness(ctx context.Context) model.SyncReadiness {
+	var sr model.SyncReadiness
+	if h.sync != nil {
+		sr.Leader = h.sync.Leader()
+	}
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.Health.CheckTimeout)
+	defer cancel()
+	if st, err := h.storage.GetSyncStatus(ctx); err == nil {
+		sr.LastResult = st.Result
+		sr.LastSuccessAt = st.LastSuccessAt
+	}
+	return sr
+}
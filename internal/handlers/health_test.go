@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CyrilSbrodov/syncService/internal/config"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthz(t *testing.T) {
+	h := &Handler{}
+	rr := httptest.NewRecorder()
+
+	h.Healthz()(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, rr.Body.String())
+}
+
+func TestReadyz(t *testing.T) {
+	lastSuccess := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		kubernetesErr  error
+		draining       bool
+		follower       bool
+		expectedStatus int
+		expectedChecks map[string]model.CheckStatus
+	}{
+		{
+			name:           "ready",
+			expectedStatus: http.StatusOK,
+			expectedChecks: map[string]model.CheckStatus{"database": model.CheckOK, "kubernetes": model.CheckOK},
+		},
+		{
+			name:           "follower is ready",
+			follower:       true,
+			expectedStatus: http.StatusOK,
+			expectedChecks: map[string]model.CheckStatus{"database": model.CheckOK, "kubernetes": model.CheckOK},
+		},
+		{
+			name:           "kubernetes unreachable",
+			kubernetesErr:  errors.New("connection refused"),
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedChecks: map[string]model.CheckStatus{"database": model.CheckOK, "kubernetes": model.CheckFail},
+		},
+		{
+			name:           "draining",
+			draining:       true,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedChecks: map[string]model.CheckStatus{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Health.CheckTimeout = time.Second
+			storage := &mockStorage{
+				getSyncStatus: func(ctx context.Context) (*model.SyncStatus, error) {
+					return &model.SyncStatus{Result: model.SyncFailed, LastSuccessAt: &lastSuccess}, nil
+				},
+			}
+			h := &Handler{cfg: cfg, storage: storage, sync: &mockSyncer{follower: tt.follower}}
+			h.AddCheck("database", func(ctx context.Context) error { return nil })
+			h.AddCheck("kubernetes", func(ctx context.Context) error { return tt.kubernetesErr })
+			if tt.draining {
+				h.Drain()
+			}
+			rr := httptest.NewRecorder()
+
+			h.Readyz()(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			var ready model.Readiness
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &ready))
+			assert.Equal(t, tt.expectedStatus == http.StatusOK, ready.Ready)
+			assert.Equal(t, tt.draining, ready.Draining)
+			checks := make(map[string]model.CheckStatus)
+			for name, c := range ready.Checks {
+				checks[name] = c.Status
+			}
+			assert.Equal(t, tt.expectedChecks, checks)
+			if tt.kubernetesErr != nil {
+				assert.Equal(t, "connection refused", ready.Checks["kubernetes"].Error)
+			}
+			assert.Equal(t, !tt.follower, ready.Sync.Leader)
+			assert.Equal(t, model.SyncFailed, ready.Sync.LastResult)
+			require.NotNil(t, ready.Sync.LastSuccessAt)
+			assert.True(t, lastSuccess.Equal(*ready.Sync.LastSuccessAt))
+		})
+	}
+}
+
+func TestReadyz_CheckTimeout(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Health.CheckTimeout = 10 * time.Millisecond
+	storage := &mockStorage{
+		getSyncStatus: func(ctx context.Context) (*model.SyncStatus, error) {
+			return nil, model.ErrorNoSyncStatus
+		},
+	}
+	h := &Handler{cfg: cfg, storage: storage}
+	h.AddCheck("database", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	rr := httptest.NewRecorder()
+
+	h.Readyz()(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	var ready model.Readiness
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &ready))
+	assert.Equal(t, "context deadline exceeded", ready.Checks["database"].Error)
+	assert.Nil(t, ready.Sync.LastSuccessAt)
+}
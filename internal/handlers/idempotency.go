@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	// replayedHeader - признак ответа, повторённого по ключу идемпотентности
+	replayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKey - длина колонки idempotency_keys.key
+	maxIdempotencyKey = 255
+)
+
+// savedHeaders - заголовки ответа, которые сохраняются и повторяются вместе с телом
+var savedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// Idempotency - middleware для запросов на изменение с заголовком Idempotency-Key.
+// Первый запрос выполняется и его ответ сохраняется в БД на cfg.Idempotency.TTL. Повтор с тем же ключом и телом
+// получает сохранённый ответ без выполнения, тот же ключ с другим запросом отклоняется.
+// Ответы 5xx не сохраняются - такой запрос можно повторить с тем же ключом.
+func (h *Handler) Idempotency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" || !mutating(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			writeError(w, r, model.ErrorInvalidIdempotencyKey)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, r, invalidBody(err))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		rec := &model.IdempotencyRecord{
+			Key:         key,
+			RequestHash: requestHash(r, body),
+			ExpiresAt:   time.Now().Add(h.cfg.Idempotency.TTL),
+		}
+		saved, err := h.storage.ReserveIdempotencyKey(r.Context(), rec)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if saved != nil {
+			switch {
+			case saved.RequestHash != rec.RequestHash:
+				writeError(w, r, model.ErrorIdempotencyKeyReused)
+			case saved.Status == 0:
+				writeError(w, r, model.ErrorIdempotencyInProgress)
+			default:
+				replay(w, saved)
+			}
+			return
+		}
+
+		cw := &captureWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			// паника в ручке: ключ освобождается, чтобы запрос можно было повторить, паника идёт в Recover
+			if v := recover(); v != nil {
+				h.storage.DeleteIdempotencyKey(context.WithoutCancel(r.Context()), key)
+				panic(v)
+			}
+		}()
+		next.ServeHTTP(cw, r)
+
+		// ответ уже отправлен, сохранение не должно зависеть от того, дождался ли его клиент
+		ctx := context.WithoutCancel(r.Context())
+		if cw.status >= http.StatusInternalServerError {
+			h.storage.DeleteIdempotencyKey(ctx, key)
+			return
+		}
+		rec.Status, rec.Body, rec.Headers = cw.status, cw.body.Bytes(), make(map[string]string)
+		for _, name := range savedHeaders {
+			if v := cw.Header().Get(name); v != "" {
+				rec.Headers[name] = v
+			}
+		}
+		if err := h.storage.SaveIdempotencyResponse(ctx, rec); err != nil {
+			h.storage.DeleteIdempotencyKey(ctx, key)
+		}
+	})
+}
+
+// replay - повтор сохранённого ответа
+func replay(w http.ResponseWriter, rec *model.IdempotencyRecord) {
+	for name, v := range rec.Headers {
+		w.Header().Set(name, v)
+	}
+	w.Header().Set(replayedHeader, "true")
+	w.WriteHeader(rec.Status)
+	w.Write(rec.Body)
+}
+
+// requestHash - отпечаток запроса: вызывающий, метод, путь с параметрами и тело.
+// Ответ на чужой запрос с тем же ключом не повторяется.
+func requestHash(r *http.Request, body []byte) string {
+	var subject string
+	if p := principal(r); p != nil {
+		subject = p.Subject
+	}
+	sum := sha256.New()
+	sum.Write([]byte(subject + "\n" + r.Method + " " + r.URL.RequestURI() + "\n"))
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// mutating - меняет ли запрос состояние
+func mutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// captureWriter - ResponseWriter, который запоминает статус и тело ответа
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (c *captureWriter) WriteHeader(status int) {
+	c.status = status
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
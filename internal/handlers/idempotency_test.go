@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CyrilSbrodov/syncService/internal/config"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// idempotencyStorage - mockStorage с ключами идемпотентности в памяти
+func idempotencyStorage(keys map[string]*model.IdempotencyRecord) *mockStorage {
+	return &mockStorage{
+		reserveIdempotencyKey: func(ctx context.Context, rec *model.IdempotencyRecord) (*model.IdempotencyRecord, error) {
+			if saved, ok := keys[rec.Key]; ok {
+				return saved, nil
+			}
+			keys[rec.Key] = &model.IdempotencyRecord{Key: rec.Key, RequestHash: rec.RequestHash}
+			return nil, nil
+		},
+		saveIdempotency: func(ctx context.Context, rec *model.IdempotencyRecord) error {
+			saved := *rec
+			keys[rec.Key] = &saved
+			return nil
+		},
+		deleteIdempotencyKey: func(ctx context.Context, key string) error {
+			delete(keys, key)
+			return nil
+		},
+	}
+}
+
+func TestIdempotency(t *testing.T) {
+	keys := make(map[string]*model.IdempotencyRecord)
+	calls := 0
+	status := http.StatusCreated
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/api/client/7")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"id":7}`))
+	})
+	cfg := &config.Config{}
+	cfg.Idempotency.TTL = time.Hour
+	h := &Handler{cfg: cfg, storage: idempotencyStorage(keys)}
+	mw := h.Idempotency(next)
+
+	do := func(key, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/client", strings.NewReader(body))
+		req.Header.Set(idempotencyKeyHeader, key)
+		mw.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("first request", func(t *testing.T) {
+		rr := do("k1", `{"client_name":"a"}`)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Equal(t, 1, calls)
+		assert.Empty(t, rr.Header().Get(replayedHeader))
+		assert.Equal(t, http.StatusCreated, keys["k1"].Status)
+		assert.Equal(t, "/api/client/7", keys["k1"].Headers["Location"])
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		rr := do("k1", `{"client_name":"a"}`)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, "true", rr.Header().Get(replayedHeader))
+		assert.Equal(t, "/api/client/7", rr.Header().Get("Location"))
+		assert.Equal(t, `{"id":7}`, rr.Body.String())
+	})
+
+	t.Run("key reused with another body", func(t *testing.T) {
+		rr := do("k1", `{"client_name":"b"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		assert.Equal(t, "idempotency_key_reused", problemCode(t, rr))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("in progress", func(t *testing.T) {
+		keys["k2"] = &model.IdempotencyRecord{Key: "k2", RequestHash: keys["k1"].RequestHash}
+		rr := do("k2", `{"client_name":"a"}`)
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		assert.Equal(t, "idempotency_in_progress", problemCode(t, rr))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("5xx releases key", func(t *testing.T) {
+		status = http.StatusServiceUnavailable
+		rr := do("k3", `{}`)
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.NotContains(t, keys, "k3")
+
+		status = http.StatusCreated
+		rr = do("k3", `{}`)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		rr := do(strings.Repeat("k", maxIdempotencyKey+1), `{}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, "invalid_idempotency_key", problemCode(t, rr))
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("without key", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		mw.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/client", strings.NewReader(`{}`)))
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Equal(t, 4, calls)
+	})
+}
+
+func TestIdempotency_Panic(t *testing.T) {
+	keys := make(map[string]*model.IdempotencyRecord)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+	cfg := &config.Config{}
+	cfg.Idempotency.TTL = time.Hour
+	h := &Handler{cfg: cfg, storage: idempotencyStorage(keys), logger: discardLogger()}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/client", strings.NewReader(`{}`))
+	req.Header.Set(idempotencyKeyHeader, "k1")
+	h.Recover(h.Idempotency(next)).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.NotContains(t, keys, "k1")
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"net/http"
+	"strconv"
+)
+
+// ActivateKillSwitch - ручка экстренной остановки алгоритмов.
+// Остановка сохраняется в БД до явного возобновления, pod'ы удаляются сразу.
+func (h *Handler) ActivateKillSwitch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var halt model.Halt
+		if err := decodeBody(r, &halt); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		halt.TriggeredBy = actor(r, halt.TriggeredBy)
+		if err := validateHalt(&halt); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		halt.ResumedAt, halt.ResumedBy = nil, ""
+		if err := h.storage.AddHalt(r.Context(), &halt); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		deleted, failed, err := h.sync.Kill(r.Context(), &halt)
+		if err != nil {
+			h.triggerSync(r.Context())
+			writeError(w, r, model.ErrorPodsNotDeleted)
+			return
+		}
+		if deleted == nil {
+			deleted = []string{}
+		}
+		if failed == nil {
+			failed = []string{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(model.KillSwitchResult{Halt: halt, DeletedPods: deleted, FailedPods: failed})
+	}
+}
+
+// GetHalts - ручка получения остановок, ?all=true - вместе с возобновлёнными
+func (h *Handler) GetHalts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		all, _ := strconv.ParseBool(r.URL.Query().Get("all"))
+		halts, err := h.storage.GetHalts(r.Context(), !all)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if halts == nil {
+			halts = []model.Halt{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(halts)
+	}
+}
+
+// ResumeHalt - ручка снятия остановки. Тело необязательно, если вызывающий аутентифицирован.
+func (h *Handler) ResumeHalt() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeError(w, r, model.ErrorInvalidID)
+			return
+		}
+		var req struct {
+			ResumedBy string `json:"resumed_by"`
+		}
+		if err := decodeOptionalBody(r, &req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		req.ResumedBy = actor(r, req.ResumedBy)
+		if req.ResumedBy == "" {
+			var v model.ValidationError
+			v.Add("resumed_by", "is required")
+			writeError(w, r, &v)
+			return
+		}
+		if err := h.storage.ResumeHalt(r.Context(), id, req.ResumedBy); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		h.triggerSync(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// validateHalt - проверка области остановки и обязательных полей журнала
+func validateHalt(halt *model.Halt) error {
+	var v model.ValidationError
+	if halt.Reason == "" {
+		v.Add("reason", "is required")
+	}
+	if halt.TriggeredBy == "" {
+		v.Add("triggered_by", "is required")
+	}
+	switch halt.Scope {
+	case model.HaltGlobal:
+		if halt.ClientID != 0 {
+			v.Add("client_id", "is not allowed for global halt")
+		}
+		if halt.Algorithm != "" {
+			v.Add("algorithm", "is not allowed for global halt")
+		}
+	case model.HaltClient:
+		if halt.ClientID <= 0 {
+			v.Add("client_id", "must be positive")
+		}
+		if halt.Algorithm != "" {
+			v.Add("algorithm", "is not allowed for client halt, use algorithm scope")
+		}
+	case model.HaltAlgorithm:
+		if !halt.Algorithm.Valid() {
+			v.Add("algorithm", "unknown algorithm")
+		}
+		if halt.ClientID < 0 {
+			v.Add("client_id", "must be positive")
+		}
+	default:
+		v.Add("scope", "must be one of global, client, algorithm")
+	}
+	return v.Err()
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_ActivateKillSwitch(t *testing.T) {
+	tests := []struct {
+		name           string
+		inputBody      string
+		storageError   error
+		killErr        error
+		expectedStatus int
+		expectedBody   string
+		expectedCode   string
+	}{
+		{
+			name:           "200",
+			inputBody:      `{"scope":"client","client_id":42,"reason":"runaway","triggered_by":"desk"}`,
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"halt":{"id":1,"scope":"client","client_id":42,"reason":"runaway","triggered_by":"desk",` +
+				`"created_at":"0001-01-01T00:00:00Z"},"deleted_pods":["hft-7"],"failed_pods":[]}` + "\n",
+		},
+		{
+			name:           "400 body",
+			inputBody:      `{`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_body",
+		},
+		{
+			name:           "422 reason",
+			inputBody:      `{"scope":"global"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+		},
+		{
+			name:           "422 algorithm",
+			inputBody:      `{"scope":"algorithm","algorithm":"foo","reason":"r","triggered_by":"t"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+		},
+		{
+			name:           "500 storage",
+			inputBody:      `{"scope":"global","reason":"r","triggered_by":"t"}`,
+			storageError:   errors.New("error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   "internal",
+		},
+		{
+			name:           "503 kill",
+			inputBody:      `{"scope":"global","reason":"r","triggered_by":"t"}`,
+			killErr:        errors.New("error"),
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCode:   "pods_not_deleted",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &mockStorage{
+				addHalt: func(ctx context.Context, halt *model.Halt) error {
+					halt.ID = 1
+					return tt.storageError
+				},
+			}
+			syncer := &mockSyncer{killed: []string{"hft-7"}, killErr: tt.killErr}
+			handler := &Handler{storage: storage, sync: syncer}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/killswitch", bytes.NewBufferString(tt.inputBody))
+
+			handler.ActivateKillSwitch()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+			} else {
+				assert.Equal(t, tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_ResumeHalt(t *testing.T) {
+	tests := []struct {
+		name            string
+		id              string
+		inputBody       string
+		principal       *model.Principal
+		storageError    error
+		expectedStatus  int
+		expectedBody    string
+		expectedCode    string
+		expectedBy      string
+		expectedTrigger int
+	}{
+		{
+			name:            "200",
+			id:              "1",
+			inputBody:       `{"resumed_by":"desk"}`,
+			expectedStatus:  http.StatusOK,
+			expectedBy:      "desk",
+			expectedTrigger: 1,
+		},
+		{
+			name:            "200 empty body",
+			id:              "1",
+			principal:       &model.Principal{Subject: "alice", Role: model.RoleOperator, Method: model.AuthJWT},
+			expectedStatus:  http.StatusOK,
+			expectedBy:      "alice",
+			expectedTrigger: 1,
+		},
+		{
+			name:           "422 empty body without auth",
+			id:             "1",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+		},
+		{
+			name:           "400 body",
+			id:             "1",
+			inputBody:      `{"resumed_by":`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_body",
+		},
+		{
+			name:           "400 id",
+			id:             "x",
+			inputBody:      `{"resumed_by":"desk"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_id",
+		},
+		{
+			name:           "422 resumed_by",
+			id:             "1",
+			inputBody:      `{}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+		},
+		{
+			name:           "404",
+			id:             "1",
+			inputBody:      `{"resumed_by":"desk"}`,
+			storageError:   model.ErrorHaltNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "halt_not_found",
+			expectedBy:     "desk",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resumedBy string
+			storage := &mockStorage{
+				resumeHalt: func(ctx context.Context, id int64, by string) error {
+					resumedBy = by
+					return tt.storageError
+				},
+			}
+			syncer := &mockSyncer{}
+			handler := &Handler{storage: storage, sync: syncer}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/killswitch/"+tt.id+"/resume", bytes.NewBufferString(tt.inputBody))
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+			if tt.principal != nil {
+				req = req.WithContext(context.WithValue(req.Context(), principalKey{}, tt.principal))
+			}
+
+			handler.ResumeHalt()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+			} else {
+				assert.Equal(t, tt.expectedBody, rr.Body.String())
+			}
+			assert.Equal(t, tt.expectedTrigger, syncer.calls)
+			assert.Equal(t, tt.expectedBy, resumedBy)
+		})
+	}
+}
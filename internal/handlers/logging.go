@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"log/slog"
+	"net/http"
+)
+
+// GetLogLevel - ручка получения текущего уровня лога
+func (h *Handler) GetLogLevel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(model.LogLevel{Level: levelName(h.logger.Level())})
+	}
+}
+
+// SetLogLevel - ручка смены уровня лога без перезапуска. Уровень действует до перезапуска реплики
+// и только на ней, после перезапуска берётся уровень из конфига.
+func (h *Handler) SetLogLevel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req model.LogLevel
+		if err := decodeBody(r, &req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(req.Level)); err != nil {
+			var v model.ValidationError
+			v.Add("level", "must be one of debug, info, warn, error")
+			writeError(w, r, &v)
+			return
+		}
+		previous := h.logger.Level()
+		h.logger.SetLevel(level)
+		h.log(r).Warn("log level changed", slog.String("from", levelName(previous)),
+			slog.String("to", levelName(level)), slog.String("changed_by", actor(r, "")))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(model.LogLevel{Level: levelName(level)})
+	}
+}
+
+// levelName - имя уровня в нижнем регистре, как его принимает SetLogLevel
+func levelName(l slog.Level) string {
+	switch l {
+	case slog.LevelDebug:
+		return "debug"
+	case slog.LevelInfo:
+		return "info"
+	case slog.LevelWarn:
+		return "warn"
+	case slog.LevelError:
+		return "error"
+	}
+	return l.String()
+}
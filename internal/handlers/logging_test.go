@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CyrilSbrodov/syncService/cmd/loggers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetLogLevel(t *testing.T) {
+	logger, err := loggers.NewLogger(loggers.Options{Env: "prod", Level: "warn"})
+	require.NoError(t, err)
+	h := &Handler{logger: logger}
+	rr := httptest.NewRecorder()
+
+	h.GetLogLevel()(rr, httptest.NewRequest(http.MethodGet, "/api/admin/log-level", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"level":"warn"}`, rr.Body.String())
+}
+
+func TestSetLogLevel(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedCode   string
+		expectedLevel  slog.Level
+	}{
+		{name: "200", body: `{"level":"debug"}`, expectedStatus: http.StatusOK, expectedLevel: slog.LevelDebug},
+		{name: "upper case", body: `{"level":"ERROR"}`, expectedStatus: http.StatusOK, expectedLevel: slog.LevelError},
+		{
+			name:           "422",
+			body:           `{"level":"verbose"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+			expectedLevel:  slog.LevelInfo,
+		},
+		{
+			name:           "400",
+			body:           `{"lvl":"debug"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_body",
+			expectedLevel:  slog.LevelInfo,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, err := loggers.NewLogger(loggers.Options{Env: "prod"})
+			require.NoError(t, err)
+			child := logger.With(slog.String("request_id", "abc"))
+			h := &Handler{logger: logger}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "/api/admin/log-level", strings.NewReader(tt.body))
+
+			h.SetLogLevel()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+			}
+			assert.Equal(t, tt.expectedLevel, logger.Level())
+			assert.Equal(t, tt.expectedLevel, child.Level())
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github.com/CyrilSbrodov/syncService/cmd/loggers"
+	"github.com/CyrilSbrodov/syncService/internal/metrics"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/CyrilSbrodov/syncService/internal/tracing"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
+
+// accessKey - ключ сведений о запросе для лога доступа в контексте
+type accessKey struct{}
+
+// access - сведения о запросе, которые дописывают внутренние middleware, пишутся в лог доступа
+type access struct {
+	principal *model.Principal
+}
+
+// setAccessPrincipal - вызывающий для лога доступа, если запрос прошёл через RequestLog
+func setAccessPrincipal(r *http.Request, p *model.Principal) {
+	if a, ok := r.Context().Value(accessKey{}).(*access); ok {
+		a.principal = p
+	}
+}
+
+// log - логгер запроса с его id, вне RequestLog - логгер ручек
+func (h *Handler) log(r *http.Request) *loggers.Logger {
+	return loggers.FromContext(r.Context(), h.logger)
+}
+
+// Trace - middleware трассировки: span "<метод> <шаблон пути>" продолжает трассу вызывающего
+// из заголовков traceparent/tracestate, его контекст передаётся ручкам, хранилищу и синкеру.
+// Статусом Error отмечаются только ответы 5xx.
+func (h *Handler) Trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		route := routeTemplate(r)
+		ctx, span := tracing.Start(ctx, r.Method+" "+route, trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route), attribute.String("url.path", r.URL.Path)))
+		defer span.End()
+		sw := &statusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", sw.Status()))
+		if sw.Status() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(sw.Status()))
+		}
+	})
+}
+
+// RequestLog - middleware id запроса и лога доступа. X-Request-ID берётся из запроса либо генерируется
+// и возвращается в ответе, в контекст кладётся логгер с id запроса - им пишут ручки и хранилище.
+// Если запрос трассируется, в логгер добавляется trace_id.
+// После ответа пишется строка лога доступа со статусом, размером ответа и временем выполнения.
+func (h *Handler) RequestLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		logger := h.logger.With(slog.String("request_id", requestID(w, r)))
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			logger = logger.With(slog.String("trace_id", sc.TraceID().String()))
+		}
+		a := &access{}
+		ctx := loggers.WithContext(context.WithValue(r.Context(), accessKey{}, a), logger)
+		sw := &statusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", sw.Status()),
+			slog.Int("bytes", sw.bytes),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("remote", r.RemoteAddr),
+		}
+		if a.principal != nil {
+			attrs = append(attrs, slog.String("subject", a.principal.Subject),
+				slog.String("role", string(a.principal.Role)), slog.String("auth", string(a.principal.Method)))
+		}
+		level := slog.LevelInfo
+		if sw.Status() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger.LogAttrs(ctx, level, "http request", attrs...)
+	})
+}
+
+// Metrics - middleware метрик HTTP API: число запросов и время выполнения по шаблону пути, методу и статусу.
+// Шаблон (/api/client/{id}) вместо пути не даёт id клиентов раздувать число рядов.
+func (h *Handler) Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, r)
+
+		route := routeTemplate(r)
+		status := strconv.Itoa(sw.Status())
+		metrics.HTTPRequests.WithLabelValues(route, r.Method, status).Inc()
+		metrics.HTTPDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// routeTemplate - шаблон пути mux найденного маршрута, unmatched - маршрут не найден
+func routeTemplate(r *http.Request) string {
+	if cr := mux.CurrentRoute(r); cr != nil {
+		if t, err := cr.GetPathTemplate(); err == nil {
+			return t
+		}
+	}
+	return "unmatched"
+}
+
+// maxBody - наибольший размер тела запроса, 1 МиБ с запасом вмещает пакет из 1000 изменений
+const maxBody = 1 << 20
+
+// LimitBody - middleware ограничения тела запроса maxBody байтами. Чтение сверх лимита возвращает
+// *http.MaxBytesError, который decodeBody, decodeMergePatch и Idempotency превращают в ответ 413.
+func (h *Handler) LimitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Recover - middleware восстановления после паники в ручке. Паника и стек пишутся в лог запроса,
+// вызывающему отдаётся 500 internal, если ответ ещё не начат. http.ErrAbortHandler пробрасывается дальше.
+func (h *Handler) Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			h.log(r).Error("panic in handler", slog.String("method", r.Method), slog.String("path", r.URL.Path),
+				slog.String("panic", fmt.Sprint(v)), slog.String("stack", string(debug.Stack())))
+			if sw.status == 0 {
+				writeError(sw, r, model.ErrorInternal)
+			}
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// statusWriter - ResponseWriter, который запоминает статус и размер ответа, 0 - ответ ещё не начат
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (s *statusWriter) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusWriter) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += n
+	return n, err
+}
+
+// Unwrap - исходный ResponseWriter для http.ResponseController
+func (s *statusWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// Status - статус ответа, ручка без ответа отдаёт 200
+func (s *statusWriter) Status() int {
+	if s.status == 0 {
+		return http.StatusOK
+	}
+	return s.status
+}
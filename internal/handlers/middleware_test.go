@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/CyrilSbrodov/syncService/cmd/loggers"
+	"github.com/CyrilSbrodov/syncService/internal/config"
+	"github.com/CyrilSbrodov/syncService/internal/metrics"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// jsonLogger - логгер, который пишет JSON строки в buf
+func jsonLogger(buf *bytes.Buffer) *loggers.Logger {
+	return &loggers.Logger{Logger: slog.New(slog.NewJSONHandler(buf, nil))}
+}
+
+// logLines - строки JSON лога
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var m map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &m))
+		lines = append(lines, m)
+	}
+	return lines
+}
+
+func TestRequestLog(t *testing.T) {
+	tests := []struct {
+		name       string
+		requestID  string
+		expectSame bool
+	}{
+		{name: "propagated", requestID: "abc-123", expectSame: true},
+		{name: "generated", requestID: ""},
+		{name: "unsafe replaced", requestID: "abc\nlevel=ERROR"},
+		{name: "too long replaced", requestID: strings.Repeat("a", maxRequestID+1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := &Handler{logger: jsonLogger(&buf)}
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				setAccessPrincipal(r, &model.Principal{Subject: "alice", Role: model.RoleOperator, Method: model.AuthJWT})
+				h.log(r).Info("inside handler")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("done"))
+			})
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/client", nil)
+			if tt.requestID != "" {
+				req.Header.Set(requestIDHeader, tt.requestID)
+			}
+
+			h.RequestLog(next).ServeHTTP(rr, req)
+
+			id := rr.Header().Get(requestIDHeader)
+			assert.NotEmpty(t, id)
+			assert.Equal(t, tt.expectSame, id == tt.requestID)
+
+			lines := logLines(t, &buf)
+			require.Len(t, lines, 2)
+			assert.Equal(t, "inside handler", lines[0]["msg"])
+			assert.Equal(t, id, lines[0]["request_id"])
+
+			access := lines[1]
+			assert.Equal(t, "http request", access["msg"])
+			assert.Equal(t, id, access["request_id"])
+			assert.Equal(t, "POST", access["method"])
+			assert.Equal(t, "/api/client", access["path"])
+			assert.Equal(t, float64(http.StatusCreated), access["status"])
+			assert.Equal(t, float64(4), access["bytes"])
+			assert.Equal(t, "alice", access["subject"])
+			assert.Contains(t, access, "latency")
+		})
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	decode := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v map[string]any
+		if err := decodeBody(r, &v); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	patch := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v model.ClientPatch
+		if err := decodeMergePatch(r, &v); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	large := `{"name":"` + strings.Repeat("x", maxBody) + `"}`
+
+	tests := []struct {
+		name           string
+		handler        http.Handler
+		body           string
+		key            string
+		expectedStatus int
+	}{
+		{name: "decode", handler: decode, body: `{"name":"a"}`, expectedStatus: http.StatusNoContent},
+		{name: "decode too large", handler: decode, body: large, expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "merge patch too large", handler: patch, body: large, expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "idempotency too large", handler: decode, body: large, key: "k1", expectedStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reserved := 0
+			storage := &mockStorage{
+				reserveIdempotencyKey: func(ctx context.Context, rec *model.IdempotencyRecord) (*model.IdempotencyRecord, error) {
+					reserved++
+					return nil, nil
+				},
+			}
+			h := &Handler{cfg: &config.Config{}, storage: storage}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/client", strings.NewReader(tt.body))
+			if tt.key != "" {
+				req.Header.Set(idempotencyKeyHeader, tt.key)
+			}
+
+			h.LimitBody(h.Idempotency(tt.handler)).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusRequestEntityTooLarge {
+				assert.Equal(t, "body_too_large", problemCode(t, rr))
+			}
+			assert.Equal(t, 0, reserved)
+		})
+	}
+}
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name           string
+		handler        http.HandlerFunc
+		expectedStatus int
+	}{
+		{
+			name:           "panic before response",
+			handler:        func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "panic after response started",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				panic("boom")
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := &Handler{logger: jsonLogger(&buf)}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/client/1", nil)
+
+			h.RequestLog(h.Recover(tt.handler)).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			lines := logLines(t, &buf)
+			require.Len(t, lines, 2)
+			assert.Equal(t, "panic in handler", lines[0]["msg"])
+			assert.Equal(t, "boom", lines[0]["panic"])
+			assert.Contains(t, lines[0]["stack"], "runtime/debug.Stack")
+			assert.Equal(t, float64(tt.expectedStatus), lines[1]["status"])
+			if tt.expectedStatus == http.StatusInternalServerError {
+				assert.Equal(t, "internal", problemCode(t, rr))
+			}
+		})
+	}
+
+	t.Run("abort handler", func(t *testing.T) {
+		h := &Handler{logger: discardLogger()}
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) })
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			h.Recover(next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		})
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	h := &Handler{}
+	r := mux.NewRouter()
+	r.Use(h.Metrics)
+	r.HandleFunc("/api/client/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods("GET")
+	counter := metrics.HTTPRequests.WithLabelValues("/api/client/{id}", "GET", "404")
+	before := testutil.ToFloat64(counter)
+
+	for _, id := range []string{"1", "2"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/client/"+id, nil))
+	}
+
+	assert.Equal(t, before+2, testutil.ToFloat64(counter))
+}
+
+func TestTrace(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	tests := []struct {
+		name           string
+		status         int
+		expectedStatus codes.Code
+	}{
+		{name: "not found is not a failure", status: http.StatusNotFound, expectedStatus: codes.Unset},
+		{name: "server error", status: http.StatusInternalServerError, expectedStatus: codes.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := &Handler{logger: jsonLogger(&buf)}
+			r := mux.NewRouter()
+			r.Use(h.Trace, h.RequestLog)
+			r.HandleFunc("/api/client/{id}", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}).Methods("GET")
+			req := httptest.NewRequest(http.MethodGet, "/api/client/7", nil)
+			req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := sr.Ended()
+			require.NotEmpty(t, spans)
+			span := spans[len(spans)-1]
+			assert.Equal(t, "GET /api/client/{id}", span.Name())
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+			assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+			assert.Equal(t, tt.expectedStatus, span.Status().Code)
+			lines := logLines(t, &buf)
+			require.Len(t, lines, 1)
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", lines[0]["trace_id"])
+		})
+	}
+}
+
+func TestRegister_Unmatched(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{name: "404", method: http.MethodGet, path: "/api/unknown", expectedStatus: http.StatusNotFound, expectedCode: "route_not_found"},
+		{name: "405", method: http.MethodPatch, path: "/api/schedules", expectedStatus: http.StatusMethodNotAllowed, expectedCode: "method_not_allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := &Handler{logger: jsonLogger(&buf)}
+			r := mux.NewRouter()
+			h.Register(r)
+			counter := metrics.HTTPRequests.WithLabelValues("unmatched", tt.method, strconv.Itoa(tt.expectedStatus))
+			before := testutil.ToFloat64(counter)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Contains(t, rr.Body.String(), `"code":"`+tt.expectedCode+`"`)
+			assert.NotEmpty(t, rr.Header().Get(requestIDHeader))
+			assert.Equal(t, before+1, testutil.ToFloat64(counter))
+			lines := logLines(t, &buf)
+			require.NotEmpty(t, lines)
+			assert.EqualValues(t, tt.expectedStatus, lines[len(lines)-1]["status"])
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/CyrilSbrodov/syncService/cmd/loggers"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// GetClientRevisions - ручка получения ревизий клиента, новые первыми, с отличиями от предыдущей ревизии
+func (h *Handler) GetClientRevisions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeError(w, r, model.ErrorInvalidID)
+			return
+		}
+		revisions, err := h.storage.GetClientRevisions(r.Context(), id)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if revisions == nil {
+			revisions = []model.ClientRevision{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(revisions)
+	}
+}
+
+// RollbackClient - ручка отката клиента к ревизии ?revision=N: восстанавливаются спецификация и статусы алгоритмов,
+// затем запускается синхронизация. Откат, включающий алгоритм из политики подтверждения, хранилище отклоняет
+// с ErrorApprovalRequired.
+func (h *Handler) RollbackClient() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeError(w, r, model.ErrorInvalidID)
+			return
+		}
+		revision, err := strconv.ParseInt(r.URL.Query().Get("revision"), 10, 64)
+		if err != nil || revision <= 0 {
+			writeError(w, r, model.ErrorInvalidQuery)
+			return
+		}
+		rev, err := h.storage.RollbackClient(r.Context(), id, revision)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		h.log(r).Info("client rolled back", loggers.ClientID(id), slog.Int64("to_revision", revision),
+			slog.Int64("revision", rev.Revision), slog.String("by", rev.CreatedBy))
+		h.triggerSync(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(rev)
+	}
+}
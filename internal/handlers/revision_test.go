@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_RollbackClient(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		snapshot       model.ClientSnapshot
+		rollbackErr    error
+		expectedStatus int
+		expectedSync   int
+	}{
+		{
+			name:           "rolled back",
+			query:          "?revision=3",
+			snapshot:       model.ClientSnapshot{ClientSpec: model.ClientSpec{Version: 1}, VWAP: true},
+			expectedStatus: http.StatusOK,
+			expectedSync:   1,
+		},
+		{
+			name:           "no revision",
+			query:          "",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "revision not found",
+			query:          "?revision=9",
+			rollbackErr:    model.ErrorClientRevisionNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "enables gated algorithm",
+			query:          "?revision=3",
+			rollbackErr:    model.ErrorApprovalRequired,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &mockStorage{
+				rollbackClient: func(ctx context.Context, clientID, revision int64) (*model.ClientRevision, error) {
+					if tt.rollbackErr != nil {
+						return nil, tt.rollbackErr
+					}
+					return &model.ClientRevision{ClientID: clientID, Revision: 5, Snapshot: tt.snapshot}, nil
+				},
+			}
+			sync := &mockSyncer{}
+			h := &Handler{storage: storage, sync: sync, logger: discardLogger(), approvals: hftApproval}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/client/42/rollback"+tt.query, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": "42"})
+
+			h.RollbackClient()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedSync, sync.calls)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"net/http"
+)
+
+// GetSchedules - ручка получения расписаний торговых сессий всех клиентов
+func (h *Handler) GetSchedules() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.writeSchedules(w, r, 0)
+	}
+}
+
+// GetClientSchedules - ручка получения расписаний торговых сессий клиента
+func (h *Handler) GetClientSchedules() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeError(w, r, model.ErrorInvalidID)
+			return
+		}
+		h.writeSchedules(w, r, id)
+	}
+}
+
+// writeSchedules - ответ со списком расписаний
+func (h *Handler) writeSchedules(w http.ResponseWriter, r *http.Request, clientID int64) {
+	schedules, err := h.storage.GetSchedules(r.Context(), clientID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if schedules == nil {
+		schedules = []model.Schedule{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(schedules)
+}
+
+// SetSchedule - ручка создания или замены расписания торговой сессии.
+// Без algorithm расписание действует на все алгоритмы клиента, у которых нет своего.
+func (h *Handler) SetSchedule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeError(w, r, model.ErrorInvalidID)
+			return
+		}
+		var s model.Schedule
+		if err := decodeBody(r, &s); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		s.ClientID = id
+		if s.Algorithm != "" && !s.Algorithm.Valid() {
+			var v model.ValidationError
+			v.Add("algorithm", "unknown algorithm")
+			writeError(w, r, &v)
+			return
+		}
+		if err := s.Validate(); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if err := h.storage.SetSchedule(r.Context(), &s); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		h.triggerSync(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(s)
+	}
+}
+
+// DeleteSchedule - ручка удаления расписания, ?algorithm=hft - расписания отдельного алгоритма
+func (h *Handler) DeleteSchedule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeError(w, r, model.ErrorInvalidID)
+			return
+		}
+		algorithm := model.AlgorithmType(r.URL.Query().Get("algorithm"))
+		if algorithm != "" && !algorithm.Valid() {
+			writeError(w, r, model.ErrorInvalidQuery)
+			return
+		}
+		if err := h.storage.DeleteSchedule(r.Context(), id, algorithm); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		h.triggerSync(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_GetSchedules(t *testing.T) {
+	storage := &mockStorage{
+		getSchedules: func(ctx context.Context, clientID int64) ([]model.Schedule, error) {
+			return nil, nil
+		},
+	}
+	handler := &Handler{storage: storage}
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/schedules", nil)
+
+	handler.GetSchedules()(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "[]\n", rr.Body.String())
+}
+
+func TestHandler_SetSchedule(t *testing.T) {
+	tests := []struct {
+		name            string
+		inputBody       string
+		storageError    error
+		expectedStatus  int
+		expectedBody    string
+		expectedCode    string
+		expectedTrigger int
+	}{
+		{
+			name:            "200",
+			inputBody:       `{"algorithm":"hft","timezone":"America/New_York","days":["mon","fri"],"open":"09:30","close":"16:00"}`,
+			expectedStatus:  http.StatusOK,
+			expectedTrigger: 1,
+		},
+		{
+			name:           "422 algorithm",
+			inputBody:      `{"algorithm":"foo","timezone":"UTC","open":"09:30","close":"16:00"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+		},
+		{
+			name:           "422 timezone",
+			inputBody:      `{"timezone":"Mars/Olympus","open":"09:30","close":"16:00"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+		},
+		{
+			name:           "404",
+			inputBody:      `{"timezone":"UTC","open":"09:30","close":"16:00"}`,
+			storageError:   model.ErrorClientNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "client_not_found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var saved *model.Schedule
+			storage := &mockStorage{
+				setSchedule: func(ctx context.Context, s *model.Schedule) error {
+					saved = s
+					return tt.storageError
+				},
+			}
+			syncer := &mockSyncer{}
+			handler := &Handler{storage: storage, sync: syncer}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "/api/client/7/schedule", bytes.NewBufferString(tt.inputBody))
+			req = mux.SetURLVars(req, map[string]string{"id": "7"})
+
+			handler.SetSchedule()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+			} else if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, rr.Body.String())
+			}
+			assert.Equal(t, tt.expectedTrigger, syncer.calls)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, int64(7), saved.ClientID)
+			}
+		})
+	}
+}
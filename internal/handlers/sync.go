@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"net/http"
+)
+
+// GetSyncGuard - ручка получения состояния защиты от массового удаления
+func (h *Handler) GetSyncGuard() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		guard, err := h.storage.GetSyncGuard(r.Context())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if guard.Pods == nil {
+			guard.Pods = []string{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(guard)
+	}
+}
+
+// ConfirmSyncGuard - ручка подтверждения заблокированного массового удаления
+func (h *Handler) ConfirmSyncGuard() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := h.storage.ConfirmSyncGuard(r.Context()); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		h.triggerSync(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// GetSyncStatus - ручка получения итога последнего прохода синхронизации
+func (h *Handler) GetSyncStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, err := h.storage.GetSyncStatus(r.Context())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if status.SkippedClients == nil {
+			status.SkippedClients = []int64{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(status)
+	}
+}
+
+// GetFreeze - ручка получения глобальной заморозки синхронизации
+func (h *Handler) GetFreeze() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		freeze, err := h.storage.GetFreeze(r.Context())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(freeze)
+	}
+}
+
+// SetFreeze - ручка установки или снятия глобальной заморозки синхронизации.
+// Kill switch во время заморозки продолжает работать.
+func (h *Handler) SetFreeze() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var freeze model.Freeze
+		if err := decodeBody(r, &freeze); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if err := checkUntil(freeze.Until); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if !freeze.Frozen {
+			freeze.Until, freeze.Reason = nil, ""
+		}
+		if err := h.storage.SetFreeze(r.Context(), &freeze); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if !freeze.Frozen {
+			h.triggerSync(r.Context())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(freeze)
+	}
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSyncer struct {
+	calls      int
+	killed     []string
+	failed     []string
+	killErr    error
+	pods       []model.PodState
+	observeErr error
+	follower   bool
+}
+
+func (m *mockSyncer) Trigger(ctx context.Context) {
+	m.calls++
+}
+
+func (m *mockSyncer) Leader() bool {
+	return !m.follower
+}
+
+func (m *mockSyncer) Kill(ctx context.Context, halt *model.Halt) ([]string, []string, error) {
+	return m.killed, m.failed, m.killErr
+}
+
+func (m *mockSyncer) Observe(ctx context.Context, as model.AlgorithmStatus) ([]model.PodState, error) {
+	return m.pods, m.observeErr
+}
+
+func TestHandler_GetSyncGuard(t *testing.T) {
+	tests := []struct {
+		name           string
+		guard          *model.SyncGuard
+		storageError   error
+		expectedStatus int
+		expectedBody   string
+		expectedCode   string
+	}{
+		{
+			name:           "200",
+			guard:          &model.SyncGuard{Alert: true, Pods: []string{"hft-1"}, ManagedPods: 1, Reason: "limit"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"alert":true,"pods":["hft-1"],"managed_pods":1,"reason":"limit","confirmed":false}` + "\n",
+		},
+		{
+			name:           "200 no pods",
+			guard:          &model.SyncGuard{},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"alert":false,"pods":[],"managed_pods":0,"reason":"","confirmed":false}` + "\n",
+		},
+		{
+			name:           "500",
+			storageError:   errors.New("error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   "internal",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &mockStorage{
+				getSyncGuard: func(ctx context.Context) (*model.SyncGuard, error) {
+					return tt.guard, tt.storageError
+				},
+			}
+			handler := &Handler{storage: storage}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/sync/guard", nil)
+
+			handler.GetSyncGuard()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+			} else {
+				assert.Equal(t, tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_ConfirmSyncGuard(t *testing.T) {
+	tests := []struct {
+		name            string
+		storageError    error
+		expectedStatus  int
+		expectedBody    string
+		expectedCode    string
+		expectedTrigger int
+	}{
+		{
+			name:            "200",
+			expectedStatus:  http.StatusOK,
+			expectedTrigger: 1,
+		},
+		{
+			name:           "409",
+			storageError:   model.ErrorNoSyncAlert,
+			expectedStatus: http.StatusConflict,
+			expectedCode:   "no_sync_alert",
+		},
+		{
+			name:           "500",
+			storageError:   errors.New("error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   "internal",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &mockStorage{
+				confirmSyncGuard: func(ctx context.Context) error {
+					return tt.storageError
+				},
+			}
+			trigger := &mockSyncer{}
+			handler := &Handler{storage: storage, sync: trigger}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/sync/guard/confirm", nil)
+
+			handler.ConfirmSyncGuard()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+			} else {
+				assert.Equal(t, tt.expectedBody, rr.Body.String())
+			}
+			assert.Equal(t, tt.expectedTrigger, trigger.calls)
+		})
+	}
+}
+
+func TestHandler_SetFreeze(t *testing.T) {
+	tests := []struct {
+		name            string
+		inputBody       string
+		storageError    error
+		expectedStatus  int
+		expectedCode    string
+		expectedTrigger int
+	}{
+		{
+			name:           "200 freeze",
+			inputBody:      `{"frozen":true,"until":"2999-01-01T00:00:00Z","reason":"cluster upgrade"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:            "200 unfreeze",
+			inputBody:       `{"frozen":false}`,
+			expectedStatus:  http.StatusOK,
+			expectedTrigger: 1,
+		},
+		{
+			name:           "422",
+			inputBody:      `{"frozen":true,"until":"2000-01-01T00:00:00Z"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+		},
+		{
+			name:           "500",
+			inputBody:      `{"frozen":true}`,
+			storageError:   errors.New("error"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &mockStorage{
+				setFreeze: func(ctx context.Context, f *model.Freeze) error {
+					return tt.storageError
+				},
+			}
+			syncer := &mockSyncer{}
+			handler := &Handler{storage: storage, sync: syncer}
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "/api/sync/freeze", bytes.NewBufferString(tt.inputBody))
+
+			handler.SetFreeze()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, problemCode(t, rr))
+			}
+			assert.Equal(t, tt.expectedTrigger, syncer.calls)
+		})
+	}
+}
+
+func TestHandler_GetSyncStatus(t *testing.T) {
+	storage := &mockStorage{
+		getSyncStatus: func(ctx context.Context) (*model.SyncStatus, error) {
+			return &model.SyncStatus{}, nil
+		},
+	}
+	handler := &Handler{storage: storage}
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/sync/status", nil)
+
+	handler.GetSyncStatus()(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"skipped_clients":[]`)
+}
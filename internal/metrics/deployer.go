@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"context"
+	"github.com/CyrilSbrodov/syncService/internal/deployer"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"time"
+)
+
+// Deployer - деплоер, который пишет время и ошибки вызовов API кластера
+type Deployer struct {
+	next deployer.Deployer
+}
+
+// NewDeployer - конструктор деплоера с метриками поверх next
+func NewDeployer(next deployer.Deployer) *Deployer {
+	return &Deployer{next: next}
+}
+
+func (m *Deployer) CreatePod(ctx context.Context, pod model.Pod) (err error) {
+	defer observeDeployer("create_pod", time.Now(), &err)
+	return m.next.CreatePod(ctx, pod)
+}
+
+func (m *Deployer) DeletePod(ctx context.Context, name string) (err error) {
+	defer observeDeployer("delete_pod", time.Now(), &err)
+	return m.next.DeletePod(ctx, name)
+}
+
+func (m *Deployer) GetPodList(ctx context.Context) (_ []string, err error) {
+	defer observeDeployer("list_pods", time.Now(), &err)
+	return m.next.GetPodList(ctx)
+}
@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net"
+	"net/http"
+	"time"
+)
+
+// namespace - префикс имён метрик сервиса
+const namespace = "syncservice"
+
+// Метрики HTTP API. route - шаблон пути mux (/api/client/{id}), чтобы id не раздували число рядов.
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+)
+
+// Метрики хранилища по методам storage.Storage
+var (
+	StorageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "call_duration_seconds",
+		Help:      "Storage call latency by method.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"method"})
+	StorageErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "errors_total",
+		Help:      "Storage call errors by method and error kind.",
+	}, []string{"method", "kind"})
+)
+
+// Метрики синкера
+var (
+	SyncDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "sync",
+		Name:      "pass_duration_seconds",
+		Help:      "Sync pass duration by result.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"result"})
+	SyncActions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sync",
+		Name:      "actions_total",
+		Help:      "Pods created or deleted by the syncer by action and algorithm type.",
+	}, []string{"action", "algorithm"})
+	SyncActionFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sync",
+		Name:      "action_failures_total",
+		Help:      "Failed pod creations and deletions by action and algorithm type.",
+	}, []string{"action", "algorithm"})
+	AlgorithmsDesired = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "sync",
+		Name:      "algorithms_desired",
+		Help:      "Algorithm pods that should be running after the last sync pass by type.",
+	}, []string{"algorithm"})
+	AlgorithmsRunning = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "sync",
+		Name:      "algorithms_running",
+		Help:      "Syncer pods running in the cluster at the last sync pass by type.",
+	}, []string{"algorithm"})
+)
+
+// Метрики вызовов API кластера
+var (
+	DeployerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "deployer",
+		Name:      "call_duration_seconds",
+		Help:      "Cluster API call latency by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+	DeployerErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "deployer",
+		Name:      "errors_total",
+		Help:      "Cluster API call errors by operation.",
+	}, []string{"operation"})
+)
+
+// Handler - ручка /metrics в формате Prometheus
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// SetAlgorithms - число желаемых и запущенных pod'ов по типам алгоритмов, отсутствующие типы - 0
+func SetAlgorithms(desired, running map[model.AlgorithmType]int) {
+	for _, t := range model.AlgorithmTypes {
+		AlgorithmsDesired.WithLabelValues(string(t)).Set(float64(desired[t]))
+		AlgorithmsRunning.WithLabelValues(string(t)).Set(float64(running[t]))
+	}
+}
+
+// observeStorage - время вызова хранилища и его ошибка с категорией, вызывается через defer с адресом ошибки
+func observeStorage(method string, start time.Time, err *error) {
+	StorageDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if *err != nil {
+		StorageErrors.WithLabelValues(method, errorKind(*err)).Inc()
+	}
+}
+
+// observeDeployer - время вызова API кластера и его ошибка, вызывается через defer с адресом ошибки
+func observeDeployer(operation string, start time.Time, err *error) {
+	DeployerDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if *err != nil {
+		DeployerErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// errorKind - категория ошибки для меток: категория model.Error, validation, unavailable или internal
+func errorKind(err error) string {
+	var (
+		e      *model.Error
+		v      *model.ValidationError
+		netErr net.Error
+	)
+	switch {
+	case errors.As(err, &v):
+		return string(model.KindValidation)
+	case errors.As(err, &e):
+		return string(e.Kind)
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		return string(model.KindUnavailable)
+	}
+	return string(model.KindInternal)
+}
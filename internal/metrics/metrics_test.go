@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/CyrilSbrodov/syncService/internal/storage"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// clientStorage - хранилище, в котором реализовано только получение клиента
+type clientStorage struct {
+	storage.Storage
+	err error
+}
+
+func (s clientStorage) GetClient(ctx context.Context, id int64) (*model.Client, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &model.Client{ID: id}, nil
+}
+
+func TestStorage(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		expectedKind string
+	}{
+		{name: "ok"},
+		{name: "not found", err: model.ErrorClientNotFound, expectedKind: "not_found"},
+		{name: "connection", err: fmt.Errorf("query: %w", driver.ErrBadConn), expectedKind: "unavailable"},
+		{name: "unknown", err: errors.New("boom"), expectedKind: "internal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before float64
+			if tt.expectedKind != "" {
+				before = testutil.ToFloat64(StorageErrors.WithLabelValues("GetClient", tt.expectedKind))
+			}
+
+			c, err := NewStorage(clientStorage{err: tt.err}).GetClient(context.Background(), 42)
+
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, 1, testutil.CollectAndCount(StorageDuration))
+			if tt.expectedKind == "" {
+				assert.Equal(t, int64(42), c.ID)
+				return
+			}
+			assert.Equal(t, before+1, testutil.ToFloat64(StorageErrors.WithLabelValues("GetClient", tt.expectedKind)))
+		})
+	}
+}
+
+// podList - деплоер, который только возвращает список pod'ов
+type podList struct {
+	err error
+}
+
+func (d podList) CreatePod(ctx context.Context, pod model.Pod) error { return nil }
+func (d podList) DeletePod(ctx context.Context, name string) error   { return nil }
+func (d podList) GetPodList(ctx context.Context) ([]string, error)   { return []string{"hft-1"}, d.err }
+
+func TestDeployer(t *testing.T) {
+	before := testutil.ToFloat64(DeployerErrors.WithLabelValues("list_pods"))
+
+	_, err := NewDeployer(podList{}).GetPodList(context.Background())
+	assert.NoError(t, err)
+	_, err = NewDeployer(podList{err: errors.New("api down")}).GetPodList(context.Background())
+	assert.Error(t, err)
+
+	assert.Equal(t, before+1, testutil.ToFloat64(DeployerErrors.WithLabelValues("list_pods")))
+}
+
+func TestSetAlgorithms(t *testing.T) {
+	SetAlgorithms(map[model.AlgorithmType]int{model.AlgorithmHFT: 3},
+		map[model.AlgorithmType]int{model.AlgorithmHFT: 2, model.AlgorithmVWAP: 1})
+
+	assert.Equal(t, float64(3), testutil.ToFloat64(AlgorithmsDesired.WithLabelValues("hft")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(AlgorithmsDesired.WithLabelValues("vwap")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(AlgorithmsRunning.WithLabelValues("hft")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(AlgorithmsRunning.WithLabelValues("vwap")))
+}
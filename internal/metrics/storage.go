@@ -0,0 +1,306 @@
+package metrics
+
+import (
+	"context"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/CyrilSbrodov/syncService/internal/storage"
+	"time"
+)
+
+// Storage - хранилище, которое пишет время и ошибки вызовов по методам storage.Storage
+type Storage struct {
+	next storage.Storage
+}
+
+// NewStorage - конструктор хранилища с метриками поверх next
+func NewStorage(next storage.Storage) *Storage {
+	return &Storage{next: next}
+}
+
+func (m *Storage) AddClient(ctx context.Context, client *model.Client) (err error) {
+	defer observeStorage("AddClient", time.Now(), &err)
+	return m.next.AddClient(ctx, client)
+}
+
+func (m *Storage) GetClient(ctx context.Context, id int64) (_ *model.Client, err error) {
+	defer observeStorage("GetClient", time.Now(), &err)
+	return m.next.GetClient(ctx, id)
+}
+
+func (m *Storage) UpdateClient(ctx context.Context, client *model.Client) (err error) {
+	defer observeStorage("UpdateClient", time.Now(), &err)
+	return m.next.UpdateClient(ctx, client)
+}
+
+func (m *Storage) PatchClient(ctx context.Context, id, revision int64,
+	patch *model.ClientPatch) (_ *model.Client, err error) {
+	defer observeStorage("PatchClient", time.Now(), &err)
+	return m.next.PatchClient(ctx, id, revision, patch)
+}
+
+func (m *Storage) GetClients(ctx context.Context) (_ []model.Client, err error) {
+	defer observeStorage("GetClients", time.Now(), &err)
+	return m.next.GetClients(ctx)
+}
+
+func (m *Storage) SetClientsSpawned(ctx context.Context, ids []int64) (err error) {
+	defer observeStorage("SetClientsSpawned", time.Now(), &err)
+	return m.next.SetClientsSpawned(ctx, ids)
+}
+
+func (m *Storage) SetClientsRestarted(ctx context.Context, clients []model.Client) (err error) {
+	defer observeStorage("SetClientsRestarted", time.Now(), &err)
+	return m.next.SetClientsRestarted(ctx, clients)
+}
+
+func (m *Storage) DeleteClient(ctx context.Context, id int64) (err error) {
+	defer observeStorage("DeleteClient", time.Now(), &err)
+	return m.next.DeleteClient(ctx, id)
+}
+
+func (m *Storage) RestoreClient(ctx context.Context, id int64) (_ *model.Client, err error) {
+	defer observeStorage("RestoreClient", time.Now(), &err)
+	return m.next.RestoreClient(ctx, id)
+}
+
+func (m *Storage) SetDeletionProtection(ctx context.Context, id int64, protected bool) (_ *model.Client, err error) {
+	defer observeStorage("SetDeletionProtection", time.Now(), &err)
+	return m.next.SetDeletionProtection(ctx, id, protected)
+}
+
+func (m *Storage) PurgeDeletedClients(ctx context.Context, before time.Time) (_ int64, err error) {
+	defer observeStorage("PurgeDeletedClients", time.Now(), &err)
+	return m.next.PurgeDeletedClients(ctx, before)
+}
+
+func (m *Storage) UpdateAlgorithmStatus(ctx context.Context, as *model.AlgorithmStatus) (err error) {
+	defer observeStorage("UpdateAlgorithmStatus", time.Now(), &err)
+	return m.next.UpdateAlgorithmStatus(ctx, as)
+}
+
+func (m *Storage) PatchAlgorithmStatus(ctx context.Context, clientID int64,
+	patch *model.AlgorithmPatch) (_ *model.AlgorithmStatus, err error) {
+	defer observeStorage("PatchAlgorithmStatus", time.Now(), &err)
+	return m.next.PatchAlgorithmStatus(ctx, clientID, patch)
+}
+
+func (m *Storage) GetAlgorithmStatus(ctx context.Context) (_ []model.AlgorithmStatus, err error) {
+	defer observeStorage("GetAlgorithmStatus", time.Now(), &err)
+	return m.next.GetAlgorithmStatus(ctx)
+}
+
+func (m *Storage) GetDeletedAlgorithms(ctx context.Context) (_ []int64, err error) {
+	defer observeStorage("GetDeletedAlgorithms", time.Now(), &err)
+	return m.next.GetDeletedAlgorithms(ctx)
+}
+
+func (m *Storage) GetClientAlgorithms(ctx context.Context, clientID int64) (_ *model.AlgorithmStatus, err error) {
+	defer observeStorage("GetClientAlgorithms", time.Now(), &err)
+	return m.next.GetClientAlgorithms(ctx, clientID)
+}
+
+func (m *Storage) BulkUpdateAlgorithms(ctx context.Context, mode model.BulkMode,
+	changes []model.AlgorithmChange) (_ *model.BulkResult, err error) {
+	defer observeStorage("BulkUpdateAlgorithms", time.Now(), &err)
+	return m.next.BulkUpdateAlgorithms(ctx, mode, changes)
+}
+
+func (m *Storage) GetSyncGuard(ctx context.Context) (_ *model.SyncGuard, err error) {
+	defer observeStorage("GetSyncGuard", time.Now(), &err)
+	return m.next.GetSyncGuard(ctx)
+}
+
+func (m *Storage) RaiseSyncGuard(ctx context.Context, guard *model.SyncGuard) (err error) {
+	defer observeStorage("RaiseSyncGuard", time.Now(), &err)
+	return m.next.RaiseSyncGuard(ctx, guard)
+}
+
+func (m *Storage) ConfirmSyncGuard(ctx context.Context) (err error) {
+	defer observeStorage("ConfirmSyncGuard", time.Now(), &err)
+	return m.next.ConfirmSyncGuard(ctx)
+}
+
+func (m *Storage) ResetSyncGuard(ctx context.Context) (err error) {
+	defer observeStorage("ResetSyncGuard", time.Now(), &err)
+	return m.next.ResetSyncGuard(ctx)
+}
+
+func (m *Storage) AddHalt(ctx context.Context, halt *model.Halt) (err error) {
+	defer observeStorage("AddHalt", time.Now(), &err)
+	return m.next.AddHalt(ctx, halt)
+}
+
+func (m *Storage) GetHalts(ctx context.Context, activeOnly bool) (_ []model.Halt, err error) {
+	defer observeStorage("GetHalts", time.Now(), &err)
+	return m.next.GetHalts(ctx, activeOnly)
+}
+
+func (m *Storage) ResumeHalt(ctx context.Context, id int64, resumedBy string) (err error) {
+	defer observeStorage("ResumeHalt", time.Now(), &err)
+	return m.next.ResumeHalt(ctx, id, resumedBy)
+}
+
+func (m *Storage) SetClientSuspension(ctx context.Context, s *model.Suspension) (err error) {
+	defer observeStorage("SetClientSuspension", time.Now(), &err)
+	return m.next.SetClientSuspension(ctx, s)
+}
+
+func (m *Storage) GetSuspendedClients(ctx context.Context) (_ []model.Suspension, err error) {
+	defer observeStorage("GetSuspendedClients", time.Now(), &err)
+	return m.next.GetSuspendedClients(ctx)
+}
+
+func (m *Storage) GetFreeze(ctx context.Context) (_ *model.Freeze, err error) {
+	defer observeStorage("GetFreeze", time.Now(), &err)
+	return m.next.GetFreeze(ctx)
+}
+
+func (m *Storage) SetFreeze(ctx context.Context, f *model.Freeze) (err error) {
+	defer observeStorage("SetFreeze", time.Now(), &err)
+	return m.next.SetFreeze(ctx, f)
+}
+
+func (m *Storage) GetSyncStatus(ctx context.Context) (_ *model.SyncStatus, err error) {
+	defer observeStorage("GetSyncStatus", time.Now(), &err)
+	return m.next.GetSyncStatus(ctx)
+}
+
+func (m *Storage) SaveSyncStatus(ctx context.Context, st *model.SyncStatus) (err error) {
+	defer observeStorage("SaveSyncStatus", time.Now(), &err)
+	return m.next.SaveSyncStatus(ctx, st)
+}
+
+func (m *Storage) TryLockSync(ctx context.Context) (_ bool, err error) {
+	defer observeStorage("TryLockSync", time.Now(), &err)
+	return m.next.TryLockSync(ctx)
+}
+
+func (m *Storage) NotifySync(ctx context.Context, event model.SyncEvent) (err error) {
+	defer observeStorage("NotifySync", time.Now(), &err)
+	return m.next.NotifySync(ctx, event)
+}
+
+func (m *Storage) ListenSync(ctx context.Context) (_ <-chan model.SyncEvent, err error) {
+	defer observeStorage("ListenSync", time.Now(), &err)
+	return m.next.ListenSync(ctx)
+}
+
+func (m *Storage) GetSchedules(ctx context.Context, clientID int64) (_ []model.Schedule, err error) {
+	defer observeStorage("GetSchedules", time.Now(), &err)
+	return m.next.GetSchedules(ctx, clientID)
+}
+
+func (m *Storage) SetSchedule(ctx context.Context, s *model.Schedule) (err error) {
+	defer observeStorage("SetSchedule", time.Now(), &err)
+	return m.next.SetSchedule(ctx, s)
+}
+
+func (m *Storage) DeleteSchedule(ctx context.Context, clientID int64, algorithm model.AlgorithmType) (err error) {
+	defer observeStorage("DeleteSchedule", time.Now(), &err)
+	return m.next.DeleteSchedule(ctx, clientID, algorithm)
+}
+
+func (m *Storage) AddScheduledChange(ctx context.Context, c *model.ScheduledChange) (err error) {
+	defer observeStorage("AddScheduledChange", time.Now(), &err)
+	return m.next.AddScheduledChange(ctx, c)
+}
+
+func (m *Storage) GetScheduledChanges(ctx context.Context, clientID int64,
+	status model.ChangeStatus) (_ []model.ScheduledChange, err error) {
+	defer observeStorage("GetScheduledChanges", time.Now(), &err)
+	return m.next.GetScheduledChanges(ctx, clientID, status)
+}
+
+func (m *Storage) CancelScheduledChange(ctx context.Context, id int64) (err error) {
+	defer observeStorage("CancelScheduledChange", time.Now(), &err)
+	return m.next.CancelScheduledChange(ctx, id)
+}
+
+func (m *Storage) ApplyDueChanges(ctx context.Context) (_ []model.ScheduledChange, err error) {
+	defer observeStorage("ApplyDueChanges", time.Now(), &err)
+	return m.next.ApplyDueChanges(ctx)
+}
+
+func (m *Storage) ReserveIdempotencyKey(ctx context.Context,
+	rec *model.IdempotencyRecord) (_ *model.IdempotencyRecord, err error) {
+	defer observeStorage("ReserveIdempotencyKey", time.Now(), &err)
+	return m.next.ReserveIdempotencyKey(ctx, rec)
+}
+
+func (m *Storage) SaveIdempotencyResponse(ctx context.Context, rec *model.IdempotencyRecord) (err error) {
+	defer observeStorage("SaveIdempotencyResponse", time.Now(), &err)
+	return m.next.SaveIdempotencyResponse(ctx, rec)
+}
+
+func (m *Storage) DeleteIdempotencyKey(ctx context.Context, key string) (err error) {
+	defer observeStorage("DeleteIdempotencyKey", time.Now(), &err)
+	return m.next.DeleteIdempotencyKey(ctx, key)
+}
+
+func (m *Storage) PurgeIdempotencyKeys(ctx context.Context) (_ int64, err error) {
+	defer observeStorage("PurgeIdempotencyKeys", time.Now(), &err)
+	return m.next.PurgeIdempotencyKeys(ctx)
+}
+
+func (m *Storage) AddAPIKey(ctx context.Context, key *model.APIKey, hash string) (err error) {
+	defer observeStorage("AddAPIKey", time.Now(), &err)
+	return m.next.AddAPIKey(ctx, key, hash)
+}
+
+func (m *Storage) GetAPIKeys(ctx context.Context) (_ []model.APIKey, err error) {
+	defer observeStorage("GetAPIKeys", time.Now(), &err)
+	return m.next.GetAPIKeys(ctx)
+}
+
+func (m *Storage) AuthenticateAPIKey(ctx context.Context, hash string) (_ *model.APIKey, err error) {
+	defer observeStorage("AuthenticateAPIKey", time.Now(), &err)
+	return m.next.AuthenticateAPIKey(ctx, hash)
+}
+
+func (m *Storage) RevokeAPIKey(ctx context.Context, id int64) (err error) {
+	defer observeStorage("RevokeAPIKey", time.Now(), &err)
+	return m.next.RevokeAPIKey(ctx, id)
+}
+
+func (m *Storage) AddApproval(ctx context.Context, a *model.ApprovalRequest) (err error) {
+	defer observeStorage("AddApproval", time.Now(), &err)
+	return m.next.AddApproval(ctx, a)
+}
+
+func (m *Storage) GetApproval(ctx context.Context, id int64) (_ *model.ApprovalRequest, err error) {
+	defer observeStorage("GetApproval", time.Now(), &err)
+	return m.next.GetApproval(ctx, id)
+}
+
+func (m *Storage) GetApprovals(ctx context.Context, clientID int64,
+	status model.ApprovalStatus) (_ []model.ApprovalRequest, err error) {
+	defer observeStorage("GetApprovals", time.Now(), &err)
+	return m.next.GetApprovals(ctx, clientID, status)
+}
+
+func (m *Storage) DecideApproval(ctx context.Context, id int64, status model.ApprovalStatus, decidedBy,
+	comment string) (_ *model.ApprovalRequest, err error) {
+	defer observeStorage("DecideApproval", time.Now(), &err)
+	return m.next.DecideApproval(ctx, id, status, decidedBy, comment)
+}
+
+func (m *Storage) GetAuditLog(ctx context.Context, f model.AuditFilter) (_ []model.AuditEntry, err error) {
+	defer observeStorage("GetAuditLog", time.Now(), &err)
+	return m.next.GetAuditLog(ctx, f)
+}
+
+func (m *Storage) GetClientRevisions(ctx context.Context, clientID int64) (_ []model.ClientRevision, err error) {
+	defer observeStorage("GetClientRevisions", time.Now(), &err)
+	return m.next.GetClientRevisions(ctx, clientID)
+}
+
+func (m *Storage) GetClientRevision(ctx context.Context, clientID,
+	revision int64) (_ *model.ClientRevision, err error) {
+	defer observeStorage("GetClientRevision", time.Now(), &err)
+	return m.next.GetClientRevision(ctx, clientID, revision)
+}
+
+func (m *Storage) RollbackClient(ctx context.Context, clientID, revision int64) (_ *model.ClientRevision, err error) {
+	defer observeStorage("RollbackClient", time.Now(), &err)
+	return m.next.RollbackClient(ctx, clientID, revision)
+}
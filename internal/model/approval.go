@@ -0,0 +1,87 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// ApprovalStatus - состояние запроса на подтверждение
+type ApprovalStatus string
+
+const (
+	ApprovalPending  ApprovalStatus = "pending"
+	ApprovalApproved ApprovalStatus = "approved"
+	ApprovalRejected ApprovalStatus = "rejected"
+	// ApprovalExpired - запрос не рассмотрен до ExpiresAt, применить его уже нельзя
+	ApprovalExpired ApprovalStatus = "expired"
+)
+
+// ApprovalRequest - изменение алгоритмов клиента, ожидающее подтверждения вторым вызывающим.
+// Изменение применяется к algorithm_status целиком только после подтверждения.
+type ApprovalRequest struct {
+	ID          int64          `json:"id"`
+	ClientID    int64          `json:"client_id"`
+	Changes     AlgorithmPatch `json:"changes"`
+	Status      ApprovalStatus `json:"status"`
+	RequestedBy string         `json:"requested_by"`
+	CreatedAt   time.Time      `json:"created_at"`
+	ExpiresAt   time.Time      `json:"expires_at"`
+	DecidedBy   string         `json:"decided_by,omitempty"`
+	DecidedAt   *time.Time     `json:"decided_at,omitempty"`
+	Comment     string         `json:"comment,omitempty"`
+}
+
+// ApprovalDecision - тело запроса подтверждения или отклонения.
+// DecidedBy используется только при выключенной аутентификации.
+type ApprovalDecision struct {
+	DecidedBy string `json:"decided_by"`
+	Comment   string `json:"comment"`
+}
+
+// ApprovalPolicy - политика подтверждения: включение алгоритмов Algorithms требует подтверждения
+// вызывающим с ролью не ниже ApproverRole, отличным от автора изменения. Пустой Algorithms - подтверждение не нужно.
+type ApprovalPolicy struct {
+	Algorithms   []AlgorithmType
+	ApproverRole Role
+	TTL          time.Duration
+}
+
+// NewApprovalPolicy - политика подтверждения из конфига
+func NewApprovalPolicy(algorithms []string, approverRole string, ttl time.Duration) (ApprovalPolicy, error) {
+	p := ApprovalPolicy{ApproverRole: Role(approverRole), TTL: ttl}
+	for _, a := range algorithms {
+		t := AlgorithmType(a)
+		if !t.Valid() {
+			return p, fmt.Errorf("approval: unknown algorithm %q", a)
+		}
+		p.Algorithms = append(p.Algorithms, t)
+	}
+	if !p.ApproverRole.Valid() {
+		return p, fmt.Errorf("approval: unknown approver role %q", approverRole)
+	}
+	if ttl <= 0 {
+		return p, fmt.Errorf("approval: ttl must be positive")
+	}
+	return p, nil
+}
+
+// Gated - требует ли включение алгоритма подтверждения
+func (p ApprovalPolicy) Gated(t AlgorithmType) bool {
+	for _, a := range p.Algorithms {
+		if a == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Requires - алгоритмы, которые изменение patch включает из выключенного состояния current и которые требуют подтверждения
+func (p ApprovalPolicy) Requires(current AlgorithmStatus, patch AlgorithmPatch) []AlgorithmType {
+	var gated []AlgorithmType
+	for _, a := range p.Algorithms {
+		if patch.Enables(a) && !current.Enabled(a) {
+			gated = append(gated, a)
+		}
+	}
+	return gated
+}
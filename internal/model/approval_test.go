@@ -0,0 +1,41 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApprovalPolicy_Requires(t *testing.T) {
+	on, off := true, false
+	policy, err := NewApprovalPolicy([]string{"hft"}, "admin", time.Hour)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		current  AlgorithmStatus
+		patch    AlgorithmPatch
+		expected []AlgorithmType
+	}{
+		{name: "enable hft", patch: AlgorithmPatch{HFT: &on}, expected: []AlgorithmType{AlgorithmHFT}},
+		{name: "hft already on", current: AlgorithmStatus{HFT: true}, patch: AlgorithmPatch{HFT: &on}},
+		{name: "disable hft", current: AlgorithmStatus{HFT: true}, patch: AlgorithmPatch{HFT: &off}},
+		{name: "not gated", patch: AlgorithmPatch{VWAP: &on, TWAP: &on}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, policy.Requires(tt.current, tt.patch))
+		})
+	}
+}
+
+func TestNewApprovalPolicy(t *testing.T) {
+	_, err := NewApprovalPolicy([]string{"scalping"}, "admin", time.Hour)
+	assert.Error(t, err)
+	_, err = NewApprovalPolicy([]string{"hft"}, "root", time.Hour)
+	assert.Error(t, err)
+	p, err := NewApprovalPolicy(nil, "admin", time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, p.Gated(AlgorithmHFT))
+}
@@ -0,0 +1,139 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// AuditAction - действие над сущностью в журнале аудита
+type AuditAction string
+
+const (
+	AuditCreate   AuditAction = "create"
+	AuditUpdate   AuditAction = "update"
+	AuditPatch    AuditAction = "patch"
+	AuditDelete   AuditAction = "delete"
+	AuditBulk     AuditAction = "bulk"
+	AuditRaise    AuditAction = "raise"
+	AuditConfirm  AuditAction = "confirm"
+	AuditReset    AuditAction = "reset"
+	AuditResume   AuditAction = "resume"
+	AuditCancel   AuditAction = "cancel"
+	AuditApply    AuditAction = "apply"
+	AuditApprove  AuditAction = "approve"
+	AuditReject   AuditAction = "reject"
+	AuditRevoke   AuditAction = "revoke"
+	AuditRollback AuditAction = "rollback"
+	AuditRestore  AuditAction = "restore"
+	AuditPurge    AuditAction = "purge"
+	AuditRestart  AuditAction = "restart"
+)
+
+// Сущности журнала аудита
+const (
+	EntityClient          = "client"
+	EntityAlgorithms      = "algorithm_status"
+	EntitySuspension      = "suspension"
+	EntityFreeze          = "sync_freeze"
+	EntitySyncGuard       = "sync_guard"
+	EntityHalt            = "halt"
+	EntitySchedule        = "schedule"
+	EntityScheduledChange = "scheduled_change"
+	EntityAPIKey          = "api_key"
+	EntityApproval        = "approval"
+)
+
+// AuditChange - значение поля до и после изменения, null - поля не было
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditEntry - запись журнала аудита. Записи только добавляются, в одной транзакции с изменением.
+type AuditEntry struct {
+	ID        int64                  `json:"id"`
+	Actor     string                 `json:"actor"`
+	Action    AuditAction            `json:"action"`
+	Entity    string                 `json:"entity"`
+	EntityID  int64                  `json:"entity_id,omitempty"`
+	ClientID  int64                  `json:"client_id,omitempty"`
+	Diff      map[string]AuditChange `json:"diff"`
+	RequestID string                 `json:"request_id,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// AuditFilter - фильтры журнала аудита, нулевые значения не фильтруют. BeforeID - страница записей старше id.
+type AuditFilter struct {
+	ClientID int64
+	Actor    string
+	From     time.Time
+	To       time.Time
+	BeforeID int64
+	Limit    int
+}
+
+// Diff - изменённые поля между JSON представлениями before и after. nil before - создание, nil after - удаление.
+func Diff(before, after any) (map[string]AuditChange, error) {
+	b, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+	diff := make(map[string]AuditChange)
+	for k, v := range a {
+		if old, ok := b[k]; !ok || !reflect.DeepEqual(old, v) {
+			diff[k] = AuditChange{Before: old, After: v}
+		}
+	}
+	for k, v := range b {
+		if _, ok := a[k]; !ok {
+			diff[k] = AuditChange{Before: v}
+		}
+	}
+	return diff, nil
+}
+
+// jsonFields - поля JSON объекта v
+func jsonFields(v any) (map[string]any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
+
+// Origin - кто и каким запросом выполняет изменение, пишется в журнал аудита
+type Origin struct {
+	Actor     string
+	RequestID string
+}
+
+// systemActor - автор изменений без Origin в контексте
+const systemActor = "system"
+
+// originKey - ключ Origin в контексте
+type originKey struct{}
+
+// WithOrigin - контекст с автором изменения для журнала аудита
+func WithOrigin(ctx context.Context, o Origin) context.Context {
+	return context.WithValue(ctx, originKey{}, o)
+}
+
+// OriginFromContext - автор изменения из контекста, без него - system
+func OriginFromContext(ctx context.Context) Origin {
+	o, _ := ctx.Value(originKey{}).(Origin)
+	if o.Actor == "" {
+		o.Actor = systemActor
+	}
+	return o
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		before   any
+		after    any
+		expected map[string]AuditChange
+	}{
+		{
+			name:     "changed fields only",
+			before:   &AlgorithmStatus{AlgorithmID: 1, ClientID: 42, VWAP: true},
+			after:    &AlgorithmStatus{AlgorithmID: 1, ClientID: 42, VWAP: true, HFT: true},
+			expected: map[string]AuditChange{"hft": {Before: false, After: true}},
+		},
+		{
+			name:     "create",
+			after:    &Suspension{ClientID: 42, Suspended: true},
+			expected: map[string]AuditChange{"client_id": {After: float64(42)}, "suspended": {After: true}},
+		},
+		{
+			name:     "delete",
+			before:   &Suspension{ClientID: 42},
+			expected: map[string]AuditChange{"client_id": {Before: float64(42)}, "suspended": {Before: false}},
+		},
+		{
+			name:     "omitted field",
+			before:   &Suspension{ClientID: 42, Suspended: true, Reason: "upgrade"},
+			after:    &Suspension{ClientID: 42},
+			expected: map[string]AuditChange{"suspended": {Before: true, After: false}, "reason": {Before: "upgrade"}},
+		},
+		{
+			name:     "no changes",
+			before:   &Freeze{Frozen: true},
+			after:    &Freeze{Frozen: true},
+			expected: map[string]AuditChange{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := Diff(tt.before, tt.after)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, diff)
+		})
+	}
+}
+
+func TestOriginFromContext(t *testing.T) {
+	assert.Equal(t, Origin{Actor: "system"}, OriginFromContext(context.Background()))
+	ctx := WithOrigin(context.Background(), Origin{Actor: "alice", RequestID: "req-1"})
+	assert.Equal(t, Origin{Actor: "alice", RequestID: "req-1"}, OriginFromContext(ctx))
+}
@@ -0,0 +1,107 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Role - роль вызывающего API, роли упорядочены по правам: admin может всё, что operator, operator - всё, что viewer
+type Role string
+
+const (
+	// RoleViewer - только чтение
+	RoleViewer Role = "viewer"
+	// RoleOperator - изменение клиентов, алгоритмов, расписаний и экстренная остановка
+	RoleOperator Role = "operator"
+	// RoleAdmin - управление ключами API, подтверждение массового удаления и снятие остановок
+	RoleAdmin Role = "admin"
+)
+
+// roleRanks - порядок ролей по правам
+var roleRanks = map[Role]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// Valid - известна ли роль
+func (r Role) Valid() bool {
+	return roleRanks[r] > 0
+}
+
+// Allows - достаточно ли роли для операции, требующей роль required
+func (r Role) Allows(required Role) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[required]
+}
+
+// AuthMethod - способ аутентификации вызывающего
+type AuthMethod string
+
+const (
+	// AuthNone - аутентификация выключена в конфиге
+	AuthNone   AuthMethod = "none"
+	AuthAPIKey AuthMethod = "api_key"
+	AuthJWT    AuthMethod = "jwt"
+)
+
+// Principal - аутентифицированный вызывающий API. Subject однозначен между способами аутентификации:
+// apikey:<id>:<name> для ключа API (имя ключа не уникально, id - да), jwt:<sub> для JWT.
+type Principal struct {
+	Subject string     `json:"subject"`
+	Role    Role       `json:"role"`
+	Method  AuthMethod `json:"method"`
+	Scope   Scope      `json:"scope"`
+}
+
+// APIKeySubject - subject вызывающего по ключу API
+func APIKeySubject(id int64, name string) string {
+	return fmt.Sprintf("apikey:%d:%s", id, name)
+}
+
+// JWTSubject - subject вызывающего по JWT
+func JWTSubject(sub string) string {
+	return "jwt:" + sub
+}
+
+// PrincipalIdentity - кто стоит за subject для правила двух лиц. Ключи API с одним именем выпущены одному
+// вызывающему: второй ключ не позволяет подтвердить собственный запрос. Subject JWT и прочие - как есть,
+// префикс не даёт спутать ключ API с JWT того же имени.
+func PrincipalIdentity(subject string) string {
+	if rest, ok := strings.CutPrefix(subject, "apikey:"); ok {
+		if _, name, ok := strings.Cut(rest, ":"); ok {
+			return "apikey:" + name
+		}
+	}
+	return subject
+}
+
+// APIKey - ключ API. Сам ключ не хранится, в БД только его SHA-256 и префикс для опознания в списках.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Role       Role       `json:"role"`
+	Scope      Scope      `json:"scope"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreateAPIKeyRequest - тело запроса выпуска ключа
+type CreateAPIKeyRequest struct {
+	Name  string `json:"name"`
+	Role  Role   `json:"role"`
+	Scope Scope  `json:"scope"`
+}
+
+// CreatedAPIKey - выпущенный ключ, Key показывается только в этом ответе
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// LogLevel - уровень лога сервиса: debug, info, warn, error
+type LogLevel struct {
+	Level string `json:"level"`
+}
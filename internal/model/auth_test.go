@@ -0,0 +1,27 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrincipalIdentity(t *testing.T) {
+	tests := []struct {
+		name     string
+		subject  string
+		expected string
+	}{
+		{name: "api key", subject: APIKeySubject(3, "alice"), expected: "apikey:alice"},
+		{name: "second api key", subject: APIKeySubject(4, "alice"), expected: "apikey:alice"},
+		{name: "name with colon", subject: APIKeySubject(5, "ops:bot"), expected: "apikey:ops:bot"},
+		{name: "jwt", subject: JWTSubject("alice"), expected: "jwt:alice"},
+		{name: "jwt subject that looks like a key", subject: JWTSubject("apikey:3:alice"), expected: "jwt:apikey:3:alice"},
+		{name: "without auth", subject: "anonymous", expected: "anonymous"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, PrincipalIdentity(tt.subject))
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package model
+
+// BulkMode - режим пакетного изменения
+type BulkMode string
+
+const (
+	// BulkAtomic - все изменения применяются в одной транзакции, при первой ошибке откатываются все
+	BulkAtomic BulkMode = "atomic"
+	// BulkBestEffort - ошибочные изменения пропускаются, остальные применяются
+	BulkBestEffort BulkMode = "best_effort"
+)
+
+// AlgorithmChange - включение или выключение одного алгоритма клиента
+type AlgorithmChange struct {
+	ClientID  int64         `json:"client_id"`
+	Algorithm AlgorithmType `json:"algorithm"`
+	Enabled   bool          `json:"enabled"`
+}
+
+// BulkAlgorithmRequest - тело запроса пакетного изменения алгоритмов
+type BulkAlgorithmRequest struct {
+	Mode    BulkMode          `json:"mode"`
+	Changes []AlgorithmChange `json:"changes"`
+}
+
+// BulkItemStatus - итог одного изменения пакета
+type BulkItemStatus string
+
+const (
+	BulkApplied    BulkItemStatus = "applied"
+	BulkFailed     BulkItemStatus = "failed"
+	BulkRolledBack BulkItemStatus = "rolled_back"
+	BulkSkipped    BulkItemStatus = "skipped"
+)
+
+// BulkItemResult - итог изменения с индексом в запросе. State - статусы алгоритмов клиента после изменения.
+type BulkItemResult struct {
+	Index int `json:"index"`
+	AlgorithmChange
+	Status BulkItemStatus   `json:"status"`
+	Code   string           `json:"code,omitempty"`
+	State  *AlgorithmStatus `json:"state,omitempty"`
+}
+
+// BulkResult - итог пакетного изменения, Applied - зафиксирована ли транзакция
+type BulkResult struct {
+	Mode    BulkMode         `json:"mode"`
+	Applied bool             `json:"applied"`
+	Results []BulkItemResult `json:"results"`
+}
+
+// Failed - количество неприменённых изменений
+func (r BulkResult) Failed() int {
+	n := 0
+	for _, item := range r.Results {
+		if item.Status != BulkApplied {
+			n++
+		}
+	}
+	return n
+}
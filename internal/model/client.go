@@ -0,0 +1,135 @@
+package model
+
+import "time"
+
+// ClientSpec - поля клиента, которые задаёт пользователь API.
+// Id, время создания, изменения и запуска pod'ов выставляют только БД и синкер.
+type ClientSpec struct {
+	ClientName  string  `json:"client_name"`
+	Version     int     `json:"version"`
+	Image       string  `json:"image"`
+	CPU         string  `json:"cpu"`
+	Memory      string  `json:"memory"`
+	Priority    float64 `json:"priority"`
+	NeedRestart bool    `json:"needRestart"`
+	// Tags - метки клиента, по ним вызывающим API выдаётся доступ к группам клиентов
+	Tags []string `json:"tags,omitempty"`
+}
+
+// CreateClientRequest - тело запроса создания клиента
+type CreateClientRequest struct {
+	ClientSpec
+}
+
+// Client - клиент для записи в БД
+func (r CreateClientRequest) Client() *Client {
+	return r.ClientSpec.client(0)
+}
+
+// UpdateClientRequest - тело запроса изменения клиента
+type UpdateClientRequest struct {
+	ID int64 `json:"id"`
+	ClientSpec
+}
+
+// Client - клиент для записи в БД
+func (r UpdateClientRequest) Client() *Client {
+	return r.ClientSpec.client(r.ID)
+}
+
+// client - клиент с полями спецификации, серверные поля не заполнены
+func (s ClientSpec) client(id int64) *Client {
+	return &Client{
+		ID:          id,
+		ClientName:  s.ClientName,
+		Version:     s.Version,
+		Image:       s.Image,
+		CPU:         s.CPU,
+		Memory:      s.Memory,
+		Priority:    s.Priority,
+		NeedRestart: s.NeedRestart,
+		Tags:        s.Tags,
+	}
+}
+
+// ClientResponse - клиент в ответах API. SpawnedAt пустой, пока синкер не запускал pod'ы клиента.
+type ClientResponse struct {
+	ID int64 `json:"id"`
+	ClientSpec
+	DeletionProtected bool       `json:"deletion_protected"`
+	SpawnedAt         *time.Time `json:"spawned_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	Revision          int64      `json:"revision"`
+}
+
+// NewClientResponse - представление клиента из БД для ответа API
+func NewClientResponse(c *Client) ClientResponse {
+	resp := ClientResponse{
+		ID: c.ID,
+		ClientSpec: ClientSpec{
+			ClientName:  c.ClientName,
+			Version:     c.Version,
+			Image:       c.Image,
+			CPU:         c.CPU,
+			Memory:      c.Memory,
+			Priority:    c.Priority,
+			NeedRestart: c.NeedRestart,
+			Tags:        c.Tags,
+		},
+		DeletionProtected: c.DeletionProtected,
+		CreatedAt:         c.CreatedAt,
+		UpdatedAt:         c.UpdatedAt,
+		Revision:          c.Revision,
+	}
+	if !c.SpawnedAt.IsZero() {
+		spawnedAt := c.SpawnedAt
+		resp.SpawnedAt = &spawnedAt
+	}
+	return resp
+}
+
+// ClientPatch - частичное изменение клиента (JSON Merge Patch), nil - поле не меняется
+type ClientPatch struct {
+	ClientName  *string   `json:"client_name"`
+	Version     *int      `json:"version"`
+	Image       *string   `json:"image"`
+	CPU         *string   `json:"cpu"`
+	Memory      *string   `json:"memory"`
+	Priority    *float64  `json:"priority"`
+	NeedRestart *bool     `json:"needRestart"`
+	Tags        *[]string `json:"tags"`
+}
+
+// Apply - применение изменения к клиенту
+func (p ClientPatch) Apply(c *Client) {
+	if p.ClientName != nil {
+		c.ClientName = *p.ClientName
+	}
+	if p.Version != nil {
+		c.Version = *p.Version
+	}
+	if p.Image != nil {
+		c.Image = *p.Image
+	}
+	if p.CPU != nil {
+		c.CPU = *p.CPU
+	}
+	if p.Memory != nil {
+		c.Memory = *p.Memory
+	}
+	if p.Priority != nil {
+		c.Priority = *p.Priority
+	}
+	if p.NeedRestart != nil {
+		c.NeedRestart = *p.NeedRestart
+	}
+	if p.Tags != nil {
+		c.Tags = *p.Tags
+	}
+}
+
+// DeletionProtection - тело запроса установки или снятия защиты клиента от удаления
+type DeletionProtection struct {
+	Protected bool `json:"protected"`
+}
@@ -1,8 +1,118 @@
 package model
 
-import "errors"
+import (
+	"strings"
+)
+
+// ErrorKind - категория ошибки, по ней API выбирает HTTP статус
+type ErrorKind string
+
+const (
+	KindBadRequest   ErrorKind = "bad_request"
+	KindTooLarge     ErrorKind = "too_large"
+	KindUnauthorized ErrorKind = "unauthorized"
+	KindForbidden    ErrorKind = "forbidden"
+	KindNotFound     ErrorKind = "not_found"
+	KindMethod       ErrorKind = "method_not_allowed"
+	KindConflict     ErrorKind = "conflict"
+	KindValidation   ErrorKind = "validation"
+	KindPrecondition ErrorKind = "precondition_failed"
+	KindUnavailable  ErrorKind = "unavailable"
+	KindInternal     ErrorKind = "internal"
+)
+
+// Error - ошибка с категорией и стабильным машиночитаемым кодом.
+// Коды - часть API, менять их нельзя.
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+}
+
+// NewError - конструктор ошибки
+func NewError(kind ErrorKind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
 
 var (
-	ErrorClientConflict = errors.New("client name already exists")
-	ErrorNoClients      = errors.New("no one clients")
+	ErrorClientConflict         = NewError(KindConflict, "client_conflict", "client name already exists")
+	ErrorNoClients              = NewError(KindNotFound, "no_clients", "no one clients")
+	ErrorNoSyncAlert            = NewError(KindConflict, "no_sync_alert", "no active sync guard alert")
+	ErrorHaltNotFound           = NewError(KindNotFound, "halt_not_found", "active halt not found")
+	ErrorClientNotFound         = NewError(KindNotFound, "client_not_found", "client not found")
+	ErrorRevisionMismatch       = NewError(KindPrecondition, "revision_mismatch", "client was modified, revision does not match If-Match")
+	ErrorInvalidIfMatch         = NewError(KindPrecondition, "invalid_if_match", "If-Match must be an ETag of the client")
+	ErrorNoSyncStatus           = NewError(KindNotFound, "no_sync_status", "no sync passes yet")
+	ErrorNoSchedule             = NewError(KindNotFound, "schedule_not_found", "schedule not found")
+	ErrorNoPendingChange        = NewError(KindNotFound, "change_not_found", "pending change not found")
+	ErrorUnknownAlgorithm       = NewError(KindNotFound, "algorithm_not_found", "unknown algorithm type")
+	ErrorRouteNotFound          = NewError(KindNotFound, "route_not_found", "route not found")
+	ErrorMethodNotAllowed       = NewError(KindMethod, "method_not_allowed", "method is not allowed for this route")
+	ErrorInvalidBody            = NewError(KindBadRequest, "invalid_body", "invalid request body")
+	ErrorBodyTooLarge           = NewError(KindTooLarge, "body_too_large", "request body is too large")
+	ErrorInvalidID              = NewError(KindBadRequest, "invalid_id", "invalid id")
+	ErrorInvalidQuery           = NewError(KindBadRequest, "invalid_query", "invalid query parameter")
+	ErrorUnavailable            = NewError(KindUnavailable, "unavailable", "dependency is unavailable")
+	ErrorPodsNotDeleted         = NewError(KindUnavailable, "pods_not_deleted", "halt is latched, failed to delete pods")
+	ErrorInvalidIdempotencyKey  = NewError(KindBadRequest, "invalid_idempotency_key", "Idempotency-Key must be 1 to 255 characters")
+	ErrorIdempotencyKeyReused   = NewError(KindValidation, "idempotency_key_reused", "Idempotency-Key was already used with a different request")
+	ErrorIdempotencyInProgress  = NewError(KindConflict, "idempotency_in_progress", "request with this Idempotency-Key is still in progress")
+	ErrorUnauthorized           = NewError(KindUnauthorized, "unauthorized", "missing or invalid credentials")
+	ErrorForbidden              = NewError(KindForbidden, "forbidden", "role does not allow this operation")
+	ErrorOutOfScope             = NewError(KindForbidden, "out_of_scope", "operation is outside the caller's client scope")
+	ErrorAPIKeyNotFound         = NewError(KindNotFound, "api_key_not_found", "active api key not found")
+	ErrorApprovalNotFound       = NewError(KindNotFound, "approval_not_found", "approval request not found")
+	ErrorApprovalNotPending     = NewError(KindConflict, "approval_not_pending", "approval request is already decided or expired")
+	ErrorSelfApproval           = NewError(KindForbidden, "self_approval", "approval request must be approved by another principal")
+	ErrorApprovalRequired       = NewError(KindValidation, "approval_required", "change enables an algorithm that requires an approved request")
+	ErrorClientRevisionNotFound = NewError(KindNotFound, "client_revision_not_found", "client revision not found")
+	ErrorDeletionProtected      = NewError(KindConflict, "deletion_protected", "client is protected from deletion, clear protection first")
+	ErrorInternal               = NewError(KindInternal, "internal", "internal server error")
 )
+
+// FieldError - ошибка валидации одного поля запроса
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// ValidationError - ошибки валидации запроса по всем полям
+type ValidationError struct {
+	Fields []FieldError `json:"errors"`
+}
+
+// Add - добавление ошибки поля
+func (e *ValidationError) Add(field, reason string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Reason: reason})
+}
+
+// Err - nil, если ошибок нет
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, f.Field+": "+f.Reason)
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Problem - тело ответа об ошибке в формате application/problem+json (RFC 9457)
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
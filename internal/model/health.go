@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// CheckStatus - итог проверки зависимости
+type CheckStatus string
+
+const (
+	CheckOK   CheckStatus = "ok"
+	CheckFail CheckStatus = "fail"
+)
+
+// CheckResult - итог проверки одной зависимости для /readyz
+type CheckResult struct {
+	Status    CheckStatus `json:"status"`
+	LatencyMS int64       `json:"latency_ms"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// SyncReadiness - состояние синхронизации реплики. Не влияет на готовность:
+// реплика, которая не ведёт синхронизацию, обслуживает API так же, как ведущая.
+type SyncReadiness struct {
+	Leader        bool       `json:"leader"`
+	LastResult    SyncResult `json:"last_result,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+}
+
+// Readiness - ответ /readyz. Ready=false, если реплика останавливается или хотя бы одна проверка не прошла.
+type Readiness struct {
+	Ready    bool                   `json:"ready"`
+	Draining bool                   `json:"draining"`
+	Checks   map[string]CheckResult `json:"checks"`
+	Sync     SyncReadiness          `json:"sync"`
+}
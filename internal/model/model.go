@@ -2,19 +2,52 @@ package model
 
 import "time"
 
-// Client - структура клиента.
+// AlgorithmType - тип алгоритма
+type AlgorithmType string
+
+const (
+	AlgorithmVWAP AlgorithmType = "vwap"
+	AlgorithmTWAP AlgorithmType = "twap"
+	AlgorithmHFT  AlgorithmType = "hft"
+)
+
+// AlgorithmTypes - все поддерживаемые типы алгоритмов
+var AlgorithmTypes = []AlgorithmType{AlgorithmVWAP, AlgorithmTWAP, AlgorithmHFT}
+
+// Valid - поддерживается ли тип алгоритма
+func (t AlgorithmType) Valid() bool {
+	for _, v := range AlgorithmTypes {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Client - структура клиента в БД. В API используются CreateClientRequest, UpdateClientRequest и ClientResponse.
 type Client struct {
-	ID          int64     `json:"id"`
-	ClientName  string    `json:"client_name"`
-	Version     int       `json:"version"`
-	Image       string    `json:"image"`
-	CPU         string    `json:"cpu"`
-	Memory      string    `json:"memory"`
-	Priority    float64   `json:"priority"`
-	NeedRestart bool      `json:"needRestart"`
-	SpawnedAt   time.Time `json:"spawned_at"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int64    `json:"id"`
+	ClientName  string   `json:"client_name"`
+	Version     int      `json:"version"`
+	Image       string   `json:"image"`
+	CPU         string   `json:"cpu"`
+	Memory      string   `json:"memory"`
+	Priority    float64  `json:"priority"`
+	NeedRestart bool     `json:"needRestart"`
+	Tags        []string `json:"tags"`
+	// DeletionProtected - защита от удаления, пока флаг стоит, DELETE клиента отклоняется
+	DeletionProtected bool      `json:"deletion_protected"`
+	SpawnedAt         time.Time `json:"spawned_at"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	// Revision - ревизия строки, увеличивается при каждом изменении. При обновлении - ожидаемая ревизия, 0 - без проверки.
+	Revision int64 `json:"revision"`
+}
+
+// RolloutChanged - отличается ли от prev то, из чего запускаются pod'ы клиента: образ, версия и ресурсы.
+// После такого изменения запущенные pod'ы клиента нужно пересоздать.
+func (c *Client) RolloutChanged(prev *Client) bool {
+	return c.Image != prev.Image || c.Version != prev.Version || c.CPU != prev.CPU || c.Memory != prev.Memory
 }
 
 // AlgorithmStatus - структура алгоритмов
@@ -25,3 +58,228 @@ type AlgorithmStatus struct {
 	TWAP        bool  `json:"twap"`
 	HFT         bool  `json:"hft"`
 }
+
+// AlgorithmPatch - частичное изменение статусов алгоритмов клиента (JSON Merge Patch), nil - флаг не меняется
+type AlgorithmPatch struct {
+	VWAP *bool `json:"vwap,omitempty"`
+	TWAP *bool `json:"twap,omitempty"`
+	HFT  *bool `json:"hft,omitempty"`
+}
+
+// NewAlgorithmPatch - изменение одного флага алгоритма
+func NewAlgorithmPatch(t AlgorithmType, enabled bool) *AlgorithmPatch {
+	var p AlgorithmPatch
+	switch t {
+	case AlgorithmVWAP:
+		p.VWAP = &enabled
+	case AlgorithmTWAP:
+		p.TWAP = &enabled
+	case AlgorithmHFT:
+		p.HFT = &enabled
+	}
+	return &p
+}
+
+// Enables - включает ли изменение алгоритм указанного типа
+func (p AlgorithmPatch) Enables(t AlgorithmType) bool {
+	var v *bool
+	switch t {
+	case AlgorithmVWAP:
+		v = p.VWAP
+	case AlgorithmTWAP:
+		v = p.TWAP
+	case AlgorithmHFT:
+		v = p.HFT
+	}
+	return v != nil && *v
+}
+
+// Pod - pod алгоритма клиента для деплоера: имя и спецификация клиента, из которой он запускается
+type Pod struct {
+	Name     string
+	ClientID int64
+	Image    string
+	Version  int
+	CPU      string
+	Memory   string
+}
+
+// NewPod - pod алгоритма с именем name по спецификации клиента
+func NewPod(name string, c *Client) Pod {
+	return Pod{Name: name, ClientID: c.ID, Image: c.Image, Version: c.Version, CPU: c.CPU, Memory: c.Memory}
+}
+
+// PodState - наблюдаемое состояние pod'а алгоритма в кластере
+type PodState struct {
+	Algorithm AlgorithmType `json:"algorithm"`
+	Pod       string        `json:"pod"`
+	Running   bool          `json:"running"`
+}
+
+// AlgorithmState - желаемое состояние алгоритмов клиента из БД и наблюдаемые pod'ы.
+// ObserveError заполняется, если список pod'ов получить не удалось.
+type AlgorithmState struct {
+	Desired      AlgorithmStatus `json:"desired"`
+	Observed     []PodState      `json:"observed"`
+	ObserveError string          `json:"observe_error,omitempty"`
+}
+
+// Enabled - включен ли алгоритм указанного типа
+func (a AlgorithmStatus) Enabled(t AlgorithmType) bool {
+	switch t {
+	case AlgorithmVWAP:
+		return a.VWAP
+	case AlgorithmTWAP:
+		return a.TWAP
+	case AlgorithmHFT:
+		return a.HFT
+	}
+	return false
+}
+
+// SyncGuard - состояние защиты от массового удаления pod'ов
+type SyncGuard struct {
+	Alert       bool       `json:"alert"`
+	Pods        []string   `json:"pods"`
+	ManagedPods int        `json:"managed_pods"`
+	Reason      string     `json:"reason"`
+	RaisedAt    *time.Time `json:"raised_at,omitempty"`
+	Confirmed   bool       `json:"confirmed"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+}
+
+// HaltScope - область действия экстренной остановки
+type HaltScope string
+
+const (
+	HaltGlobal    HaltScope = "global"
+	HaltClient    HaltScope = "client"
+	HaltAlgorithm HaltScope = "algorithm"
+)
+
+// Halt - экстренная остановка алгоритмов (kill switch).
+// Действует до явного возобновления, записи не удаляются и служат журналом срабатываний.
+type Halt struct {
+	ID          int64         `json:"id"`
+	Scope       HaltScope     `json:"scope"`
+	ClientID    int64         `json:"client_id,omitempty"`
+	Algorithm   AlgorithmType `json:"algorithm,omitempty"`
+	Reason      string        `json:"reason"`
+	TriggeredBy string        `json:"triggered_by"`
+	CreatedAt   time.Time     `json:"created_at"`
+	ResumedAt   *time.Time    `json:"resumed_at,omitempty"`
+	ResumedBy   string        `json:"resumed_by,omitempty"`
+}
+
+// Matches - попадает ли алгоритм клиента под остановку.
+// Для остановки по типу алгоритма без client_id останавливается тип у всех клиентов.
+func (h Halt) Matches(clientID int64, t AlgorithmType) bool {
+	switch h.Scope {
+	case HaltGlobal:
+		return true
+	case HaltClient:
+		return h.ClientID == clientID
+	case HaltAlgorithm:
+		return h.Algorithm == t && (h.ClientID == 0 || h.ClientID == clientID)
+	}
+	return false
+}
+
+// KillSwitchResult - результат срабатывания kill switch
+type KillSwitchResult struct {
+	Halt        Halt     `json:"halt"`
+	DeletedPods []string `json:"deleted_pods"`
+	FailedPods  []string `json:"failed_pods"`
+}
+
+// Suspension - приостановка синхронизации клиента, Until - время автоматического снятия
+type Suspension struct {
+	ClientID  int64      `json:"client_id"`
+	Suspended bool       `json:"suspended"`
+	Until     *time.Time `json:"until,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// Freeze - глобальная заморозка синхронизации, Until - время автоматического снятия
+type Freeze struct {
+	Frozen    bool       `json:"frozen"`
+	Until     *time.Time `json:"until,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// Active - действует ли заморозка на момент now
+func (f Freeze) Active(now time.Time) bool {
+	return f.Frozen && (f.Until == nil || f.Until.After(now))
+}
+
+// SyncResult - итог прохода синхронизации
+type SyncResult string
+
+const (
+	SyncOK      SyncResult = "ok"
+	SyncFrozen  SyncResult = "frozen"
+	SyncBlocked SyncResult = "guard_blocked"
+	SyncFailed  SyncResult = "failed"
+)
+
+// SyncStatus - итог последнего прохода синхронизации.
+// LastSuccessAt - окончание последнего прохода с итогом ok, он сохраняется и после неуспешных проходов.
+type SyncStatus struct {
+	Result         SyncResult `json:"result"`
+	StartedAt      time.Time  `json:"started_at"`
+	FinishedAt     time.Time  `json:"finished_at"`
+	Created        int        `json:"created"`
+	Deleted        int        `json:"deleted"`
+	SkippedClients []int64    `json:"skipped_clients"`
+	Error          string     `json:"error,omitempty"`
+	LastSuccessAt  *time.Time `json:"last_success_at,omitempty"`
+}
+
+// SyncEvent - событие синхронизации, которое реплики пересылают друг другу через LISTEN/NOTIFY
+type SyncEvent string
+
+const (
+	// SyncEventTrigger - запрос внепланового прохода, пришедший на неведущую реплику
+	SyncEventTrigger SyncEvent = "trigger"
+	// SyncEventHalt - добавлена остановка kill switch, идущий проход ведущей отменяется
+	SyncEventHalt SyncEvent = "halt"
+)
+
+// ChangeStatus - состояние отложенного изменения
+type ChangeStatus string
+
+const (
+	ChangePending   ChangeStatus = "pending"
+	ChangeApplied   ChangeStatus = "applied"
+	ChangeCancelled ChangeStatus = "cancelled"
+	ChangeFailed    ChangeStatus = "failed"
+)
+
+// ScheduledChange - отложенное включение или выключение алгоритма клиента в момент ApplyAt.
+// Attempts - неудачные попытки применения из-за временных сбоев, RetryAt - время следующей попытки.
+type ScheduledChange struct {
+	ID        int64         `json:"id"`
+	ClientID  int64         `json:"client_id"`
+	Algorithm AlgorithmType `json:"algorithm"`
+	Enabled   bool          `json:"enabled"`
+	ApplyAt   time.Time     `json:"apply_at"`
+	Status    ChangeStatus  `json:"status"`
+	CreatedBy string        `json:"created_by,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	AppliedAt *time.Time    `json:"applied_at,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Attempts  int           `json:"attempts,omitempty"`
+	RetryAt   *time.Time    `json:"retry_at,omitempty"`
+}
+
+// IdempotencyRecord - сохранённый ответ на запрос с заголовком Idempotency-Key.
+// Status 0 - первый запрос с этим ключом ещё выполняется.
+type IdempotencyRecord struct {
+	Key         string
+	RequestHash string
+	Status      int
+	Headers     map[string]string
+	Body        []byte
+	ExpiresAt   time.Time
+}
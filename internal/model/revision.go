@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// ClientSnapshot - восстанавливаемое состояние клиента: спецификация и флаги алгоритмов
+type ClientSnapshot struct {
+	ClientSpec
+	VWAP bool `json:"vwap"`
+	TWAP bool `json:"twap"`
+	HFT  bool `json:"hft"`
+}
+
+// NewClientSnapshot - снимок клиента и статусов его алгоритмов
+func NewClientSnapshot(c *Client, as *AlgorithmStatus) ClientSnapshot {
+	return ClientSnapshot{
+		ClientSpec: NewClientResponse(c).ClientSpec,
+		VWAP:       as.VWAP,
+		TWAP:       as.TWAP,
+		HFT:        as.HFT,
+	}
+}
+
+// Algorithms - изменение, возвращающее флаги алгоритмов из снимка
+func (s ClientSnapshot) Algorithms() *AlgorithmPatch {
+	vwap, twap, hft := s.VWAP, s.TWAP, s.HFT
+	return &AlgorithmPatch{VWAP: &vwap, TWAP: &twap, HFT: &hft}
+}
+
+// ClientRevision - ревизия клиента: снимок после изменения. Номера ревизий растут с 1 для каждого клиента.
+// ClientRevision - ревизия строки клиента из ETag на момент изменения, у ревизий до её появления - 0.
+// Diff - отличия от предыдущей ревизии, у первой - все поля.
+type ClientRevision struct {
+	ClientID       int64                  `json:"client_id"`
+	Revision       int64                  `json:"revision"`
+	ClientRevision int64                  `json:"client_revision,omitempty"`
+	Snapshot       ClientSnapshot         `json:"snapshot"`
+	Diff           map[string]AuditChange `json:"diff"`
+	CreatedBy      string                 `json:"created_by"`
+	RequestID      string                 `json:"request_id,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
+}
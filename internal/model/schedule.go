@@ -0,0 +1,160 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	clockLayout = "15:04"
+	dateLayout  = "2006-01-02"
+)
+
+// weekdays - сокращённые названия дней недели в расписании
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Schedule - торговая сессия клиента или отдельного алгоритма клиента.
+// Сессия открыта в дни Days (пусто - каждый день) с Open до Close по времени Timezone, кроме дат Holidays.
+// Если Close раньше Open, сессия переходит через полночь и относится к дню открытия.
+type Schedule struct {
+	ID        int64         `json:"id"`
+	ClientID  int64         `json:"client_id"`
+	Algorithm AlgorithmType `json:"algorithm,omitempty"`
+	Timezone  string        `json:"timezone"`
+	Days      []string      `json:"days"`
+	Open      string        `json:"open"`
+	Close     string        `json:"close"`
+	Holidays  []string      `json:"holidays"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// Validate - проверка расписания, ошибки возвращаются по полям
+func (s Schedule) Validate() error {
+	var v ValidationError
+	if _, err := time.LoadLocation(s.Timezone); err != nil || s.Timezone == "" {
+		v.Add("timezone", fmt.Sprintf("unknown timezone %q", s.Timezone))
+	}
+	open, openErr := parseClock(s.Open)
+	if openErr != nil {
+		v.Add("open", "must be HH:MM")
+	}
+	closing, closeErr := parseClock(s.Close)
+	if closeErr != nil {
+		v.Add("close", "must be HH:MM")
+	}
+	if openErr == nil && closeErr == nil && open == closing {
+		v.Add("close", "must differ from open")
+	}
+	for _, d := range s.Days {
+		if _, ok := weekdays[d]; !ok {
+			v.Add("days", fmt.Sprintf("unknown day %q", d))
+		}
+	}
+	for _, h := range s.Holidays {
+		if _, err := time.Parse(dateLayout, h); err != nil {
+			v.Add("holidays", fmt.Sprintf("invalid date %q, must be YYYY-MM-DD", h))
+		}
+	}
+	return v.Err()
+}
+
+// InSession - открыта ли сессия в момент t. Невалидное расписание считается закрытым.
+func (s Schedule) InSession(t time.Time) bool {
+	loc, open, closing, err := s.parse()
+	if err != nil {
+		return false
+	}
+	lt := t.In(loc)
+	m := lt.Hour()*60 + lt.Minute()
+	if open < closing {
+		return m >= open && m < closing && s.tradingDay(lt)
+	}
+	if m >= open {
+		return s.tradingDay(lt)
+	}
+	return m < closing && s.tradingDay(lt.AddDate(0, 0, -1))
+}
+
+// NextTransition - ближайшее после t открытие или закрытие сессии, нулевое время если его нет в ближайшую неделю
+func (s Schedule) NextTransition(t time.Time) time.Time {
+	loc, open, closing, err := s.parse()
+	if err != nil {
+		return time.Time{}
+	}
+	lt := t.In(loc)
+	var next time.Time
+	for d := -1; d <= 8; d++ {
+		day := time.Date(lt.Year(), lt.Month(), lt.Day()+d, 0, 0, 0, 0, loc)
+		if !s.tradingDay(day) {
+			continue
+		}
+		closeDay := day
+		if closing < open {
+			closeDay = day.AddDate(0, 0, 1)
+		}
+		for _, c := range []time.Time{atClock(day, open), atClock(closeDay, closing)} {
+			if c.After(t) && (next.IsZero() || c.Before(next)) {
+				next = c
+			}
+		}
+	}
+	return next
+}
+
+// parse - разбор часового пояса и времени открытия и закрытия в минутах от полуночи
+func (s Schedule) parse() (*time.Location, int, int, error) {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	open, err := parseClock(s.Open)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	closing, err := parseClock(s.Close)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return loc, open, closing, nil
+}
+
+// tradingDay - торговый ли день d по дням недели и праздникам
+func (s Schedule) tradingDay(d time.Time) bool {
+	date := d.Format(dateLayout)
+	for _, h := range s.Holidays {
+		if h == date {
+			return false
+		}
+	}
+	if len(s.Days) == 0 {
+		return true
+	}
+	for _, name := range s.Days {
+		if wd, ok := weekdays[name]; ok && wd == d.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock - разбор времени "15:04" в минуты от полуночи
+func parseClock(v string) (int, error) {
+	c, err := time.Parse(clockLayout, v)
+	if err != nil {
+		return 0, err
+	}
+	return c.Hour()*60 + c.Minute(), nil
+}
+
+// atClock - момент времени в день day через minutes минут от полуночи
+func atClock(day time.Time, minutes int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), minutes/60, minutes%60, 0, 0, day.Location())
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedule_InSession(t *testing.T) {
+	nyse := Schedule{
+		Timezone: "America/New_York",
+		Days:     []string{"mon", "tue", "wed", "thu", "fri"},
+		Open:     "09:30",
+		Close:    "16:00",
+		Holidays: []string{"2026-11-26"},
+	}
+	overnight := Schedule{
+		Timezone: "UTC",
+		Days:     []string{"mon"},
+		Open:     "22:00",
+		Close:    "06:00",
+	}
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		at       string
+		expected bool
+	}{
+		{"before open", nyse, "2026-11-02T14:29:00Z", false},
+		{"at open", nyse, "2026-11-02T14:30:00Z", true},
+		{"before close", nyse, "2026-11-02T20:59:00Z", true},
+		{"at close", nyse, "2026-11-02T21:00:00Z", false},
+		{"weekend", nyse, "2026-11-07T15:00:00Z", false},
+		{"holiday", nyse, "2026-11-26T15:00:00Z", false},
+		{"overnight evening", overnight, "2026-11-02T23:00:00Z", true},
+		{"overnight morning", overnight, "2026-11-03T05:59:00Z", true},
+		{"overnight next evening", overnight, "2026-11-03T23:00:00Z", false},
+		{"invalid", Schedule{Timezone: "Nowhere/City", Open: "09:00", Close: "10:00"}, "2026-11-02T09:30:00Z", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, err := time.Parse(time.RFC3339, tt.at)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, tt.schedule.InSession(at))
+		})
+	}
+}
+
+func TestSchedule_NextTransition(t *testing.T) {
+	s := Schedule{
+		Timezone: "Europe/Moscow",
+		Days:     []string{"mon", "tue", "wed", "thu", "fri"},
+		Open:     "10:00",
+		Close:    "18:45",
+	}
+
+	friday, _ := time.Parse(time.RFC3339, "2026-11-06T16:00:00Z")
+	monday, _ := time.Parse(time.RFC3339, "2026-11-09T07:00:00Z")
+	assert.True(t, monday.Equal(s.NextTransition(friday)))
+
+	open, _ := time.Parse(time.RFC3339, "2026-11-09T07:30:00Z")
+	closing, _ := time.Parse(time.RFC3339, "2026-11-09T15:45:00Z")
+	assert.True(t, closing.Equal(s.NextTransition(open)))
+}
+
+func TestSchedule_Validate(t *testing.T) {
+	valid := Schedule{Timezone: "UTC", Open: "09:00", Close: "17:00", Days: []string{"mon"}, Holidays: []string{"2026-01-01"}}
+	assert.NoError(t, valid.Validate())
+
+	invalid := []Schedule{
+		{Timezone: "", Open: "09:00", Close: "17:00"},
+		{Timezone: "UTC", Open: "9am", Close: "17:00"},
+		{Timezone: "UTC", Open: "09:00", Close: "09:00"},
+		{Timezone: "UTC", Open: "09:00", Close: "17:00", Days: []string{"monday"}},
+		{Timezone: "UTC", Open: "09:00", Close: "17:00", Holidays: []string{"01.01.2026"}},
+	}
+	for _, s := range invalid {
+		assert.Error(t, s.Validate())
+	}
+}
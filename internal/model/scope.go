@@ -0,0 +1,49 @@
+package model
+
+import "context"
+
+// Scope - клиенты, доступные вызывающему: перечисленные id и клиенты хотя бы с одним из тегов.
+// Пустая область - доступ ко всем клиентам.
+type Scope struct {
+	ClientIDs []int64  `json:"client_ids,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+// Unrestricted - доступны ли все клиенты
+func (s Scope) Unrestricted() bool {
+	return len(s.ClientIDs) == 0 && len(s.Tags) == 0
+}
+
+// Allows - входит ли клиент с id и тегами в область. Для нового клиента id = 0 и решают только теги.
+func (s Scope) Allows(id int64, tags []string) bool {
+	if s.Unrestricted() {
+		return true
+	}
+	for _, v := range s.ClientIDs {
+		if id != 0 && v == id {
+			return true
+		}
+	}
+	for _, t := range tags {
+		for _, v := range s.Tags {
+			if t == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// scopeKey - ключ области видимости в контексте запроса
+type scopeKey struct{}
+
+// WithScope - контекст с областью видимости вызывающего, по ней хранилище ограничивает запросы
+func WithScope(ctx context.Context, s Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, s)
+}
+
+// ScopeFromContext - область видимости из контекста. Без неё (синкер, планировщик) доступны все клиенты.
+func ScopeFromContext(ctx context.Context) Scope {
+	s, _ := ctx.Value(scopeKey{}).(Scope)
+	return s
+}
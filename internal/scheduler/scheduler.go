@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/CyrilSbrodov/syncService/cmd/loggers"
+	"github.com/CyrilSbrodov/syncService/internal/config"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/CyrilSbrodov/syncService/internal/storage"
+	"log/slog"
+	"time"
+)
+
+// SyncTrigger - интерфейс внепланового запуска синхронизации
+type SyncTrigger interface {
+	Trigger(ctx context.Context)
+}
+
+// Scheduler - структура планировщика, что применяет отложенные изменения статусов алгоритмов
+type Scheduler struct {
+	store  storage.Storage
+	sync   SyncTrigger
+	logger *loggers.Logger
+	cfg    config.Config
+}
+
+// NewScheduler - конструктор планировщика
+func NewScheduler(store storage.Storage, sync SyncTrigger, logger *loggers.Logger, cfg config.Config) *Scheduler {
+	return &Scheduler{
+		store:  store,
+		sync:   sync,
+		logger: logger,
+		cfg:    cfg,
+	}
+}
+
+// purgeInterval - период удаления истёкших ключей идемпотентности и окончательного удаления клиентов
+const purgeInterval = 10 * time.Minute
+
+// Start - функция запуска планировщика с таймером cfg.Scheduler.Interval
+func (s *Scheduler) Start() {
+	ticker := time.NewTicker(s.cfg.Scheduler.Interval)
+	purge := time.NewTicker(purgeInterval)
+	for {
+		select {
+		case <-ticker.C:
+			s.applyDueChanges()
+		case <-purge.C:
+			s.purgeIdempotencyKeys()
+			s.purgeDeletedClients()
+		}
+	}
+}
+
+// purgeIdempotencyKeys - удаление истёкших ключей идемпотентности
+func (s *Scheduler) purgeIdempotencyKeys() {
+	n, err := s.store.PurgeIdempotencyKeys(context.Background())
+	if err != nil {
+		s.logger.Error("Error purging idempotency keys", loggers.Err(err))
+		return
+	}
+	if n > 0 {
+		s.logger.Info("expired idempotency keys purged", slog.Int64("keys", n))
+	}
+}
+
+// purgeDeletedClients - окончательное удаление клиентов, удалённых раньше cfg.Clients.DeletionGrace
+func (s *Scheduler) purgeDeletedClients() {
+	ctx := model.WithOrigin(context.Background(), model.Origin{Actor: "scheduler"})
+	n, err := s.store.PurgeDeletedClients(ctx, time.Now().Add(-s.cfg.Clients.DeletionGrace))
+	if err != nil {
+		s.logger.Error("Error purging deleted clients", loggers.Err(err))
+		return
+	}
+	if n > 0 {
+		s.logger.Info("deleted clients purged", slog.Int64("clients", n))
+	}
+}
+
+// applyDueChanges - применение наступивших изменений и запуск синхронизации, если что-то применено.
+// Изменение, отложенное до повтора после временного сбоя, пишется в лог предупреждением.
+// Изменения, обработанные до ошибки хранилища, тоже учитываются.
+func (s *Scheduler) applyDueChanges() {
+	ctx := model.WithOrigin(context.Background(), model.Origin{Actor: "scheduler"})
+	changes, err := s.store.ApplyDueChanges(ctx)
+	if err != nil {
+		s.logger.Error("Error applying scheduled changes", loggers.Err(err))
+	}
+	var applied int
+	for _, c := range changes {
+		attrs := []any{slog.Int64("change", c.ID), loggers.ClientID(c.ClientID),
+			loggers.Algorithm(string(c.Algorithm)), slog.Bool("enabled", c.Enabled)}
+		switch c.Status {
+		case model.ChangeFailed:
+			s.logger.Error("scheduled change failed", append(attrs, slog.String("error", c.Error))...)
+			continue
+		case model.ChangePending:
+			s.logger.Warn("scheduled change will be retried", append(attrs, slog.Int("attempts", c.Attempts),
+				slog.String("error", c.Error))...)
+			continue
+		}
+		s.logger.Info("scheduled change applied", attrs...)
+		applied++
+	}
+	if applied > 0 {
+		s.sync.Trigger(ctx)
+	}
+}
@@ -0,0 +1,177 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/CyrilSbrodov/syncService/cmd/loggers"
+	"github.com/CyrilSbrodov/syncService/internal/config"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/CyrilSbrodov/syncService/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// changeStore - хранилище отложенных изменений в памяти: применяет наступившие к now изменения
+// в порядке apply_at и id, изменения из fail отмечает как failed, изменения из retry оставляет до повтора
+type changeStore struct {
+	storage.Storage
+	now     time.Time
+	pending []model.ScheduledChange
+	fail    map[int64]bool
+	retry   map[int64]bool
+	err     error
+}
+
+func (s *changeStore) ApplyDueChanges(ctx context.Context) ([]model.ScheduledChange, error) {
+	sort.Slice(s.pending, func(i, j int) bool {
+		if !s.pending[i].ApplyAt.Equal(s.pending[j].ApplyAt) {
+			return s.pending[i].ApplyAt.Before(s.pending[j].ApplyAt)
+		}
+		return s.pending[i].ID < s.pending[j].ID
+	})
+	var due, rest []model.ScheduledChange
+	for _, c := range s.pending {
+		if c.ApplyAt.After(s.now) {
+			rest = append(rest, c)
+			continue
+		}
+		c.Status = model.ChangeApplied
+		switch {
+		case s.fail[c.ID]:
+			c.Status, c.Error = model.ChangeFailed, "boom"
+		case s.retry[c.ID]:
+			c.Status, c.Error, c.Attempts = model.ChangePending, "deadlock detected", c.Attempts+1
+			rest = append(rest, c)
+		}
+		due = append(due, c)
+	}
+	s.pending = rest
+	return due, s.err
+}
+
+// triggerCounter - запуск синхронизации, который считает вызовы
+type triggerCounter struct {
+	calls int
+}
+
+func (t *triggerCounter) Trigger(ctx context.Context) {
+	t.calls++
+}
+
+// logLines - сообщения и id изменений из JSON лога
+func logLines(t *testing.T, buf *bytes.Buffer) []string {
+	var lines []string
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var line map[string]any
+		require.NoError(t, dec.Decode(&line))
+		msg := line["msg"].(string)
+		if id, ok := line["change"]; ok {
+			msg = fmt.Sprintf("%s %v", msg, id)
+		}
+		lines = append(lines, msg)
+	}
+	return lines
+}
+
+func TestScheduler_ApplyDueChanges(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	pending := func() []model.ScheduledChange {
+		return []model.ScheduledChange{
+			{ID: 3, ClientID: 1, Algorithm: model.AlgorithmHFT, Enabled: true, ApplyAt: now.Add(-time.Minute)},
+			{ID: 1, ClientID: 1, Algorithm: model.AlgorithmTWAP, ApplyAt: now.Add(time.Hour)},
+			{ID: 2, ClientID: 2, Algorithm: model.AlgorithmVWAP, Enabled: true, ApplyAt: now.Add(-time.Hour)},
+			{ID: 4, ClientID: 3, Algorithm: model.AlgorithmHFT, ApplyAt: now.Add(-time.Minute)},
+		}
+	}
+
+	tests := []struct {
+		name            string
+		fail            map[int64]bool
+		retry           map[int64]bool
+		err             error
+		expectedLines   []string
+		expectedTrigger int
+		expectedPending int
+	}{
+		{
+			name: "due applied in order, not due kept",
+			expectedLines: []string{"scheduled change applied 2", "scheduled change applied 3",
+				"scheduled change applied 4"},
+			expectedTrigger: 1,
+			expectedPending: 1,
+		},
+		{
+			name: "failed change does not stop others",
+			fail: map[int64]bool{2: true},
+			expectedLines: []string{"scheduled change failed 2", "scheduled change applied 3",
+				"scheduled change applied 4"},
+			expectedTrigger: 1,
+			expectedPending: 1,
+		},
+		{
+			name:  "transient failure kept for retry",
+			retry: map[int64]bool{3: true},
+			expectedLines: []string{"scheduled change applied 2", "scheduled change will be retried 3",
+				"scheduled change applied 4"},
+			expectedTrigger: 1,
+			expectedPending: 2,
+		},
+		{
+			name:            "all failed, no sync",
+			fail:            map[int64]bool{2: true, 3: true, 4: true},
+			expectedLines:   []string{"scheduled change failed 2", "scheduled change failed 3", "scheduled change failed 4"},
+			expectedPending: 1,
+		},
+		{
+			name: "storage error keeps processed changes",
+			err:  errors.New("connection reset"),
+			expectedLines: []string{"Error applying scheduled changes", "scheduled change applied 2",
+				"scheduled change applied 3", "scheduled change applied 4"},
+			expectedTrigger: 1,
+			expectedPending: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			store := &changeStore{now: now, pending: pending(), fail: tt.fail, retry: tt.retry, err: tt.err}
+			sync := &triggerCounter{}
+			logger := &loggers.Logger{Logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+			s := NewScheduler(store, sync, logger, config.Config{})
+
+			s.applyDueChanges()
+
+			assert.Equal(t, tt.expectedLines, logLines(t, &buf))
+			assert.Equal(t, tt.expectedTrigger, sync.calls)
+			assert.Len(t, store.pending, tt.expectedPending)
+		})
+	}
+}
+
+func TestScheduler_ApplyDueChanges_NothingDue(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	store := &changeStore{now: now, pending: []model.ScheduledChange{
+		{ID: 1, ClientID: 1, Algorithm: model.AlgorithmHFT, Enabled: true, ApplyAt: now.Add(time.Minute)},
+	}}
+	sync := &triggerCounter{}
+	s := NewScheduler(store, sync, &loggers.Logger{Logger: slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil))}, config.Config{})
+
+	s.applyDueChanges()
+	assert.Equal(t, 0, sync.calls)
+	assert.Len(t, store.pending, 1)
+
+	store.now = now.Add(time.Minute)
+	s.applyDueChanges()
+	assert.Equal(t, 1, sync.calls)
+	assert.Empty(t, store.pending)
+}
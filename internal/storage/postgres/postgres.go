@@ -3,11 +3,18 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/CyrilSbrodov/syncService/cmd/loggers"
 	"github.com/CyrilSbrodov/syncService/internal/config"
 	"github.com/CyrilSbrodov/syncService/internal/model"
 	"github.com/lib/pq"
 	_ "github.com/lib/pq"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,6 +23,9 @@ type PGStore struct {
 	cfg    *config.Config
 	logger *loggers.Logger
 	db     *sql.DB
+	// leader - выделенное соединение, на котором держится блокировка ведущей реплики синхронизации
+	leader   *sql.Conn
+	leaderMu sync.Mutex
 }
 
 // NewPGStore - конструктор БД
@@ -25,15 +35,15 @@ func NewPGStore(cfg *config.Config, logger *loggers.Logger) (*PGStore, error) {
 
 	db, err := sql.Open("postgres", cfg.DBPath)
 	if err != nil {
-		logger.Error("failed to init db", err)
+		logger.Error("failed to init db", loggers.Err(err))
 		return nil, err
 	}
 	if err = db.Ping(); err != nil {
-		logger.Error("failed to connect to db", err)
+		logger.Error("failed to connect to db", loggers.Err(err))
 		return nil, err
 	}
 	if err := createTable(ctx, db, logger); err != nil {
-		logger.Error("failed to create table", err)
+		logger.Error("failed to create table", loggers.Err(err))
 		return nil, err
 	}
 	return &PGStore{
@@ -47,7 +57,7 @@ func NewPGStore(cfg *config.Config, logger *loggers.Logger) (*PGStore, error) {
 func createTable(ctx context.Context, db *sql.DB, logger *loggers.Logger) error {
 	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
-		logger.Error("failed to begin transaction", err)
+		logger.Error("failed to begin transaction", loggers.Err(err))
 		return err
 	}
 
@@ -57,7 +67,7 @@ func createTable(ctx context.Context, db *sql.DB, logger *loggers.Logger) error
 	tables := []string{
 		`CREATE TABLE IF NOT EXISTS clients (
 			id SERIAL PRIMARY KEY,
-			client_name VARCHAR(100) NOT NULL UNIQUE,
+			client_name VARCHAR(100) NOT NULL,
 			version INT,
 			image VARCHAR(255),
 			cpu VARCHAR(50),
@@ -75,11 +85,157 @@ func createTable(ctx context.Context, db *sql.DB, logger *loggers.Logger) error
     		twap BOOLEAN DEFAULT FALSE,
     		hft BOOLEAN DEFAULT FALSE
 		)`,
+		`CREATE TABLE IF NOT EXISTS sync_guard (
+			id INT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+			alert BOOLEAN DEFAULT FALSE,
+			pods TEXT[],
+			managed_pods INT DEFAULT 0,
+			reason TEXT,
+			raised_at TIMESTAMP,
+			confirmed BOOLEAN DEFAULT FALSE,
+			confirmed_at TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS halts (
+			id SERIAL PRIMARY KEY,
+			scope VARCHAR(20) NOT NULL,
+			client_id INT,
+			algorithm VARCHAR(20),
+			reason TEXT NOT NULL,
+			triggered_by VARCHAR(100) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			resumed_at TIMESTAMP,
+			resumed_by VARCHAR(100)
+		)`,
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS suspended BOOLEAN DEFAULT FALSE`,
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS revision BIGINT NOT NULL DEFAULT 1`,
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS suspended_until TIMESTAMPTZ`,
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS suspend_reason TEXT`,
+		`CREATE TABLE IF NOT EXISTS sync_freeze (
+			id INT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+			frozen BOOLEAN DEFAULT FALSE,
+			frozen_until TIMESTAMPTZ,
+			reason TEXT,
+			updated_at TIMESTAMPTZ
+		)`,
+		`CREATE TABLE IF NOT EXISTS sync_status (
+			id INT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+			result VARCHAR(20) NOT NULL,
+			started_at TIMESTAMPTZ,
+			finished_at TIMESTAMPTZ,
+			created INT DEFAULT 0,
+			deleted INT DEFAULT 0,
+			skipped_clients BIGINT[],
+			error TEXT
+		)`,
+		`CREATE TABLE IF NOT EXISTS schedules (
+			id SERIAL PRIMARY KEY,
+			client_id INT NOT NULL REFERENCES clients(id),
+			algorithm VARCHAR(20) NOT NULL DEFAULT '',
+			timezone VARCHAR(64) NOT NULL,
+			days TEXT[],
+			open_time VARCHAR(5) NOT NULL,
+			close_time VARCHAR(5) NOT NULL,
+			holidays TEXT[],
+			updated_at TIMESTAMPTZ DEFAULT now(),
+			UNIQUE (client_id, algorithm)
+		)`,
+		`CREATE TABLE IF NOT EXISTS scheduled_changes (
+			id SERIAL PRIMARY KEY,
+			client_id INT NOT NULL REFERENCES clients(id),
+			algorithm VARCHAR(20) NOT NULL,
+			enabled BOOLEAN NOT NULL,
+			apply_at TIMESTAMPTZ NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			created_by VARCHAR(100),
+			created_at TIMESTAMPTZ DEFAULT now(),
+			applied_at TIMESTAMPTZ,
+			error TEXT
+		)`,
+		`CREATE INDEX IF NOT EXISTS scheduled_changes_pending ON scheduled_changes (apply_at) WHERE status='pending'`,
+		`ALTER TABLE scheduled_changes ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0`,
+		`ALTER TABLE scheduled_changes ADD COLUMN IF NOT EXISTS retry_at TIMESTAMPTZ`,
+		`CREATE TABLE IF NOT EXISTS idempotency_keys (
+			key VARCHAR(255) PRIMARY KEY,
+			request_hash CHAR(64) NOT NULL,
+			status INT NOT NULL DEFAULT 0,
+			headers JSONB,
+			body BYTEA,
+			created_at TIMESTAMPTZ DEFAULT now(),
+			expires_at TIMESTAMPTZ NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idempotency_keys_expires ON idempotency_keys (expires_at)`,
+		`CREATE TABLE IF NOT EXISTS api_keys (
+			id BIGSERIAL PRIMARY KEY,
+			name VARCHAR(100) NOT NULL,
+			prefix VARCHAR(16) NOT NULL,
+			key_hash CHAR(64) NOT NULL UNIQUE,
+			role VARCHAR(20) NOT NULL,
+			created_at TIMESTAMPTZ DEFAULT now(),
+			last_used_at TIMESTAMPTZ,
+			revoked_at TIMESTAMPTZ
+		)`,
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}'`,
+		`CREATE INDEX IF NOT EXISTS clients_tags ON clients USING GIN (tags)`,
+		`ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS client_ids BIGINT[] NOT NULL DEFAULT '{}'`,
+		`ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS client_tags TEXT[] NOT NULL DEFAULT '{}'`,
+		`CREATE TABLE IF NOT EXISTS approvals (
+			id BIGSERIAL PRIMARY KEY,
+			client_id INT NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+			vwap BOOLEAN,
+			twap BOOLEAN,
+			hft BOOLEAN,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			requested_by VARCHAR(100) NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ DEFAULT now(),
+			expires_at TIMESTAMPTZ NOT NULL,
+			decided_by VARCHAR(100),
+			decided_at TIMESTAMPTZ,
+			comment TEXT
+		)`,
+		`CREATE INDEX IF NOT EXISTS approvals_pending ON approvals (client_id) WHERE status='pending'`,
+		`CREATE TABLE IF NOT EXISTS client_revisions (
+			client_id BIGINT NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+			revision BIGINT NOT NULL,
+			snapshot JSONB NOT NULL,
+			created_by TEXT NOT NULL,
+			request_id TEXT,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			PRIMARY KEY (client_id, revision)
+		)`,
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id BIGSERIAL PRIMARY KEY,
+			actor TEXT NOT NULL,
+			action VARCHAR(20) NOT NULL,
+			entity VARCHAR(30) NOT NULL,
+			entity_id BIGINT,
+			client_id BIGINT,
+			diff JSONB NOT NULL,
+			request_id TEXT,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`,
+		`CREATE INDEX IF NOT EXISTS audit_log_client ON audit_log (client_id, id)`,
+		`CREATE INDEX IF NOT EXISTS audit_log_actor ON audit_log (actor, id)`,
+		`CREATE INDEX IF NOT EXISTS audit_log_created ON audit_log (created_at)`,
+		`CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger LANGUAGE plpgsql AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit_log is append-only';
+		END $$`,
+		`DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log`,
+		`CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+			FOR EACH ROW EXECUTE FUNCTION audit_log_append_only()`,
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS deletion_protected BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE clients ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ`,
+		`CREATE INDEX IF NOT EXISTS clients_deleted ON clients (deleted_at) WHERE deleted_at IS NOT NULL`,
+		// имя уникально только среди неудалённых клиентов, удалённый клиент не держит имя до окончательного удаления
+		`ALTER TABLE clients DROP CONSTRAINT IF EXISTS clients_client_name_key`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS clients_name_live ON clients (client_name) WHERE deleted_at IS NULL`,
+		`ALTER TABLE sync_status ADD COLUMN IF NOT EXISTS last_success_at TIMESTAMPTZ`,
+		`ALTER TABLE client_revisions ADD COLUMN IF NOT EXISTS client_revision BIGINT`,
 	}
 
 	for _, table := range tables {
 		if _, err = tx.ExecContext(ctx, table); err != nil {
-			logger.Error("Unable to create table", err)
+			logger.Error("Unable to create table", loggers.Err(err))
 			return err
 		}
 	}
@@ -87,85 +243,2134 @@ func createTable(ctx context.Context, db *sql.DB, logger *loggers.Logger) error
 	return tx.Commit()
 }
 
-// AddClient - добаление клиента в БД и дефолтные значения алгоритмов
-func (p *PGStore) AddClient(ctx context.Context, c *model.Client) error {
-	q := `INSERT INTO clients (client_name, version, image, cpu, memory, priority, need_restart, spawned_at, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
-	err := p.db.QueryRowContext(ctx, q, c.ClientName, c.Version, c.Image, c.CPU, c.Memory, c.Priority,
-		c.NeedRestart, c.SpawnedAt, c.CreatedAt, c.UpdatedAt).Scan(&c.ID)
+// schemaColumns - колонки, которые создаёт createTable, их наличие проверяет CheckSchema.
+// Кроме ключевой колонки каждой таблицы здесь все колонки, добавленные через ALTER TABLE:
+// без них таблица есть, но сервис с ней не работает.
+var schemaColumns = []string{
+	"clients.id", "clients.client_name", "clients.revision", "clients.suspended", "clients.suspended_until",
+	"clients.suspend_reason", "clients.tags", "clients.deletion_protected", "clients.deleted_at",
+	"algorithm_status.client_id", "algorithm_status.vwap", "algorithm_status.twap", "algorithm_status.hft",
+	"sync_guard.alert", "halts.id", "sync_freeze.frozen", "sync_status.result", "sync_status.last_success_at",
+	"schedules.id", "scheduled_changes.id", "scheduled_changes.attempts", "scheduled_changes.retry_at", "idempotency_keys.key",
+	"api_keys.id", "api_keys.client_ids", "api_keys.client_tags",
+	"approvals.id", "client_revisions.revision", "client_revisions.snapshot", "client_revisions.client_revision", "audit_log.id",
+}
+
+// Ping - проверка соединения с БД
+func (p *PGStore) Ping(ctx context.Context) error {
+	return p.db.PingContext(ctx)
+}
+
+// CheckSchema - проверка, что схема БД создана: все таблицы и колонки schemaColumns на месте.
+// Схема создаётся при старте, отсутствие колонок значит, что БД подменили, таблицы изменили вручную
+// или миграции createTable не применились.
+func (p *PGStore) CheckSchema(ctx context.Context) error {
+	q := `SELECT table_name || '.' || column_name FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name || '.' || column_name = ANY($1)`
+	rows, err := p.db.QueryContext(ctx, q, pq.StringArray(schemaColumns))
 	if err != nil {
-		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
-			p.logger.Error("client_name already exists", err)
-			return model.ErrorClientConflict
+		return err
+	}
+	defer rows.Close()
+	found := make(map[string]bool, len(schemaColumns))
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
 		}
-		p.logger.Error("Failure to insert client into table", err)
+		found[name] = true
+	}
+	if err := rows.Err(); err != nil {
 		return err
 	}
+	var missing []string
+	for _, name := range schemaColumns {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing columns: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// syncLockKey - ключ advisory lock ведущей реплики синхронизации
+const syncLockKey int64 = 7235
+
+// TryLockSync - захват блокировки ведущей реплики синхронизации или проверка, что она ещё держится.
+// Блокировка сессионная, поэтому держится на выделенном соединении. При обрыве соединения Postgres
+// освобождает её, и её может захватить другая реплика - следующий вызов пытается захватить её заново.
+func (p *PGStore) TryLockSync(ctx context.Context) (bool, error) {
+	p.leaderMu.Lock()
+	defer p.leaderMu.Unlock()
+	if p.leader != nil {
+		if _, err := p.leader.ExecContext(ctx, `SELECT 1`); err == nil {
+			return true, nil
+		}
+		p.log(ctx).Warn("sync leader connection lost, lock released")
+		p.leader.Close()
+		p.leader = nil
+	}
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		p.log(ctx).Error("Failure to get connection for sync lock", loggers.Err(err))
+		return false, err
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, syncLockKey).Scan(&locked); err != nil {
+		conn.Close()
+		p.log(ctx).Error("Failure to acquire sync lock", loggers.Err(err))
+		return false, err
+	}
+	if !locked {
+		conn.Close()
+		return false, nil
+	}
+	p.leader = conn
+	return true, nil
+}
+
+// syncChannel - канал LISTEN/NOTIFY, по которому реплики пересылают друг другу события синхронизации
+const syncChannel = "sync"
+
+// listenerPing - как часто проверяется соединение слушателя событий синхронизации
+const listenerPing = 90 * time.Second
 
-	q = `INSERT INTO algorithm_status (client_id, vwap, twap, hft) VALUES ($1, default, default, default)`
-	if _, err := p.db.ExecContext(ctx, q, c.ID); err != nil {
-		p.logger.Error("Failure to insert algorithm status into table", err)
+// NotifySync - отправка события синхронизации всем репликам
+func (p *PGStore) NotifySync(ctx context.Context, event model.SyncEvent) error {
+	if _, err := p.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, syncChannel, string(event)); err != nil {
+		p.log(ctx).Error("Failure to notify sync event", slog.String("event", string(event)), loggers.Err(err))
 		return err
 	}
 	return nil
 }
 
-// UpdateClient - обновление клиента в БД
-func (p *PGStore) UpdateClient(ctx context.Context, client *model.Client) error {
-	q := `UPDATE clients SET client_name=$1, version=$2, image=$3, cpu=$4, memory=$5, priority=$6, need_restart=$7, spawned_at=$8, updated_at=$9 
-			WHERE id=$10`
-	_, err := p.db.ExecContext(ctx, q, client.ClientName, client.Version, client.Image, client.CPU, client.Memory, client.Priority,
-		client.NeedRestart, client.SpawnedAt, time.Now(), client.ID)
-	if err != nil {
-		p.logger.Error("Failure to update client in table", err)
+// ListenSync - события синхронизации от всех реплик, включая эту, до отмены ctx.
+// Слушатель держит своё соединение и сам переподключается. Уведомления, отправленные пока соединения не было,
+// теряются, поэтому после переподключения отдаётся SyncEventTrigger - проход наверстает пропущенное.
+func (p *PGStore) ListenSync(ctx context.Context) (<-chan model.SyncEvent, error) {
+	l := pq.NewListener(p.cfg.DBPath, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			p.logger.Warn("sync listener connection problem", loggers.Err(err))
+		}
+	})
+	if err := l.Listen(syncChannel); err != nil {
+		l.Close()
+		p.log(ctx).Error("Failure to listen sync events", loggers.Err(err))
+		return nil, err
+	}
+	events := make(chan model.SyncEvent, 1)
+	go func() {
+		defer close(events)
+		defer l.Close()
+		ping := time.NewTicker(listenerPing)
+		defer ping.Stop()
+		for {
+			var event model.SyncEvent
+			select {
+			case <-ctx.Done():
+				return
+			case <-ping.C:
+				go l.Ping()
+				continue
+			case n := <-l.Notify:
+				// nil - соединение восстановлено после обрыва
+				event = model.SyncEventTrigger
+				if n != nil {
+					event = model.SyncEvent(n.Extra)
+				}
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// clientColumns - колонки клиента в порядке scanClient
+const clientColumns = `id, client_name, version, image, cpu, memory, priority, need_restart, spawned_at, created_at,
+	updated_at, revision, tags, deletion_protected`
+
+// activeAlgorithms - условие на algorithm_status: клиент не удалён
+const activeAlgorithms = `client_id IN (SELECT id FROM clients WHERE deleted_at IS NULL)`
+
+// scanClient - чтение клиента из строки с колонками clientColumns
+func scanClient(row interface{ Scan(...any) error }, c *model.Client) error {
+	var (
+		spawnedAt sql.NullTime
+		tags      pq.StringArray
+	)
+	if err := row.Scan(&c.ID, &c.ClientName, &c.Version, &c.Image, &c.CPU, &c.Memory, &c.Priority, &c.NeedRestart,
+		&spawnedAt, &c.CreatedAt, &c.UpdatedAt, &c.Revision, &tags, &c.DeletionProtected); err != nil {
 		return err
 	}
+	c.SpawnedAt = spawnedAt.Time
+	c.Tags = tags
 	return nil
 }
 
-// DeleteClient - удаление клиента и алгоритмов из БД
-func (p *PGStore) DeleteClient(ctx context.Context, client *model.Client) error {
-	q := `DELETE FROM clients WHERE id=$1`
-	_, err := p.db.ExecContext(ctx, q, client.ID)
-	if err != nil {
-		p.logger.Error("Failure to delete client from table", err)
+// textArray - массив для колонки NOT NULL DEFAULT '{}', nil записывается как пустой массив
+func textArray(v []string) pq.StringArray {
+	if v == nil {
+		return pq.StringArray{}
+	}
+	return v
+}
+
+// scopeCondition - условие области видимости вызывающего из ctx на колонку с id клиента.
+// Параметры условия добавляются в args, без области видимости условие пустое.
+func scopeCondition(ctx context.Context, column string, args *[]any) string {
+	scope := model.ScopeFromContext(ctx)
+	if scope.Unrestricted() {
+		return ""
+	}
+	ids := pq.Int64Array(scope.ClientIDs)
+	if ids == nil {
+		ids = pq.Int64Array{}
+	}
+	*args = append(*args, ids, textArray(scope.Tags))
+	return fmt.Sprintf(`%s IN (SELECT id FROM clients WHERE id = ANY($%d) OR tags && $%d)`, column, len(*args)-1, len(*args))
+}
+
+// scopeClause - scopeCondition для добавления к WHERE через AND
+func scopeClause(ctx context.Context, column string, args *[]any) string {
+	if cond := scopeCondition(ctx, column, args); cond != "" {
+		return " AND " + cond
+	}
+	return ""
+}
+
+// checkScope - входит ли клиент в область видимости вызывающего. Клиент вне области - ErrorClientNotFound,
+// чтобы не раскрывать его существование. Без области видимости запрос в БД не выполняется.
+func (p *PGStore) checkScope(ctx context.Context, clientID int64) error {
+	args := []any{clientID}
+	cond := scopeClause(ctx, "id", &args)
+	if cond == "" {
+		return nil
+	}
+	var exists bool
+	if err := p.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM clients WHERE id=$1 AND deleted_at IS NULL`+cond+`)`, args...).
+		Scan(&exists); err != nil {
+		p.log(ctx).Error("Failure to check client scope", loggers.Err(err))
 		return err
 	}
-	q = `DELETE FROM algorithm_status WHERE client_id=$1`
-	_, err = p.db.ExecContext(ctx, q, client.ID)
+	if !exists {
+		return model.ErrorClientNotFound
+	}
+	return nil
+}
+
+// log - логгер запроса из ctx, чтобы ошибки БД связывались с HTTP запросом, иначе логгер хранилища
+func (p *PGStore) log(ctx context.Context) *loggers.Logger {
+	return loggers.FromContext(ctx, p.logger)
+}
+
+// inTx - выполнение fn в транзакции, транзакция фиксируется, если fn не вернула ошибку
+func (p *PGStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
-		p.logger.Error("Failure to delete algorithm from table", err)
+		p.log(ctx).Error("failed to begin transaction", loggers.Err(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		p.log(ctx).Error("failed to commit transaction", loggers.Err(err))
 		return err
 	}
 	return nil
 }
 
-// UpdateAlgorithmStatus - обновление статусов алноритмов в БД
-func (p *PGStore) UpdateAlgorithmStatus(ctx context.Context, as *model.AlgorithmStatus) error {
-	q := `UPDATE algorithm_status SET vwap=$1, twap=$2, hft=$3 WHERE client_id=$4`
-	_, err := p.db.ExecContext(ctx, q, as.VWAP, as.TWAP, as.HFT, as.ClientID)
+// audit - запись в журнал аудита в транзакции изменения. Автор и id запроса берутся из ctx,
+// в журнал попадают только поля, которые отличаются в before и after.
+func (p *PGStore) audit(ctx context.Context, tx *sql.Tx, action model.AuditAction, entity string, entityID, clientID int64,
+	before, after any) error {
+	diff, err := model.Diff(before, after)
+	if err != nil {
+		p.log(ctx).Error("failed to build audit diff", loggers.Err(err))
+		return err
+	}
+	data, err := json.Marshal(diff)
 	if err != nil {
-		p.logger.Error("Failure to update algorithm status in table", err)
+		p.log(ctx).Error("failed to marshal audit diff", loggers.Err(err))
+		return err
+	}
+	o := model.OriginFromContext(ctx)
+	q := `INSERT INTO audit_log (actor, action, entity, entity_id, client_id, diff, request_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	if _, err := tx.ExecContext(ctx, q, o.Actor, action, entity, nullInt64(entityID), nullInt64(clientID), data,
+		nullString(o.RequestID)); err != nil {
+		p.log(ctx).Error("Failure to insert audit entry into table", loggers.Err(err))
 		return err
 	}
 	return nil
 }
 
-func (p *PGStore) GetAlgorithmStatus(ctx context.Context) ([]model.AlgorithmStatus, error) {
-	q := `SELECT id, client_id, vwap, twap, hft FROM algorithm_status`
-	rows, err := p.db.QueryContext(ctx, q)
+// lockClient - неудалённый клиент из области видимости вызывающего, строка заблокирована до конца транзакции
+func (p *PGStore) lockClient(ctx context.Context, tx *sql.Tx, id int64) (*model.Client, error) {
+	args := []any{id}
+	q := `SELECT ` + clientColumns + ` FROM clients WHERE id=$1 AND deleted_at IS NULL` + scopeClause(ctx, "id", &args) +
+		` FOR UPDATE`
+	var c model.Client
+	if err := scanClient(tx.QueryRowContext(ctx, q, args...), &c); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrorClientNotFound
+		}
+		p.log(ctx).Error("Failure to lock client in table", loggers.Err(err))
+		return nil, err
+	}
+	return &c, nil
+}
+
+// AddClient - добаление клиента в БД и дефолтные значения алгоритмов.
+// В client записывается сохранённое состояние, включая id и время создания.
+func (p *PGStore) AddClient(ctx context.Context, c *model.Client) error {
+	if !model.ScopeFromContext(ctx).Allows(0, c.Tags) {
+		return model.ErrorOutOfScope
+	}
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		q := `INSERT INTO clients (client_name, version, image, cpu, memory, priority, need_restart, tags)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING ` + clientColumns
+		err := scanClient(tx.QueryRowContext(ctx, q, c.ClientName, c.Version, c.Image, c.CPU, c.Memory, c.Priority,
+			c.NeedRestart, textArray(c.Tags)), c)
+		if err != nil {
+			if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+				p.log(ctx).Error("client_name already exists", loggers.Err(err))
+				return model.ErrorClientConflict
+			}
+			p.log(ctx).Error("Failure to insert client into table", loggers.Err(err))
+			return err
+		}
+
+		q = `INSERT INTO algorithm_status (client_id, vwap, twap, hft) VALUES ($1, default, default, default)
+			RETURNING id, client_id, vwap, twap, hft`
+		var as model.AlgorithmStatus
+		if err := tx.QueryRowContext(ctx, q, c.ID).Scan(&as.AlgorithmID, &as.ClientID, &as.VWAP, &as.TWAP, &as.HFT); err != nil {
+			p.log(ctx).Error("Failure to insert algorithm status into table", loggers.Err(err))
+			return err
+		}
+		if err := p.audit(ctx, tx, model.AuditCreate, model.EntityClient, c.ID, c.ID, nil, c); err != nil {
+			return err
+		}
+		_, err = p.snapshot(ctx, tx, c, &as)
+		return err
+	})
+}
+
+// GetClient - получение клиента по id, удалённый клиент не возвращается
+func (p *PGStore) GetClient(ctx context.Context, id int64) (*model.Client, error) {
+	args := []any{id}
+	q := `SELECT ` + clientColumns + ` FROM clients WHERE id=$1 AND deleted_at IS NULL` + scopeClause(ctx, "id", &args)
+	var c model.Client
+	if err := scanClient(p.db.QueryRowContext(ctx, q, args...), &c); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrorClientNotFound
+		}
+		p.log(ctx).Error("Failure to select client from table", loggers.Err(err))
+		return nil, err
+	}
+	return &c, nil
+}
+
+// UpdateClient - обновление клиента в БД. В client записывается сохранённое состояние.
+// Если задана client.Revision, строка меняется только при совпадении ревизии - проверка идёт под блокировкой строки.
+// Изменение образа, версии или ресурсов выставляет need_restart.
+func (p *PGStore) UpdateClient(ctx context.Context, client *model.Client) error {
+	if !model.ScopeFromContext(ctx).Allows(client.ID, client.Tags) {
+		if err := p.checkScope(ctx, client.ID); err != nil {
+			return err
+		}
+		return model.ErrorOutOfScope
+	}
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := p.lockClient(ctx, tx, client.ID)
+		if err != nil {
+			return err
+		}
+		if client.Revision != 0 && client.Revision != before.Revision {
+			return model.ErrorRevisionMismatch
+		}
+		q := `UPDATE clients SET client_name=$1, version=$2, image=$3, cpu=$4, memory=$5, priority=$6, need_restart=$7,
+			tags=$8, updated_at=$9, revision=revision+1
+			WHERE id=$10 RETURNING ` + clientColumns
+		err = scanClient(tx.QueryRowContext(ctx, q, client.ClientName, client.Version, client.Image, client.CPU,
+			client.Memory, client.Priority, client.NeedRestart, textArray(client.Tags), time.Now(), client.ID), client)
+		if err != nil {
+			return p.clientUpdateError(ctx, err)
+		}
+		if err := p.markRestart(ctx, tx, before, client); err != nil {
+			return err
+		}
+		if err := p.audit(ctx, tx, model.AuditUpdate, model.EntityClient, client.ID, client.ID, before, client); err != nil {
+			return err
+		}
+		return p.snapshotClient(ctx, tx, client)
+	})
+}
+
+// PatchClient - изменение только переданных полей клиента. Если задана revision, строка меняется только при совпадении ревизии.
+// Пустое изменение ничего не пишет и возвращает текущее состояние.
+// Изменение образа, версии или ресурсов выставляет need_restart.
+func (p *PGStore) PatchClient(ctx context.Context, id, revision int64, patch *model.ClientPatch) (*model.Client, error) {
+	var (
+		sets []string
+		args []any
+	)
+	set := func(column string, value any) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s=$%d", column, len(args)))
+	}
+	if patch.ClientName != nil {
+		set("client_name", *patch.ClientName)
+	}
+	if patch.Version != nil {
+		set("version", *patch.Version)
+	}
+	if patch.Image != nil {
+		set("image", *patch.Image)
+	}
+	if patch.CPU != nil {
+		set("cpu", *patch.CPU)
+	}
+	if patch.Memory != nil {
+		set("memory", *patch.Memory)
+	}
+	if patch.Priority != nil {
+		set("priority", *patch.Priority)
+	}
+	if patch.NeedRestart != nil {
+		set("need_restart", *patch.NeedRestart)
+	}
+	if patch.Tags != nil {
+		if !model.ScopeFromContext(ctx).Allows(id, *patch.Tags) {
+			if err := p.checkScope(ctx, id); err != nil {
+				return nil, err
+			}
+			return nil, model.ErrorOutOfScope
+		}
+		set("tags", textArray(*patch.Tags))
+	}
+	if len(sets) == 0 {
+		c, err := p.GetClient(ctx, id)
+		if err == nil && revision != 0 && c.Revision != revision {
+			return nil, model.ErrorRevisionMismatch
+		}
+		return c, err
+	}
+	set("updated_at", time.Now())
+	args = append(args, id)
+	q := fmt.Sprintf(`UPDATE clients SET %s, revision=revision+1 WHERE id=$%d RETURNING `+clientColumns,
+		strings.Join(sets, ", "), len(args))
+	var c model.Client
+	err := p.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := p.lockClient(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if revision != 0 && revision != before.Revision {
+			return model.ErrorRevisionMismatch
+		}
+		if err := scanClient(tx.QueryRowContext(ctx, q, args...), &c); err != nil {
+			return p.clientUpdateError(ctx, err)
+		}
+		if err := p.markRestart(ctx, tx, before, &c); err != nil {
+			return err
+		}
+		if err := p.audit(ctx, tx, model.AuditPatch, model.EntityClient, id, id, before, &c); err != nil {
+			return err
+		}
+		return p.snapshotClient(ctx, tx, &c)
+	})
 	if err != nil {
-		p.logger.Error("Failure to select algorithms from table", err)
 		return nil, err
 	}
-	var algorithms []model.AlgorithmStatus
+	return &c, nil
+}
+
+// clientUpdateError - ошибка обновления клиента в терминах модели
+func (p *PGStore) clientUpdateError(ctx context.Context, err error) error {
+	if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+		return model.ErrorClientConflict
+	}
+	p.log(ctx).Error("Failure to update client in table", loggers.Err(err))
+	return err
+}
+
+// GetClients - неудалённые клиенты из области видимости вызывающего, по ним синкер создаёт pod'ы
+func (p *PGStore) GetClients(ctx context.Context) ([]model.Client, error) {
+	var args []any
+	q := `SELECT ` + clientColumns + ` FROM clients WHERE deleted_at IS NULL` + scopeClause(ctx, "id", &args) + ` ORDER BY id`
+	rows, err := p.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		p.log(ctx).Error("Failure to select clients from table", loggers.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clients []model.Client
 	for rows.Next() {
-		var a model.AlgorithmStatus
-		if err := rows.Scan(&a.AlgorithmID, &a.ClientID, &a.VWAP, &a.TWAP, &a.HFT); err != nil {
-			p.logger.Error("failed to scan algorithms from data")
+		var c model.Client
+		if err := scanClient(rows, &c); err != nil {
+			p.log(ctx).Error("failed to scan clients from data", loggers.Err(err))
 			return nil, err
 		}
-		algorithms = append(algorithms, a)
+		clients = append(clients, c)
+	}
+	return clients, rows.Err()
+}
+
+// SetClientsSpawned - отметка времени запуска pod'ов клиентов, выставляется синкером
+func (p *PGStore) SetClientsSpawned(ctx context.Context, ids []int64) error {
+	q := `UPDATE clients SET spawned_at=$1 WHERE id = ANY($2)`
+	if _, err := p.db.ExecContext(ctx, q, time.Now(), pq.Int64Array(ids)); err != nil {
+		p.log(ctx).Error("Failure to update client spawn time in table", loggers.Err(err))
+		return err
+	}
+	return nil
+}
+
+// SetClientsRestarted - снятие need_restart после пересоздания pod'ов клиентов синкером. clients - состояние,
+// по которому pod'ы пересозданы: флаг снимается, только если ревизия клиента с тех пор не изменилась,
+// иначе спецификация могла поменяться снова и pod'ы пересоздаст следующий проход.
+// Снятие флага пишется в журнал аудита, но не меняет ревизию клиента: ETag, полученный до прохода, остаётся верным.
+func (p *PGStore) SetClientsRestarted(ctx context.Context, clients []model.Client) error {
+	for i := range clients {
+		before := &clients[i]
+		err := p.inTx(ctx, func(tx *sql.Tx) error {
+			q := `UPDATE clients SET need_restart=FALSE
+				WHERE id=$1 AND revision=$2 AND need_restart AND deleted_at IS NULL RETURNING ` + clientColumns
+			var c model.Client
+			if err := scanClient(tx.QueryRowContext(ctx, q, before.ID, before.Revision), &c); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return nil
+				}
+				p.log(ctx).Error("Failure to update client restart flag in table", loggers.Err(err))
+				return err
+			}
+			return p.audit(ctx, tx, model.AuditRestart, model.EntityClient, c.ID, c.ID, before, &c)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markRestart - после изменения образа, версии или ресурсов клиента его запущенные pod'ы нужно пересоздать:
+// need_restart выставляется в транзакции изменения, флаг снимает синкер, когда пересоздаст pod'ы
+func (p *PGStore) markRestart(ctx context.Context, tx *sql.Tx, before, c *model.Client) error {
+	if c.NeedRestart || !c.RolloutChanged(before) {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE clients SET need_restart=TRUE WHERE id=$1`, c.ID); err != nil {
+		p.log(ctx).Error("Failure to update client restart flag in table", loggers.Err(err))
+		return err
+	}
+	c.NeedRestart = true
+	return nil
+}
+
+// DeleteClient - мягкое удаление клиента: клиент пропадает из API и синхронизации, pod'ы удаляет синкер.
+// Данные хранятся до окончательного удаления в PurgeDeletedClients. Клиент с защитой от удаления не удаляется.
+func (p *PGStore) DeleteClient(ctx context.Context, id int64) error {
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		c, err := p.lockClient(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if c.DeletionProtected {
+			return model.ErrorDeletionProtected
+		}
+		var deletedAt time.Time
+		q := `UPDATE clients SET deleted_at=now() WHERE id=$1 RETURNING deleted_at`
+		if err := tx.QueryRowContext(ctx, q, id).Scan(&deletedAt); err != nil {
+			p.log(ctx).Error("Failure to delete client from table", loggers.Err(err))
+			return err
+		}
+		return p.audit(ctx, tx, model.AuditDelete, model.EntityClient, id, id,
+			map[string]any{"deleted_at": nil}, map[string]any{"deleted_at": deletedAt})
+	})
+}
+
+// RestoreClient - восстановление удалённого клиента, пока он не удалён окончательно.
+// Алгоритмы клиента восстанавливаются в прежнем состоянии, pod'ы поднимет синкер.
+// Если имя клиента уже занял другой неудалённый клиент - ErrorClientConflict.
+func (p *PGStore) RestoreClient(ctx context.Context, id int64) (*model.Client, error) {
+	var c model.Client
+	err := p.inTx(ctx, func(tx *sql.Tx) error {
+		args := []any{id}
+		q := `SELECT deleted_at FROM clients WHERE id=$1 AND deleted_at IS NOT NULL` + scopeClause(ctx, "id", &args) +
+			` FOR UPDATE`
+		var deletedAt time.Time
+		if err := tx.QueryRowContext(ctx, q, args...).Scan(&deletedAt); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return model.ErrorClientNotFound
+			}
+			p.log(ctx).Error("Failure to lock deleted client in table", loggers.Err(err))
+			return err
+		}
+		q = `UPDATE clients SET deleted_at=NULL WHERE id=$1 RETURNING ` + clientColumns
+		if err := scanClient(tx.QueryRowContext(ctx, q, id), &c); err != nil {
+			if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+				return model.ErrorClientConflict
+			}
+			p.log(ctx).Error("Failure to restore client in table", loggers.Err(err))
+			return err
+		}
+		return p.audit(ctx, tx, model.AuditRestore, model.EntityClient, id, id,
+			map[string]any{"deleted_at": deletedAt}, map[string]any{"deleted_at": nil})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// SetDeletionProtection - установка или снятие защиты клиента от удаления.
+// Защита в снимок не входит и откатом не меняется, но ревизия пишется: ревизия строки клиента растёт.
+func (p *PGStore) SetDeletionProtection(ctx context.Context, id int64, protected bool) (*model.Client, error) {
+	var c model.Client
+	err := p.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := p.lockClient(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		q := `UPDATE clients SET deletion_protected=$1, updated_at=$2, revision=revision+1 WHERE id=$3 RETURNING ` +
+			clientColumns
+		if err := scanClient(tx.QueryRowContext(ctx, q, protected, time.Now(), id), &c); err != nil {
+			p.log(ctx).Error("Failure to update client deletion protection in table", loggers.Err(err))
+			return err
+		}
+		if err := p.audit(ctx, tx, model.AuditUpdate, model.EntityClient, id, id, before, &c); err != nil {
+			return err
+		}
+		return p.snapshotClient(ctx, tx, &c)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// PurgeDeletedClients - окончательное удаление клиентов, удалённых раньше before, вместе с алгоритмами,
+// расписаниями и отложенными изменениями. Ревизии и запросы подтверждения удаляются каскадно.
+// Возвращает число удалённых клиентов.
+func (p *PGStore) PurgeDeletedClients(ctx context.Context, before time.Time) (int64, error) {
+	var clients []model.Client
+	err := p.inTx(ctx, func(tx *sql.Tx) error {
+		q := `SELECT ` + clientColumns + ` FROM clients WHERE deleted_at < $1 ORDER BY id FOR UPDATE`
+		rows, err := tx.QueryContext(ctx, q, before)
+		if err != nil {
+			p.log(ctx).Error("Failure to select deleted clients from table", loggers.Err(err))
+			return err
+		}
+		var ids []int64
+		for rows.Next() {
+			var c model.Client
+			if err := scanClient(rows, &c); err != nil {
+				rows.Close()
+				p.log(ctx).Error("failed to scan deleted clients from data", loggers.Err(err))
+				return err
+			}
+			clients = append(clients, c)
+			ids = append(ids, c.ID)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			p.log(ctx).Error("failed to read deleted clients", loggers.Err(err))
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		for _, q := range []string{
+			`DELETE FROM algorithm_status WHERE client_id = ANY($1)`,
+			`DELETE FROM schedules WHERE client_id = ANY($1)`,
+			`DELETE FROM scheduled_changes WHERE client_id = ANY($1)`,
+			`DELETE FROM clients WHERE id = ANY($1)`,
+		} {
+			if _, err := tx.ExecContext(ctx, q, pq.Int64Array(ids)); err != nil {
+				p.log(ctx).Error("Failure to purge deleted clients from table", loggers.Err(err))
+				return err
+			}
+		}
+		for i := range clients {
+			c := &clients[i]
+			if err := p.audit(ctx, tx, model.AuditPurge, model.EntityClient, c.ID, c.ID, c, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(clients)), nil
+}
+
+// UpdateAlgorithmStatus - обновление статусов алноритмов в БД. В as записывается сохранённое состояние.
+func (p *PGStore) UpdateAlgorithmStatus(ctx context.Context, as *model.AlgorithmStatus) error {
+	patch := model.AlgorithmPatch{VWAP: &as.VWAP, TWAP: &as.TWAP, HFT: &as.HFT}
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		updated, err := p.applyAlgorithmPatch(ctx, tx, as.ClientID, &patch, model.AuditUpdate)
+		if err != nil {
+			return err
+		}
+		*as = *updated
+		return nil
+	})
+}
+
+// PatchAlgorithmStatus - изменение только переданных флагов алгоритмов клиента.
+// Пустое изменение ничего не пишет и возвращает текущие статусы.
+func (p *PGStore) PatchAlgorithmStatus(ctx context.Context, clientID int64, patch *model.AlgorithmPatch) (*model.AlgorithmStatus, error) {
+	if q, _ := patchAlgorithmQuery(clientID, patch); q == "" {
+		return p.GetClientAlgorithms(ctx, clientID)
+	}
+	var as *model.AlgorithmStatus
+	err := p.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		as, err = p.applyAlgorithmPatch(ctx, tx, clientID, patch, model.AuditPatch)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return as, nil
+}
+
+// applyAlgorithmPatch - изменение флагов алгоритмов клиента из области видимости в транзакции tx
+// с записью в журнал аудита и ревизией клиента. Ревизия строки клиента не меняется: ETag клиента
+// описывает его спецификацию, а не флаги алгоритмов. Клиент блокируется раньше статусов алгоритмов,
+// как и при изменении клиента. Клиента без статусов алгоритмов - ErrorClientNotFound.
+func (p *PGStore) applyAlgorithmPatch(ctx context.Context, tx *sql.Tx, clientID int64, patch *model.AlgorithmPatch,
+	action model.AuditAction) (*model.AlgorithmStatus, error) {
+	c, err := p.lockClient(ctx, tx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	as, err := p.patchAlgorithms(ctx, tx, clientID, patch, action)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.snapshot(ctx, tx, c, as); err != nil {
+		return nil, err
+	}
+	return as, nil
+}
+
+// patchAlgorithms - изменение флагов алгоритмов заблокированного клиента с записью в журнал аудита, без ревизии.
+// Включение выключенного алгоритма из approval.algorithms допускается только подтверждённым запросом (AuditApprove),
+// иначе - ErrorApprovalRequired. Проверка идёт по заблокированной строке, поэтому её не обойти гонкой изменений.
+func (p *PGStore) patchAlgorithms(ctx context.Context, tx *sql.Tx, clientID int64, patch *model.AlgorithmPatch,
+	action model.AuditAction) (*model.AlgorithmStatus, error) {
+	before, err := p.lockAlgorithms(ctx, tx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if action != model.AuditApprove && len(p.approvalPolicy().Requires(*before, *patch)) > 0 {
+		return nil, model.ErrorApprovalRequired
+	}
+	q, args := patchAlgorithmQuery(clientID, patch)
+	if q == "" {
+		return before, nil
+	}
+	var as model.AlgorithmStatus
+	if err := tx.QueryRowContext(ctx, q, args...).Scan(&as.AlgorithmID, &as.ClientID, &as.VWAP, &as.TWAP, &as.HFT); err != nil {
+		p.log(ctx).Error("Failure to update algorithm status in table", loggers.Err(err))
+		return nil, err
+	}
+	if err := p.audit(ctx, tx, action, model.EntityAlgorithms, as.AlgorithmID, clientID, before, &as); err != nil {
+		return nil, err
+	}
+	return &as, nil
+}
+
+// approvalPolicy - политика подтверждения из конфига, хранилищу из неё нужны только алгоритмы
+func (p *PGStore) approvalPolicy() model.ApprovalPolicy {
+	var policy model.ApprovalPolicy
+	for _, a := range p.cfg.Approval.Algorithms {
+		policy.Algorithms = append(policy.Algorithms, model.AlgorithmType(a))
+	}
+	return policy
+}
+
+// lockAlgorithms - статусы алгоритмов клиента, строка заблокирована до конца транзакции
+func (p *PGStore) lockAlgorithms(ctx context.Context, tx *sql.Tx, clientID int64) (*model.AlgorithmStatus, error) {
+	q := `SELECT id, client_id, vwap, twap, hft FROM algorithm_status WHERE client_id=$1 FOR UPDATE`
+	var as model.AlgorithmStatus
+	err := tx.QueryRowContext(ctx, q, clientID).Scan(&as.AlgorithmID, &as.ClientID, &as.VWAP, &as.TWAP, &as.HFT)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrorClientNotFound
+		}
+		p.log(ctx).Error("Failure to lock algorithm status in table", loggers.Err(err))
+		return nil, err
+	}
+	return &as, nil
+}
+
+// snapshotClient - ревизия изменённого клиента с текущими статусами его алгоритмов
+func (p *PGStore) snapshotClient(ctx context.Context, tx *sql.Tx, c *model.Client) error {
+	as, err := p.lockAlgorithms(ctx, tx, c.ID)
+	if err != nil {
+		return err
+	}
+	_, err = p.snapshot(ctx, tx, c, as)
+	return err
+}
+
+// snapshot - запись ревизии клиента в транзакции изменения. Строка клиента должна быть заблокирована:
+// номер ревизии - следующий за последним номером клиента. client_revision - ревизия строки клиента из ETag.
+func (p *PGStore) snapshot(ctx context.Context, tx *sql.Tx, c *model.Client, as *model.AlgorithmStatus) (*model.ClientRevision, error) {
+	rev := model.ClientRevision{ClientID: c.ID, ClientRevision: c.Revision, Snapshot: model.NewClientSnapshot(c, as)}
+	data, err := json.Marshal(rev.Snapshot)
+	if err != nil {
+		p.log(ctx).Error("failed to marshal client snapshot", loggers.Err(err))
+		return nil, err
+	}
+	o := model.OriginFromContext(ctx)
+	rev.CreatedBy, rev.RequestID = o.Actor, o.RequestID
+	q := `INSERT INTO client_revisions (client_id, revision, snapshot, created_by, request_id, client_revision)
+			VALUES ($1, (SELECT COALESCE(MAX(revision), 0) + 1 FROM client_revisions WHERE client_id=$1), $2, $3, $4, $5)
+			RETURNING revision, created_at`
+	if err := tx.QueryRowContext(ctx, q, c.ID, data, o.Actor, nullString(o.RequestID), c.Revision).Scan(&rev.Revision,
+		&rev.CreatedAt); err != nil {
+		p.log(ctx).Error("Failure to insert client revision into table", loggers.Err(err))
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// clientRevisionColumns - колонки ревизии клиента в порядке scanClientRevision
+const clientRevisionColumns = `client_id, revision, client_revision, snapshot, created_by, request_id, created_at`
+
+// scanClientRevision - чтение ревизии клиента из строки с колонками clientRevisionColumns
+func scanClientRevision(row interface{ Scan(...any) error }, rev *model.ClientRevision) error {
+	var (
+		data           []byte
+		requestID      sql.NullString
+		clientRevision sql.NullInt64
+	)
+	if err := row.Scan(&rev.ClientID, &rev.Revision, &clientRevision, &data, &rev.CreatedBy, &requestID,
+		&rev.CreatedAt); err != nil {
+		return err
+	}
+	rev.RequestID = requestID.String
+	rev.ClientRevision = clientRevision.Int64
+	return json.Unmarshal(data, &rev.Snapshot)
+}
+
+// GetClientRevisions - ревизии клиента из области видимости, новые первыми, с отличиями от предыдущей ревизии.
+// У клиента, созданного до появления ревизий, список пуст.
+func (p *PGStore) GetClientRevisions(ctx context.Context, clientID int64) ([]model.ClientRevision, error) {
+	args := []any{clientID}
+	q := `SELECT ` + clientRevisionColumns + ` FROM client_revisions
+			WHERE client_id=$1` + scopeClause(ctx, "client_id", &args) + ` ORDER BY revision`
+	rows, err := p.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		p.log(ctx).Error("Failure to select client revisions from table", loggers.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		revisions []model.ClientRevision
+		prev      *model.ClientSnapshot
+	)
+	for rows.Next() {
+		var rev model.ClientRevision
+		if err := scanClientRevision(rows, &rev); err != nil {
+			p.log(ctx).Error("failed to scan client revisions from data", loggers.Err(err))
+			return nil, err
+		}
+		if rev.Diff, err = model.Diff(prev, &rev.Snapshot); err != nil {
+			return nil, err
+		}
+		prev = &rev.Snapshot
+		revisions = append(revisions, rev)
+	}
+	if err := rows.Err(); err != nil {
+		p.log(ctx).Error("failed to read client revisions", loggers.Err(err))
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		if _, err := p.GetClient(ctx, clientID); err != nil {
+			return nil, err
+		}
+	}
+	for i, j := 0, len(revisions)-1; i < j; i, j = i+1, j-1 {
+		revisions[i], revisions[j] = revisions[j], revisions[i]
+	}
+	return revisions, nil
+}
+
+// GetClientRevision - ревизия клиента по номеру, без отличий от предыдущей
+func (p *PGStore) GetClientRevision(ctx context.Context, clientID, revision int64) (*model.ClientRevision, error) {
+	args := []any{clientID, revision}
+	q := `SELECT ` + clientRevisionColumns + ` FROM client_revisions
+			WHERE client_id=$1 AND revision=$2` + scopeClause(ctx, "client_id", &args)
+	var rev model.ClientRevision
+	if err := scanClientRevision(p.db.QueryRowContext(ctx, q, args...), &rev); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrorClientRevisionNotFound
+		}
+		p.log(ctx).Error("Failure to select client revision from table", loggers.Err(err))
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// RollbackClient - восстановление спецификации клиента и статусов алгоритмов из ревизии.
+// Откат - обычное изменение: ревизия строки клиента растёт, пишутся журнал аудита и новая ревизия, она и возвращается.
+// Если откат меняет образ, версию или ресурсы, выставляется need_restart и синкер пересоздаёт pod'ы клиента.
+func (p *PGStore) RollbackClient(ctx context.Context, clientID, revision int64) (*model.ClientRevision, error) {
+	var rev *model.ClientRevision
+	err := p.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := p.lockClient(ctx, tx, clientID)
+		if err != nil {
+			return err
+		}
+		var target model.ClientRevision
+		q := `SELECT ` + clientRevisionColumns + ` FROM client_revisions
+			WHERE client_id=$1 AND revision=$2`
+		if err := scanClientRevision(tx.QueryRowContext(ctx, q, clientID, revision), &target); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return model.ErrorClientRevisionNotFound
+			}
+			p.log(ctx).Error("Failure to select client revision from table", loggers.Err(err))
+			return err
+		}
+		spec := target.Snapshot.ClientSpec
+		if !model.ScopeFromContext(ctx).Allows(clientID, spec.Tags) {
+			return model.ErrorOutOfScope
+		}
+		q = `UPDATE clients SET client_name=$1, version=$2, image=$3, cpu=$4, memory=$5, priority=$6, need_restart=$7,
+			tags=$8, updated_at=$9, revision=revision+1
+			WHERE id=$10 RETURNING ` + clientColumns
+		var c model.Client
+		err = scanClient(tx.QueryRowContext(ctx, q, spec.ClientName, spec.Version, spec.Image, spec.CPU, spec.Memory,
+			spec.Priority, spec.NeedRestart, textArray(spec.Tags), time.Now(), clientID), &c)
+		if err != nil {
+			return p.clientUpdateError(ctx, err)
+		}
+		if err := p.markRestart(ctx, tx, before, &c); err != nil {
+			return err
+		}
+		if err := p.audit(ctx, tx, model.AuditRollback, model.EntityClient, clientID, clientID, before, &c); err != nil {
+			return err
+		}
+		current, err := p.lockAlgorithms(ctx, tx, clientID)
+		if err != nil {
+			return err
+		}
+		as, err := p.patchAlgorithms(ctx, tx, clientID, target.Snapshot.Algorithms(), model.AuditRollback)
+		if err != nil {
+			return err
+		}
+		if rev, err = p.snapshot(ctx, tx, &c, as); err != nil {
+			return err
+		}
+		rev.Diff, err = model.Diff(model.NewClientSnapshot(before, current), &rev.Snapshot)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+// patchAlgorithmQuery - UPDATE только переданных флагов алгоритмов клиента с RETURNING статусов.
+// Пустой запрос - изменять нечего.
+func patchAlgorithmQuery(clientID int64, patch *model.AlgorithmPatch) (string, []any) {
+	var (
+		sets []string
+		args []any
+	)
+	for _, f := range []struct {
+		column string
+		value  *bool
+	}{{"vwap", patch.VWAP}, {"twap", patch.TWAP}, {"hft", patch.HFT}} {
+		if f.value != nil {
+			args = append(args, *f.value)
+			sets = append(sets, fmt.Sprintf("%s=$%d", f.column, len(args)))
+		}
+	}
+	if len(sets) == 0 {
+		return "", nil
+	}
+	args = append(args, clientID)
+	q := fmt.Sprintf(`UPDATE algorithm_status SET %s WHERE client_id=$%d RETURNING id, client_id, vwap, twap, hft`,
+		strings.Join(sets, ", "), len(args))
+	return q, args
+}
+
+// GetClientAlgorithms - статусы алгоритмов клиента
+func (p *PGStore) GetClientAlgorithms(ctx context.Context, clientID int64) (*model.AlgorithmStatus, error) {
+	args := []any{clientID}
+	q := `SELECT id, client_id, vwap, twap, hft FROM algorithm_status WHERE client_id=$1 AND ` + activeAlgorithms +
+		scopeClause(ctx, "client_id", &args)
+	var as model.AlgorithmStatus
+	err := p.db.QueryRowContext(ctx, q, args...).Scan(&as.AlgorithmID, &as.ClientID, &as.VWAP, &as.TWAP, &as.HFT)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrorClientNotFound
+		}
+		p.log(ctx).Error("Failure to select client algorithms from table", loggers.Err(err))
+		return nil, err
+	}
+	return &as, nil
+}
+
+func (p *PGStore) GetAlgorithmStatus(ctx context.Context) ([]model.AlgorithmStatus, error) {
+	var args []any
+	q := `SELECT id, client_id, vwap, twap, hft FROM algorithm_status WHERE ` + activeAlgorithms +
+		scopeClause(ctx, "client_id", &args)
+	rows, err := p.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		p.log(ctx).Error("Failure to select algorithms from table", loggers.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var algorithms []model.AlgorithmStatus
+	for rows.Next() {
+		var a model.AlgorithmStatus
+		if err := rows.Scan(&a.AlgorithmID, &a.ClientID, &a.VWAP, &a.TWAP, &a.HFT); err != nil {
+			p.log(ctx).Error("failed to scan algorithms from data", loggers.Err(err))
+			return nil, err
+		}
+		algorithms = append(algorithms, a)
+	}
+	return algorithms, rows.Err()
+}
+
+// GetDeletedAlgorithms - id алгоритмов клиентов, удалённых, но ещё не удалённых окончательно.
+// Их pod'ы синкер удаляет без проверки порогов - удаление клиента подтверждено через API.
+func (p *PGStore) GetDeletedAlgorithms(ctx context.Context) ([]int64, error) {
+	var args []any
+	q := `SELECT id FROM algorithm_status WHERE client_id IN (SELECT id FROM clients WHERE deleted_at IS NOT NULL)` +
+		scopeClause(ctx, "client_id", &args) + ` ORDER BY id`
+	rows, err := p.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		p.log(ctx).Error("Failure to select deleted algorithms from table", loggers.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			p.log(ctx).Error("failed to scan deleted algorithms from data", loggers.Err(err))
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// syncGuardColumns - колонки защиты от массового удаления в порядке scanSyncGuard
+const syncGuardColumns = `alert, pods, managed_pods, reason, raised_at, confirmed, confirmed_at`
+
+// scanSyncGuard - чтение защиты от массового удаления, отсутствие строки - защита не поднималась
+func scanSyncGuard(row *sql.Row) (*model.SyncGuard, error) {
+	var (
+		g      model.SyncGuard
+		reason sql.NullString
+	)
+	err := row.Scan(&g.Alert, pq.Array(&g.Pods), &g.ManagedPods, &reason, &g.RaisedAt, &g.Confirmed, &g.ConfirmedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &model.SyncGuard{}, nil
+		}
+		return nil, err
+	}
+	g.Reason = reason.String
+	return &g, nil
+}
+
+// GetSyncGuard - получение состояния защиты от массового удаления
+func (p *PGStore) GetSyncGuard(ctx context.Context) (*model.SyncGuard, error) {
+	g, err := scanSyncGuard(p.db.QueryRowContext(ctx, `SELECT `+syncGuardColumns+` FROM sync_guard WHERE id=1`))
+	if err != nil {
+		p.log(ctx).Error("Failure to select sync guard from table", loggers.Err(err))
+		return nil, err
+	}
+	return g, nil
+}
+
+// lockSyncGuard - защита от массового удаления, строка заблокирована до конца транзакции
+func (p *PGStore) lockSyncGuard(ctx context.Context, tx *sql.Tx) (*model.SyncGuard, error) {
+	g, err := scanSyncGuard(tx.QueryRowContext(ctx, `SELECT `+syncGuardColumns+` FROM sync_guard WHERE id=1 FOR UPDATE`))
+	if err != nil {
+		p.log(ctx).Error("Failure to lock sync guard in table", loggers.Err(err))
+		return nil, err
+	}
+	return g, nil
+}
+
+// RaiseSyncGuard - поднятие тревоги о заблокированном массовом удалении.
+// Подтверждение предыдущей тревоги сбрасывается, время первой тревоги сохраняется.
+func (p *PGStore) RaiseSyncGuard(ctx context.Context, guard *model.SyncGuard) error {
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := p.lockSyncGuard(ctx, tx)
+		if err != nil {
+			return err
+		}
+		q := `INSERT INTO sync_guard (id, alert, pods, managed_pods, reason, raised_at, confirmed, confirmed_at)
+			VALUES (1, TRUE, $1, $2, $3, $4, FALSE, NULL)
+			ON CONFLICT (id) DO UPDATE SET alert=TRUE, pods=EXCLUDED.pods, managed_pods=EXCLUDED.managed_pods,
+				reason=EXCLUDED.reason, confirmed=FALSE, confirmed_at=NULL,
+				raised_at=CASE WHEN sync_guard.alert THEN sync_guard.raised_at ELSE EXCLUDED.raised_at END
+			RETURNING ` + syncGuardColumns
+		after, err := scanSyncGuard(tx.QueryRowContext(ctx, q, pq.Array(guard.Pods), guard.ManagedPods, guard.Reason, time.Now()))
+		if err != nil {
+			p.log(ctx).Error("Failure to raise sync guard in table", loggers.Err(err))
+			return err
+		}
+		return p.audit(ctx, tx, model.AuditRaise, model.EntitySyncGuard, 0, 0, before, after)
+	})
+}
+
+// ConfirmSyncGuard - подтверждение заблокированного массового удаления
+func (p *PGStore) ConfirmSyncGuard(ctx context.Context) error {
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := p.lockSyncGuard(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if !before.Alert {
+			return model.ErrorNoSyncAlert
+		}
+		q := `UPDATE sync_guard SET confirmed=TRUE, confirmed_at=$1 WHERE id=1 RETURNING ` + syncGuardColumns
+		after, err := scanSyncGuard(tx.QueryRowContext(ctx, q, time.Now()))
+		if err != nil {
+			p.log(ctx).Error("Failure to confirm sync guard in table", loggers.Err(err))
+			return err
+		}
+		return p.audit(ctx, tx, model.AuditConfirm, model.EntitySyncGuard, 0, 0, before, after)
+	})
+}
+
+// ResetSyncGuard - сброс тревоги и подтверждения
+func (p *PGStore) ResetSyncGuard(ctx context.Context) error {
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := p.lockSyncGuard(ctx, tx)
+		if err != nil {
+			return err
+		}
+		q := `UPDATE sync_guard SET alert=FALSE, pods=NULL, managed_pods=0, reason=NULL, raised_at=NULL,
+			confirmed=FALSE, confirmed_at=NULL WHERE id=1`
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			p.log(ctx).Error("Failure to reset sync guard in table", loggers.Err(err))
+			return err
+		}
+		return p.audit(ctx, tx, model.AuditReset, model.EntitySyncGuard, 0, 0, before, &model.SyncGuard{})
+	})
+}
+
+// AddHalt - фиксация срабатывания kill switch
+func (p *PGStore) AddHalt(ctx context.Context, h *model.Halt) error {
+	if h.ClientID == 0 && !model.ScopeFromContext(ctx).Unrestricted() {
+		return model.ErrorOutOfScope
+	}
+	if err := p.checkScope(ctx, h.ClientID); err != nil {
+		return err
+	}
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		q := `INSERT INTO halts (scope, client_id, algorithm, reason, triggered_by)
+			VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+		err := tx.QueryRowContext(ctx, q, h.Scope, nullInt64(h.ClientID), nullString(string(h.Algorithm)), h.Reason,
+			h.TriggeredBy).Scan(&h.ID, &h.CreatedAt)
+		if err != nil {
+			p.log(ctx).Error("Failure to insert halt into table", loggers.Err(err))
+			return err
+		}
+		return p.audit(ctx, tx, model.AuditCreate, model.EntityHalt, h.ID, h.ClientID, nil, h)
+	})
+}
+
+// GetHalts - получение остановок, activeOnly - только не возобновлённые
+func (p *PGStore) GetHalts(ctx context.Context, activeOnly bool) ([]model.Halt, error) {
+	var (
+		where []string
+		args  []any
+	)
+	if activeOnly {
+		where = append(where, `resumed_at IS NULL`)
+	}
+	// остановки без клиента действуют на всех, поэтому видны в любой области
+	if cond := scopeCondition(ctx, "client_id", &args); cond != "" {
+		where = append(where, `(client_id IS NULL OR `+cond+`)`)
+	}
+	q := `SELECT id, scope, client_id, algorithm, reason, triggered_by, created_at, resumed_at, resumed_by FROM halts`
+	if len(where) > 0 {
+		q += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	q += ` ORDER BY id`
+	rows, err := p.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		p.log(ctx).Error("Failure to select halts from table", loggers.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var halts []model.Halt
+	for rows.Next() {
+		var (
+			h         model.Halt
+			clientID  sql.NullInt64
+			algorithm sql.NullString
+			resumedBy sql.NullString
+		)
+		if err := rows.Scan(&h.ID, &h.Scope, &clientID, &algorithm, &h.Reason, &h.TriggeredBy, &h.CreatedAt,
+			&h.ResumedAt, &resumedBy); err != nil {
+			p.log(ctx).Error("failed to scan halts from data", loggers.Err(err))
+			return nil, err
+		}
+		h.ClientID = clientID.Int64
+		h.Algorithm = model.AlgorithmType(algorithm.String)
+		h.ResumedBy = resumedBy.String
+		halts = append(halts, h)
+	}
+	return halts, rows.Err()
+}
+
+// ResumeHalt - снятие остановки
+func (p *PGStore) ResumeHalt(ctx context.Context, id int64, resumedBy string) error {
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		args := []any{time.Now(), resumedBy, id}
+		q := `UPDATE halts SET resumed_at=$1, resumed_by=$2 WHERE id=$3 AND resumed_at IS NULL` +
+			scopeClause(ctx, "client_id", &args) + ` RETURNING client_id`
+		var clientID sql.NullInt64
+		if err := tx.QueryRowContext(ctx, q, args...).Scan(&clientID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return model.ErrorHaltNotFound
+			}
+			p.log(ctx).Error("Failure to resume halt in table", loggers.Err(err))
+			return err
+		}
+		before := map[string]any{"resumed_at": nil, "resumed_by": nil}
+		after := map[string]any{"resumed_at": args[0], "resumed_by": resumedBy}
+		return p.audit(ctx, tx, model.AuditResume, model.EntityHalt, id, clientID.Int64, before, after)
+	})
+}
+
+// nullInt64 - NULL вместо нулевого значения
+func nullInt64(v int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: v, Valid: v != 0}
+}
+
+// nullString - NULL вместо пустой строки
+func nullString(v string) sql.NullString {
+	return sql.NullString{String: v, Valid: v != ""}
+}
+
+// SetClientSuspension - приостановка или возобновление синхронизации клиента
+func (p *PGStore) SetClientSuspension(ctx context.Context, s *model.Suspension) error {
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		args := []any{s.ClientID}
+		q := `SELECT COALESCE(suspended, FALSE), suspended_until, suspend_reason FROM clients
+			WHERE id=$1 AND deleted_at IS NULL` + scopeClause(ctx, "id", &args) + ` FOR UPDATE`
+		var (
+			before = model.Suspension{ClientID: s.ClientID}
+			reason sql.NullString
+		)
+		if err := tx.QueryRowContext(ctx, q, args...).Scan(&before.Suspended, &before.Until, &reason); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return model.ErrorClientNotFound
+			}
+			p.log(ctx).Error("Failure to lock client suspension in table", loggers.Err(err))
+			return err
+		}
+		before.Reason = reason.String
+
+		q = `UPDATE clients SET suspended=$1, suspended_until=$2, suspend_reason=$3 WHERE id=$4`
+		if _, err := tx.ExecContext(ctx, q, s.Suspended, s.Until, nullString(s.Reason), s.ClientID); err != nil {
+			p.log(ctx).Error("Failure to update client suspension in table", loggers.Err(err))
+			return err
+		}
+		return p.audit(ctx, tx, model.AuditUpdate, model.EntitySuspension, s.ClientID, s.ClientID, &before, s)
+	})
+}
+
+// GetSuspendedClients - получение действующих приостановок клиентов, истёкшие не возвращаются
+func (p *PGStore) GetSuspendedClients(ctx context.Context) ([]model.Suspension, error) {
+	var args []any
+	q := `SELECT id, suspended_until, suspend_reason FROM clients
+			WHERE suspended AND (suspended_until IS NULL OR suspended_until > now()) AND deleted_at IS NULL` +
+		scopeClause(ctx, "id", &args) + ` ORDER BY id`
+	rows, err := p.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		p.log(ctx).Error("Failure to select suspended clients from table", loggers.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suspensions []model.Suspension
+	for rows.Next() {
+		var (
+			s      = model.Suspension{Suspended: true}
+			reason sql.NullString
+		)
+		if err := rows.Scan(&s.ClientID, &s.Until, &reason); err != nil {
+			p.log(ctx).Error("failed to scan suspended clients from data", loggers.Err(err))
+			return nil, err
+		}
+		s.Reason = reason.String
+		suspensions = append(suspensions, s)
+	}
+	return suspensions, rows.Err()
+}
+
+// freezeColumns - колонки заморозки синхронизации в порядке scanFreeze
+const freezeColumns = `frozen, frozen_until, reason, updated_at`
+
+// scanFreeze - чтение заморозки синхронизации, отсутствие строки - заморозки не было
+func scanFreeze(row *sql.Row) (*model.Freeze, error) {
+	var (
+		f      model.Freeze
+		reason sql.NullString
+	)
+	if err := row.Scan(&f.Frozen, &f.Until, &reason, &f.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &model.Freeze{}, nil
+		}
+		return nil, err
+	}
+	f.Reason = reason.String
+	return &f, nil
+}
+
+// GetFreeze - получение глобальной заморозки синхронизации
+func (p *PGStore) GetFreeze(ctx context.Context) (*model.Freeze, error) {
+	f, err := scanFreeze(p.db.QueryRowContext(ctx, `SELECT `+freezeColumns+` FROM sync_freeze WHERE id=1`))
+	if err != nil {
+		p.log(ctx).Error("Failure to select sync freeze from table", loggers.Err(err))
+		return nil, err
+	}
+	return f, nil
+}
+
+// SetFreeze - установка или снятие глобальной заморозки синхронизации
+func (p *PGStore) SetFreeze(ctx context.Context, f *model.Freeze) error {
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := scanFreeze(tx.QueryRowContext(ctx, `SELECT `+freezeColumns+` FROM sync_freeze WHERE id=1 FOR UPDATE`))
+		if err != nil {
+			p.log(ctx).Error("Failure to lock sync freeze in table", loggers.Err(err))
+			return err
+		}
+		q := `INSERT INTO sync_freeze (id, frozen, frozen_until, reason, updated_at) VALUES (1, $1, $2, $3, now())
+			ON CONFLICT (id) DO UPDATE SET frozen=EXCLUDED.frozen, frozen_until=EXCLUDED.frozen_until,
+				reason=EXCLUDED.reason, updated_at=EXCLUDED.updated_at
+			RETURNING updated_at`
+		if err := tx.QueryRowContext(ctx, q, f.Frozen, f.Until, nullString(f.Reason)).Scan(&f.UpdatedAt); err != nil {
+			p.log(ctx).Error("Failure to update sync freeze in table", loggers.Err(err))
+			return err
+		}
+		return p.audit(ctx, tx, model.AuditUpdate, model.EntityFreeze, 0, 0, before, f)
+	})
+}
+
+// GetSyncStatus - получение итога последнего прохода синхронизации
+func (p *PGStore) GetSyncStatus(ctx context.Context) (*model.SyncStatus, error) {
+	q := `SELECT result, started_at, finished_at, created, deleted, skipped_clients, error, last_success_at
+			FROM sync_status WHERE id=1`
+	var (
+		st          model.SyncStatus
+		skipped     pq.Int64Array
+		msg         sql.NullString
+		lastSuccess sql.NullTime
+	)
+	err := p.db.QueryRowContext(ctx, q).Scan(&st.Result, &st.StartedAt, &st.FinishedAt, &st.Created, &st.Deleted,
+		&skipped, &msg, &lastSuccess)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrorNoSyncStatus
+		}
+		p.log(ctx).Error("Failure to select sync status from table", loggers.Err(err))
+		return nil, err
+	}
+	st.SkippedClients = skipped
+	st.Error = msg.String
+	if lastSuccess.Valid {
+		st.LastSuccessAt = &lastSuccess.Time
+	}
+	return &st, nil
+}
+
+// SaveSyncStatus - сохранение итога прохода синхронизации, проход с итогом ok обновляет last_success_at
+func (p *PGStore) SaveSyncStatus(ctx context.Context, st *model.SyncStatus) error {
+	q := `INSERT INTO sync_status (id, result, started_at, finished_at, created, deleted, skipped_clients, error,
+				last_success_at)
+			VALUES (1, $1, $2, $3, $4, $5, $6, $7, CASE WHEN $1 = 'ok' THEN $3::timestamptz END)
+			ON CONFLICT (id) DO UPDATE SET result=EXCLUDED.result, started_at=EXCLUDED.started_at,
+				finished_at=EXCLUDED.finished_at, created=EXCLUDED.created, deleted=EXCLUDED.deleted,
+				skipped_clients=EXCLUDED.skipped_clients, error=EXCLUDED.error,
+				last_success_at=COALESCE(EXCLUDED.last_success_at, sync_status.last_success_at)
+			RETURNING last_success_at`
+	var lastSuccess sql.NullTime
+	err := p.db.QueryRowContext(ctx, q, st.Result, st.StartedAt, st.FinishedAt, st.Created, st.Deleted,
+		pq.Int64Array(st.SkippedClients), nullString(st.Error)).Scan(&lastSuccess)
+	if err != nil {
+		p.log(ctx).Error("Failure to save sync status in table", loggers.Err(err))
+		return err
+	}
+	if lastSuccess.Valid {
+		st.LastSuccessAt = &lastSuccess.Time
+	}
+	return nil
+}
+
+// scheduleColumns - колонки расписания в порядке scanSchedule
+const scheduleColumns = `id, client_id, algorithm, timezone, days, open_time, close_time, holidays, updated_at`
+
+// scanSchedule - чтение расписания из строки с колонками scheduleColumns
+func scanSchedule(row interface{ Scan(...any) error }, s *model.Schedule) error {
+	return row.Scan(&s.ID, &s.ClientID, &s.Algorithm, &s.Timezone, pq.Array(&s.Days), &s.Open, &s.Close,
+		pq.Array(&s.Holidays), &s.UpdatedAt)
+}
+
+// GetSchedules - получение расписаний торговых сессий, clientID=0 - всех клиентов
+func (p *PGStore) GetSchedules(ctx context.Context, clientID int64) ([]model.Schedule, error) {
+	args := []any{clientID}
+	q := `SELECT ` + scheduleColumns + ` FROM schedules
+			WHERE ($1=0 OR client_id=$1)` + scopeClause(ctx, "client_id", &args) + ` ORDER BY client_id, algorithm`
+	rows, err := p.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		p.log(ctx).Error("Failure to select schedules from table", loggers.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []model.Schedule
+	for rows.Next() {
+		var s model.Schedule
+		if err := scanSchedule(rows, &s); err != nil {
+			p.log(ctx).Error("failed to scan schedules from data", loggers.Err(err))
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+// SetSchedule - создание или замена расписания клиента или алгоритма клиента
+func (p *PGStore) SetSchedule(ctx context.Context, s *model.Schedule) error {
+	if err := p.checkScope(ctx, s.ClientID); err != nil {
+		return err
+	}
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		action, before := model.AuditUpdate, &model.Schedule{}
+		q := `SELECT ` + scheduleColumns + ` FROM schedules WHERE client_id=$1 AND algorithm=$2 FOR UPDATE`
+		if err := scanSchedule(tx.QueryRowContext(ctx, q, s.ClientID, s.Algorithm), before); err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				p.log(ctx).Error("Failure to lock schedule in table", loggers.Err(err))
+				return err
+			}
+			action, before = model.AuditCreate, nil
+		}
+		q = `INSERT INTO schedules (client_id, algorithm, timezone, days, open_time, close_time, holidays, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, now())
+			ON CONFLICT (client_id, algorithm) DO UPDATE SET timezone=EXCLUDED.timezone, days=EXCLUDED.days,
+				open_time=EXCLUDED.open_time, close_time=EXCLUDED.close_time, holidays=EXCLUDED.holidays,
+				updated_at=EXCLUDED.updated_at
+			RETURNING id, updated_at`
+		err := tx.QueryRowContext(ctx, q, s.ClientID, s.Algorithm, s.Timezone, pq.Array(s.Days), s.Open, s.Close,
+			pq.Array(s.Holidays)).Scan(&s.ID, &s.UpdatedAt)
+		if err != nil {
+			if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+				return model.ErrorClientNotFound
+			}
+			p.log(ctx).Error("Failure to upsert schedule into table", loggers.Err(err))
+			return err
+		}
+		return p.audit(ctx, tx, action, model.EntitySchedule, s.ID, s.ClientID, before, s)
+	})
+}
+
+// DeleteSchedule - удаление расписания, после него алгоритм работает по algorithm_status круглосуточно
+func (p *PGStore) DeleteSchedule(ctx context.Context, clientID int64, algorithm model.AlgorithmType) error {
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		args := []any{clientID, algorithm}
+		q := `DELETE FROM schedules WHERE client_id=$1 AND algorithm=$2` + scopeClause(ctx, "client_id", &args) +
+			` RETURNING ` + scheduleColumns
+		var s model.Schedule
+		if err := scanSchedule(tx.QueryRowContext(ctx, q, args...), &s); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return model.ErrorNoSchedule
+			}
+			p.log(ctx).Error("Failure to delete schedule from table", loggers.Err(err))
+			return err
+		}
+		return p.audit(ctx, tx, model.AuditDelete, model.EntitySchedule, s.ID, clientID, &s, nil)
+	})
+}
+
+// AddScheduledChange - добавление отложенного изменения статуса алгоритма
+func (p *PGStore) AddScheduledChange(ctx context.Context, c *model.ScheduledChange) error {
+	if err := p.checkScope(ctx, c.ClientID); err != nil {
+		return err
+	}
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		q := `INSERT INTO scheduled_changes (client_id, algorithm, enabled, apply_at, created_by)
+			VALUES ($1, $2, $3, $4, $5) RETURNING id, status, created_at`
+		err := tx.QueryRowContext(ctx, q, c.ClientID, c.Algorithm, c.Enabled, c.ApplyAt, nullString(c.CreatedBy)).
+			Scan(&c.ID, &c.Status, &c.CreatedAt)
+		if err != nil {
+			if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+				return model.ErrorClientNotFound
+			}
+			p.log(ctx).Error("Failure to insert scheduled change into table", loggers.Err(err))
+			return err
+		}
+		return p.audit(ctx, tx, model.AuditCreate, model.EntityScheduledChange, c.ID, c.ClientID, nil, c)
+	})
+}
+
+// GetScheduledChanges - получение отложенных изменений, clientID=0 и пустой status - без фильтра
+func (p *PGStore) GetScheduledChanges(ctx context.Context, clientID int64, status model.ChangeStatus) ([]model.ScheduledChange, error) {
+	q := `SELECT id, client_id, algorithm, enabled, apply_at, status, created_by, created_at, applied_at, error,
+				attempts, retry_at
+			FROM scheduled_changes WHERE ($1=0 OR client_id=$1) AND ($2='' OR status=$2)`
+	args := []any{clientID, status}
+	q += scopeClause(ctx, "client_id", &args) + ` ORDER BY apply_at, id`
+	rows, err := p.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		p.log(ctx).Error("Failure to select scheduled changes from table", loggers.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []model.ScheduledChange
+	for rows.Next() {
+		c, err := scanScheduledChange(rows)
+		if err != nil {
+			p.log(ctx).Error("failed to scan scheduled changes from data", loggers.Err(err))
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// CancelScheduledChange - отмена ещё не применённого изменения
+func (p *PGStore) CancelScheduledChange(ctx context.Context, id int64) error {
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		args := []any{id}
+		q := `UPDATE scheduled_changes SET status='cancelled' WHERE id=$1 AND status='pending'` +
+			scopeClause(ctx, "client_id", &args) + ` RETURNING client_id`
+		var clientID int64
+		if err := tx.QueryRowContext(ctx, q, args...).Scan(&clientID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return model.ErrorNoPendingChange
+			}
+			p.log(ctx).Error("Failure to cancel scheduled change in table", loggers.Err(err))
+			return err
+		}
+		before := map[string]any{"status": model.ChangePending}
+		after := map[string]any{"status": model.ChangeCancelled}
+		return p.audit(ctx, tx, model.AuditCancel, model.EntityScheduledChange, id, clientID, before, after)
+	})
+}
+
+// ApplyDueChanges - применение наступивших изменений к algorithm_status в порядке apply_at.
+// Каждое изменение применяется в своей транзакции со сменой его статуса, поэтому сбой одного изменения
+// не откатывает остальные, а при нескольких репликах и после перезапуска изменение применяется ровно один раз.
+// Ошибка возвращается вместе с уже обработанными изменениями, только если изменение не удалось отметить.
+func (p *PGStore) ApplyDueChanges(ctx context.Context) ([]model.ScheduledChange, error) {
+	q := `SELECT id FROM scheduled_changes WHERE status='pending' AND apply_at <= now()
+			AND (retry_at IS NULL OR retry_at <= now()) ORDER BY apply_at, id`
+	rows, err := p.db.QueryContext(ctx, q)
+	if err != nil {
+		p.log(ctx).Error("Failure to select due changes from table", loggers.Err(err))
+		return nil, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			p.log(ctx).Error("failed to scan scheduled changes from data", loggers.Err(err))
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		p.log(ctx).Error("failed to read due changes", loggers.Err(err))
+		return nil, err
+	}
+
+	var changes []model.ScheduledChange
+	for _, id := range ids {
+		c, err := p.applyDueChange(ctx, id)
+		if err != nil {
+			return changes, err
+		}
+		if c != nil {
+			changes = append(changes, *c)
+		}
+	}
+	return changes, nil
+}
+
+// applyDueChange - применение одного наступившего изменения. nil без ошибки - изменение уже обработано
+// другой репликой. Если применить изменение не удалось, его транзакция откатывается, а итог пишется
+// отдельной транзакцией: временный сбой (БД недоступна, deadlock) оставляет изменение pending до повтора
+// через scheduler.retry_backoff, удваивающийся с каждой попыткой. Постоянная ошибка или исчерпанные
+// scheduler.max_attempts попыток отмечают изменение failed, чтобы оно не повторялось на каждом тике.
+func (p *PGStore) applyDueChange(ctx context.Context, id int64) (*model.ScheduledChange, error) {
+	var c *model.ScheduledChange
+	err := p.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		if c, err = p.lockDueChange(ctx, tx, id); err != nil || c == nil {
+			return err
+		}
+		before := *c
+		c.Status = model.ChangeApplied
+		_, err = p.applyAlgorithmPatch(ctx, tx, c.ClientID, model.NewAlgorithmPatch(c.Algorithm, c.Enabled), model.AuditApply)
+		switch {
+		case errors.Is(err, model.ErrorClientNotFound):
+			c.Status, c.Error, err = model.ChangeFailed, "algorithm status not found", nil
+		case errors.Is(err, model.ErrorApprovalRequired):
+			c.Status, c.Error, err = model.ChangeFailed, err.Error(), nil
+		}
+		if err != nil {
+			return err
+		}
+		return p.markChange(ctx, tx, &before, c)
+	})
+	if err == nil || c == nil {
+		return c, err
+	}
+
+	p.log(ctx).Error("Failure to apply scheduled change", slog.Int64("change", id), loggers.Err(err))
+	msg, permanent := err.Error(), permanentError(err)
+	c = nil
+	err = p.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		if c, err = p.lockDueChange(ctx, tx, id); err != nil || c == nil {
+			return err
+		}
+		before := *c
+		c.Error = msg
+		if permanent || c.Attempts+1 >= p.cfg.Scheduler.MaxAttempts {
+			c.Status = model.ChangeFailed
+			return p.markChange(ctx, tx, &before, c)
+		}
+		return p.retryChange(ctx, tx, c)
+	})
+	return c, err
+}
+
+// retryChange - отложенный повтор изменения после временного сбоя, изменение остаётся pending
+func (p *PGStore) retryChange(ctx context.Context, tx *sql.Tx, c *model.ScheduledChange) error {
+	retryAt := time.Now().Add(p.cfg.Scheduler.RetryBackoff << c.Attempts)
+	q := `UPDATE scheduled_changes SET attempts=attempts+1, retry_at=$1, error=$2 WHERE id=$3 RETURNING attempts, retry_at`
+	if err := tx.QueryRowContext(ctx, q, retryAt, c.Error, c.ID).Scan(&c.Attempts, &c.RetryAt); err != nil {
+		p.log(ctx).Error("Failure to schedule change retry", slog.Int64("change", c.ID), loggers.Err(err))
+		return err
+	}
+	return nil
+}
+
+// permanentError - ошибка, которую повтор не исправит: ошибка API с категорией (кроме недоступности
+// и внутренних), ошибка валидации, нарушение ограничений или неверные данные в БД.
+// Остальные ошибки - сбой связи, таймаут, deadlock - считаются временными.
+func permanentError(err error) bool {
+	var (
+		e     *model.Error
+		v     *model.ValidationError
+		pgErr *pq.Error
+	)
+	switch {
+	case errors.As(err, &v):
+		return true
+	case errors.As(err, &e):
+		return e.Kind != model.KindUnavailable && e.Kind != model.KindInternal
+	case errors.As(err, &pgErr):
+		return pgErr.Code.Class() == "22" || pgErr.Code.Class() == "23"
+	}
+	return false
+}
+
+// lockDueChange - блокировка изменения, которое ещё ждёт применения. nil - его держит или уже применила другая реплика.
+func (p *PGStore) lockDueChange(ctx context.Context, tx *sql.Tx, id int64) (*model.ScheduledChange, error) {
+	q := `SELECT id, client_id, algorithm, enabled, apply_at, status, created_by, created_at, applied_at, error,
+				attempts, retry_at
+			FROM scheduled_changes WHERE id=$1 AND status='pending' FOR UPDATE SKIP LOCKED`
+	rows, err := tx.QueryContext(ctx, q, id)
+	if err != nil {
+		p.log(ctx).Error("Failure to select due change from table", loggers.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	c, err := scanScheduledChange(rows)
+	if err != nil {
+		p.log(ctx).Error("failed to scan scheduled change from data", loggers.Err(err))
+		return nil, err
+	}
+	return &c, nil
+}
+
+// markChange - запись итога изменения и аудит смены его статуса
+func (p *PGStore) markChange(ctx context.Context, tx *sql.Tx, before, c *model.ScheduledChange) error {
+	q := `UPDATE scheduled_changes SET status=$1, applied_at=now(), error=$2 WHERE id=$3 RETURNING applied_at`
+	if err := tx.QueryRowContext(ctx, q, c.Status, nullString(c.Error), c.ID).Scan(&c.AppliedAt); err != nil {
+		p.log(ctx).Error("Failure to mark scheduled change", slog.Int64("change", c.ID), loggers.Err(err))
+		return err
+	}
+	return p.audit(ctx, tx, model.AuditApply, model.EntityScheduledChange, c.ID, c.ClientID, before, c)
+}
+
+// BulkUpdateAlgorithms - пакетное изменение алгоритмов в одной транзакции, клиенты обрабатываются по возрастанию id.
+// Изменение несуществующего клиента или включение без подтверждения - ошибка элемента: в режиме atomic
+// откатывается весь пакет, в режиме best_effort элемент пропускается. Прочие ошибки БД откатывают пакет и возвращаются целиком.
+func (p *PGStore) BulkUpdateAlgorithms(ctx context.Context, mode model.BulkMode, changes []model.AlgorithmChange) (*model.BulkResult, error) {
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		p.log(ctx).Error("failed to begin transaction", loggers.Err(err))
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// строки блокируются по возрастанию client_id, иначе два пакета с клиентами в разном порядке
+	// ждут друг друга. Изменения одного клиента применяются в порядке запроса.
+	order := make([]int, len(changes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return changes[order[a]].ClientID < changes[order[b]].ClientID })
+
+	result := &model.BulkResult{Mode: mode, Results: make([]model.BulkItemResult, len(changes))}
+	failed := false
+	for _, i := range order {
+		c := changes[i]
+		item := &result.Results[i]
+		item.Index, item.AlgorithmChange = i, c
+		if failed {
+			item.Status = model.BulkSkipped
+			continue
+		}
+		as, err := p.applyAlgorithmPatch(ctx, tx, c.ClientID, model.NewAlgorithmPatch(c.Algorithm, c.Enabled), model.AuditBulk)
+		switch {
+		case err == nil:
+			item.Status, item.State = model.BulkApplied, as
+		case errors.Is(err, model.ErrorClientNotFound):
+			item.Status, item.Code = model.BulkFailed, model.ErrorClientNotFound.Code
+			failed = mode == model.BulkAtomic
+		case errors.Is(err, model.ErrorApprovalRequired):
+			item.Status, item.Code = model.BulkFailed, model.ErrorApprovalRequired.Code
+			failed = mode == model.BulkAtomic
+		default:
+			p.log(ctx).Error("Failure to apply bulk algorithm change", slog.Int("index", i), loggers.Err(err))
+			return nil, err
+		}
+	}
+	if failed {
+		for i := range result.Results {
+			if item := &result.Results[i]; item.Status == model.BulkApplied {
+				item.Status, item.State = model.BulkRolledBack, nil
+			}
+		}
+		return result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		p.log(ctx).Error("failed to commit transaction", loggers.Err(err))
+		return nil, err
+	}
+	result.Applied = true
+	return result, nil
+}
+
+// scanScheduledChange - чтение отложенного изменения из строки выборки
+func scanScheduledChange(rows *sql.Rows) (model.ScheduledChange, error) {
+	var (
+		c         model.ScheduledChange
+		createdBy sql.NullString
+		msg       sql.NullString
+	)
+	err := rows.Scan(&c.ID, &c.ClientID, &c.Algorithm, &c.Enabled, &c.ApplyAt, &c.Status, &createdBy, &c.CreatedAt,
+		&c.AppliedAt, &msg, &c.Attempts, &c.RetryAt)
+	c.CreatedBy = createdBy.String
+	c.Error = msg.String
+	return c, err
+}
+
+// ReserveIdempotencyKey - захват ключа идемпотентности под новый запрос.
+// Возвращает nil, если ключ свободен или истёк и теперь закреплён за запросом, иначе - сохранённую запись.
+func (p *PGStore) ReserveIdempotencyKey(ctx context.Context, rec *model.IdempotencyRecord) (*model.IdempotencyRecord, error) {
+	q := `INSERT INTO idempotency_keys (key, request_hash, expires_at) VALUES ($1, $2, $3)
+			ON CONFLICT (key) DO UPDATE SET request_hash=EXCLUDED.request_hash, status=0, headers=NULL, body=NULL,
+				created_at=now(), expires_at=EXCLUDED.expires_at
+			WHERE idempotency_keys.expires_at <= now()
+			RETURNING key`
+	var key string
+	err := p.db.QueryRowContext(ctx, q, rec.Key, rec.RequestHash, rec.ExpiresAt).Scan(&key)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		p.log(ctx).Error("Failure to reserve idempotency key", loggers.Err(err))
+		return nil, err
+	}
+
+	q = `SELECT key, request_hash, status, headers, body, expires_at FROM idempotency_keys WHERE key=$1`
+	var (
+		saved   model.IdempotencyRecord
+		headers []byte
+	)
+	err = p.db.QueryRowContext(ctx, q, rec.Key).Scan(&saved.Key, &saved.RequestHash, &saved.Status, &headers, &saved.Body,
+		&saved.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// ключ удалили между запросами - первый запрос завершился ошибкой, повторить можно сразу
+			return nil, model.ErrorIdempotencyInProgress
+		}
+		p.log(ctx).Error("Failure to select idempotency key", loggers.Err(err))
+		return nil, err
+	}
+	if len(headers) > 0 {
+		if err := json.Unmarshal(headers, &saved.Headers); err != nil {
+			p.log(ctx).Error("Failure to decode idempotency headers", loggers.Err(err))
+			return nil, err
+		}
+	}
+	return &saved, nil
+}
+
+// SaveIdempotencyResponse - сохранение ответа на запрос с ключом идемпотентности
+func (p *PGStore) SaveIdempotencyResponse(ctx context.Context, rec *model.IdempotencyRecord) error {
+	headers, err := json.Marshal(rec.Headers)
+	if err != nil {
+		return err
+	}
+	q := `UPDATE idempotency_keys SET status=$1, headers=$2, body=$3 WHERE key=$4 AND request_hash=$5`
+	if _, err := p.db.ExecContext(ctx, q, rec.Status, headers, rec.Body, rec.Key, rec.RequestHash); err != nil {
+		p.log(ctx).Error("Failure to save idempotent response", loggers.Err(err))
+		return err
+	}
+	return nil
+}
+
+// DeleteIdempotencyKey - освобождение ключа, чтобы запрос можно было повторить
+func (p *PGStore) DeleteIdempotencyKey(ctx context.Context, key string) error {
+	if _, err := p.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE key=$1`, key); err != nil {
+		p.log(ctx).Error("Failure to delete idempotency key", loggers.Err(err))
+		return err
+	}
+	return nil
+}
+
+// PurgeIdempotencyKeys - удаление истёкших ключей идемпотентности
+func (p *PGStore) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	res, err := p.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= now()`)
+	if err != nil {
+		p.log(ctx).Error("Failure to purge idempotency keys", loggers.Err(err))
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// apiKeyColumns - колонки ключа API в порядке scanAPIKey
+const apiKeyColumns = `id, name, prefix, role, client_ids, client_tags, created_at, last_used_at, revoked_at`
+
+// scanAPIKey - чтение ключа API из строки с колонками apiKeyColumns
+func scanAPIKey(row interface{ Scan(...any) error }, k *model.APIKey) error {
+	var (
+		ids  pq.Int64Array
+		tags pq.StringArray
+	)
+	if err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.Role, &ids, &tags, &k.CreatedAt, &k.LastUsedAt,
+		&k.RevokedAt); err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		k.Scope.ClientIDs = ids
+	}
+	if len(tags) > 0 {
+		k.Scope.Tags = tags
+	}
+	return nil
+}
+
+// AddAPIKey - сохранение нового ключа API, в БД попадает только хеш ключа
+func (p *PGStore) AddAPIKey(ctx context.Context, k *model.APIKey, hash string) error {
+	ids := pq.Int64Array(k.Scope.ClientIDs)
+	if ids == nil {
+		ids = pq.Int64Array{}
+	}
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		q := `INSERT INTO api_keys (name, prefix, key_hash, role, client_ids, client_tags) VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING ` + apiKeyColumns
+		if err := scanAPIKey(tx.QueryRowContext(ctx, q, k.Name, k.Prefix, hash, k.Role, ids, textArray(k.Scope.Tags)),
+			k); err != nil {
+			p.log(ctx).Error("Failure to insert api key into table", loggers.Err(err))
+			return err
+		}
+		return p.audit(ctx, tx, model.AuditCreate, model.EntityAPIKey, k.ID, 0, nil, k)
+	})
+}
+
+// GetAPIKeys - получение всех ключей API, включая отозванные
+func (p *PGStore) GetAPIKeys(ctx context.Context) ([]model.APIKey, error) {
+	rows, err := p.db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY id`)
+	if err != nil {
+		p.log(ctx).Error("Failure to select api keys from table", loggers.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []model.APIKey
+	for rows.Next() {
+		var k model.APIKey
+		if err := scanAPIKey(rows, &k); err != nil {
+			p.log(ctx).Error("failed to scan api keys from data", loggers.Err(err))
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// AuthenticateAPIKey - поиск действующего ключа по хешу с отметкой времени использования
+func (p *PGStore) AuthenticateAPIKey(ctx context.Context, hash string) (*model.APIKey, error) {
+	q := `UPDATE api_keys SET last_used_at=now() WHERE key_hash=$1 AND revoked_at IS NULL RETURNING ` + apiKeyColumns
+	var k model.APIKey
+	if err := scanAPIKey(p.db.QueryRowContext(ctx, q, hash), &k); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrorAPIKeyNotFound
+		}
+		p.log(ctx).Error("Failure to authenticate api key", loggers.Err(err))
+		return nil, err
+	}
+	return &k, nil
+}
+
+// RevokeAPIKey - отзыв ключа API, запись остаётся для журнала
+func (p *PGStore) RevokeAPIKey(ctx context.Context, id int64) error {
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		var revokedAt time.Time
+		q := `UPDATE api_keys SET revoked_at=now() WHERE id=$1 AND revoked_at IS NULL RETURNING revoked_at`
+		if err := tx.QueryRowContext(ctx, q, id).Scan(&revokedAt); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return model.ErrorAPIKeyNotFound
+			}
+			p.log(ctx).Error("Failure to revoke api key", loggers.Err(err))
+			return err
+		}
+		before := map[string]any{"revoked_at": nil}
+		after := map[string]any{"revoked_at": revokedAt}
+		return p.audit(ctx, tx, model.AuditRevoke, model.EntityAPIKey, id, 0, before, after)
+	})
+}
+
+// approvalStatus - статус запроса на подтверждение, нерассмотренный до expires_at запрос считается истёкшим
+const approvalStatus = `CASE WHEN status='pending' AND expires_at <= now() THEN 'expired' ELSE status END`
+
+// approvalColumns - колонки запроса на подтверждение в порядке scanApproval
+const approvalColumns = `id, client_id, vwap, twap, hft, ` + approvalStatus + `, requested_by, created_at, expires_at,
+	decided_by, decided_at, comment`
+
+// scanApproval - чтение запроса на подтверждение из строки с колонками approvalColumns
+func scanApproval(row interface{ Scan(...any) error }, a *model.ApprovalRequest) error {
+	var (
+		vwap, twap, hft    sql.NullBool
+		decidedBy, comment sql.NullString
+	)
+	if err := row.Scan(&a.ID, &a.ClientID, &vwap, &twap, &hft, &a.Status, &a.RequestedBy, &a.CreatedAt, &a.ExpiresAt,
+		&decidedBy, &a.DecidedAt, &comment); err != nil {
+		return err
+	}
+	a.Changes = model.AlgorithmPatch{VWAP: nullBoolPtr(vwap), TWAP: nullBoolPtr(twap), HFT: nullBoolPtr(hft)}
+	a.DecidedBy, a.Comment = decidedBy.String, comment.String
+	return nil
+}
+
+func nullBoolPtr(v sql.NullBool) *bool {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Bool
+}
+
+// AddApproval - сохранение запроса на подтверждение изменения алгоритмов клиента.
+// В a записываются id, статус и время создания.
+func (p *PGStore) AddApproval(ctx context.Context, a *model.ApprovalRequest) error {
+	if err := p.checkScope(ctx, a.ClientID); err != nil {
+		return err
+	}
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		q := `INSERT INTO approvals (client_id, vwap, twap, hft, requested_by, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, status, created_at`
+		err := tx.QueryRowContext(ctx, q, a.ClientID, a.Changes.VWAP, a.Changes.TWAP, a.Changes.HFT, a.RequestedBy,
+			a.ExpiresAt).Scan(&a.ID, &a.Status, &a.CreatedAt)
+		if err != nil {
+			if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+				return model.ErrorClientNotFound
+			}
+			p.log(ctx).Error("Failure to insert approval into table", loggers.Err(err))
+			return err
+		}
+		return p.audit(ctx, tx, model.AuditCreate, model.EntityApproval, a.ID, a.ClientID, nil, a)
+	})
+}
+
+// GetApproval - запрос на подтверждение по id
+func (p *PGStore) GetApproval(ctx context.Context, id int64) (*model.ApprovalRequest, error) {
+	args := []any{id}
+	q := `SELECT ` + approvalColumns + ` FROM approvals WHERE id=$1` + scopeClause(ctx, "client_id", &args)
+	var a model.ApprovalRequest
+	if err := scanApproval(p.db.QueryRowContext(ctx, q, args...), &a); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrorApprovalNotFound
+		}
+		p.log(ctx).Error("Failure to select approval from table", loggers.Err(err))
+		return nil, err
+	}
+	return &a, nil
+}
+
+// GetApprovals - запросы на подтверждение, новые первыми. clientID=0 и пустой status - без фильтра.
+func (p *PGStore) GetApprovals(ctx context.Context, clientID int64, status model.ApprovalStatus) ([]model.ApprovalRequest, error) {
+	args := []any{clientID, status}
+	q := `SELECT ` + approvalColumns + ` FROM approvals WHERE ($1=0 OR client_id=$1) AND ($2='' OR ` + approvalStatus + `=$2)` +
+		scopeClause(ctx, "client_id", &args) + ` ORDER BY id DESC`
+	rows, err := p.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		p.log(ctx).Error("Failure to select approvals from table", loggers.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var approvals []model.ApprovalRequest
+	for rows.Next() {
+		var a model.ApprovalRequest
+		if err := scanApproval(rows, &a); err != nil {
+			p.log(ctx).Error("failed to scan approvals from data", loggers.Err(err))
+			return nil, err
+		}
+		approvals = append(approvals, a)
+	}
+	return approvals, rows.Err()
+}
+
+// DecideApproval - подтверждение или отклонение запроса. Подтверждённое изменение применяется к algorithm_status
+// в той же транзакции, что и смена статуса запроса. Подтвердить запрос может только не его автор.
+func (p *PGStore) DecideApproval(ctx context.Context, id int64, status model.ApprovalStatus, decidedBy, comment string) (*model.ApprovalRequest, error) {
+	var a model.ApprovalRequest
+	err := p.inTx(ctx, func(tx *sql.Tx) error {
+		args := []any{id}
+		q := `SELECT ` + approvalColumns + ` FROM approvals WHERE id=$1` + scopeClause(ctx, "client_id", &args) + ` FOR UPDATE`
+		if err := scanApproval(tx.QueryRowContext(ctx, q, args...), &a); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return model.ErrorApprovalNotFound
+			}
+			p.log(ctx).Error("Failure to select approval from table", loggers.Err(err))
+			return err
+		}
+		if a.Status != model.ApprovalPending {
+			return model.ErrorApprovalNotPending
+		}
+		action := model.AuditReject
+		if status == model.ApprovalApproved {
+			if model.PrincipalIdentity(decidedBy) == model.PrincipalIdentity(a.RequestedBy) {
+				return model.ErrorSelfApproval
+			}
+			if _, err := p.applyAlgorithmPatch(ctx, tx, a.ClientID, &a.Changes, model.AuditApprove); err != nil {
+				return err
+			}
+			action = model.AuditApprove
+		}
+		before := a
+		q = `UPDATE approvals SET status=$1, decided_by=$2, decided_at=now(), comment=$3 WHERE id=$4 RETURNING decided_at`
+		if err := tx.QueryRowContext(ctx, q, status, decidedBy, nullString(comment), id).Scan(&a.DecidedAt); err != nil {
+			p.log(ctx).Error("Failure to update approval in table", loggers.Err(err))
+			return err
+		}
+		a.Status, a.DecidedBy, a.Comment = status, decidedBy, comment
+		return p.audit(ctx, tx, action, model.EntityApproval, id, a.ClientID, &before, &a)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// GetAuditLog - записи журнала аудита по фильтру, новые первыми.
+// Вызывающему с ограниченной областью видимости видны только записи его клиентов.
+func (p *PGStore) GetAuditLog(ctx context.Context, f model.AuditFilter) ([]model.AuditEntry, error) {
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, v any) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if f.ClientID != 0 {
+		add("client_id=$%d", f.ClientID)
+	}
+	if f.Actor != "" {
+		add("actor=$%d", f.Actor)
+	}
+	if !f.From.IsZero() {
+		add("created_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("created_at < $%d", f.To)
+	}
+	if f.BeforeID != 0 {
+		add("id < $%d", f.BeforeID)
+	}
+	if cond := scopeCondition(ctx, "client_id", &args); cond != "" {
+		where = append(where, cond)
+	}
+	q := `SELECT id, actor, action, entity, entity_id, client_id, diff, request_id, created_at FROM audit_log`
+	if len(where) > 0 {
+		q += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	args = append(args, f.Limit)
+	q += fmt.Sprintf(` ORDER BY id DESC LIMIT $%d`, len(args))
+	rows, err := p.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		p.log(ctx).Error("Failure to select audit log from table", loggers.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []model.AuditEntry
+	for rows.Next() {
+		var (
+			e                  model.AuditEntry
+			entityID, clientID sql.NullInt64
+			diff               []byte
+			requestID          sql.NullString
+		)
+		if err := rows.Scan(&e.ID, &e.Actor, &e.Action, &e.Entity, &entityID, &clientID, &diff, &requestID,
+			&e.CreatedAt); err != nil {
+			p.log(ctx).Error("failed to scan audit log from data", loggers.Err(err))
+			return nil, err
+		}
+		if err := json.Unmarshal(diff, &e.Diff); err != nil {
+			p.log(ctx).Error("failed to decode audit diff", slog.Int64("entry", e.ID), loggers.Err(err))
+			return nil, err
+		}
+		e.EntityID, e.ClientID, e.RequestID = entityID.Int64, clientID.Int64, requestID.String
+		entries = append(entries, e)
 	}
-	return algorithms, nil
+	return entries, rows.Err()
 }
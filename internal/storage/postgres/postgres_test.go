@@ -3,10 +3,12 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"github.com/CyrilSbrodov/syncService/cmd/loggers"
 	"github.com/CyrilSbrodov/syncService/internal/config"
 	"github.com/CyrilSbrodov/syncService/internal/model"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -22,6 +24,40 @@ func newMock() (*sql.DB, sqlmock.Sqlmock, error) {
 	return db, mock, nil
 }
 
+// clientRows - строки ответа с колонками клиента
+func clientRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "client_name", "version", "image", "cpu", "memory", "priority",
+		"need_restart", "spawned_at", "created_at", "updated_at", "revision", "tags", "deletion_protected"})
+}
+
+// algorithmRows - строки ответа с колонками статусов алгоритмов
+func algorithmRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "client_id", "vwap", "twap", "hft"})
+}
+
+// expectAudit - запись в журнал аудита в транзакции изменения
+func expectAudit(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+// expectRevision - запись ревизии клиента в транзакции изменения, clientRevision - ревизия строки клиента.
+// Номер записанной ревизии - revision.
+func expectRevision(mock sqlmock.Sqlmock, revision, clientRevision int64) {
+	mock.ExpectQuery("INSERT INTO client_revisions").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), clientRevision).
+		WillReturnRows(sqlmock.NewRows([]string{"revision", "created_at"}).AddRow(revision, time.Now()))
+}
+
+// expectRestart - need_restart после изменения образа, версии или ресурсов клиента
+func expectRestart(mock sqlmock.Sqlmock, id int64) {
+	mock.ExpectExec(`UPDATE clients SET need_restart=TRUE WHERE id=\$1`).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// lockedClient - строка клиента, заблокированного перед изменением алгоритмов
+func lockedClient(id int64) *sqlmock.Rows {
+	return clientRows().AddRow(id, "client", 2, "algo/hft:1.0", "500m", "1Gi", 10, false, nil, time.Now(), time.Now(), 1, "{}", false)
+}
+
 func TestPGStore_AddClient(t *testing.T) {
 	db, mock, err := newMock()
 	require.NoError(t, err)
@@ -48,54 +84,317 @@ func TestPGStore_AddClient(t *testing.T) {
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
+	created := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
 
+	mock.ExpectBegin()
 	mock.ExpectQuery("INSERT INTO clients").
-		WithArgs(client.ClientName, client.Version, client.Image, client.CPU, client.Memory, client.Priority, client.NeedRestart, client.SpawnedAt, client.CreatedAt, client.UpdatedAt).
-		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		WithArgs(client.ClientName, client.Version, client.Image, client.CPU, client.Memory, client.Priority, client.NeedRestart,
+			pq.StringArray{}).
+		WillReturnRows(clientRows().AddRow(1, client.ClientName, client.Version, client.Image, client.CPU, client.Memory,
+			client.Priority, client.NeedRestart, nil, created, created, 1, "{}", false))
 
-	mock.ExpectExec("INSERT INTO algorithm_status").
+	mock.ExpectQuery("INSERT INTO algorithm_status").
 		WithArgs(1).
+		WillReturnRows(algorithmRows().AddRow(5, 1, false, false, false))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("system", model.AuditCreate, model.EntityClient, nullInt64(1), nullInt64(1), sqlmock.AnyArg(), nullString("")).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("INSERT INTO client_revisions").
+		WithArgs(int64(1), []byte(`{"client_name":"TestClient","version":1,"image":"test/image","cpu":"1","memory":"1Gi",`+
+			`"priority":1,"needRestart":false,"vwap":false,"twap":false,"hft":false}`), "system", nullString(""), int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"revision", "created_at"}).AddRow(1, created))
+	mock.ExpectCommit()
 
 	err = store.AddClient(context.Background(), client)
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), client.ID)
+	assert.Equal(t, created, client.CreatedAt)
+	assert.True(t, client.SpawnedAt.IsZero())
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestPGStore_DeleteClient(t *testing.T) {
+func TestPGStore_GetClient(t *testing.T) {
 	db, mock, err := newMock()
 	require.NoError(t, err)
 	defer db.Close()
 
-	logger := &loggers.Logger{}
-	cfg := &config.Config{}
-
 	store := &PGStore{
-		cfg:    cfg,
-		logger: logger,
+		cfg:    &config.Config{},
+		logger: &loggers.Logger{},
 		db:     db,
 	}
+	created := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
 
-	client := &model.Client{
-		ID: 1,
+	mock.ExpectQuery("SELECT (.+) FROM clients WHERE id").
+		WithArgs(1).
+		WillReturnRows(clientRows().AddRow(1, "client", 2, "algo/hft:1.0", "500m", "1Gi", 10, false, nil, created, created, 1, "{desk-a}", false))
+	mock.ExpectQuery("SELECT (.+) FROM clients WHERE id").
+		WithArgs(2).
+		WillReturnRows(clientRows())
+
+	c, err := store.GetClient(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "client", c.ClientName)
+	assert.Equal(t, []string{"desk-a"}, c.Tags)
+	assert.Equal(t, created, c.UpdatedAt)
+
+	_, err = store.GetClient(context.Background(), 2)
+	assert.ErrorIs(t, err, model.ErrorClientNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_SetClientsSpawned(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := &PGStore{
+		cfg:    &config.Config{},
+		logger: &loggers.Logger{},
+		db:     db,
 	}
 
-	mock.ExpectExec("DELETE FROM clients").
-		WithArgs(client.ID).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE clients SET spawned_at").
+		WithArgs(sqlmock.AnyArg(), pq.Int64Array{1, 2}).
+		WillReturnResult(sqlmock.NewResult(0, 2))
 
-	mock.ExpectExec("DELETE FROM algorithm_status").
-		WithArgs(client.ID).
+	assert.NoError(t, store.SetClientsSpawned(context.Background(), []int64{1, 2}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_GetClients(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &PGStore{cfg: &config.Config{}, logger: &loggers.Logger{}, db: db}
+
+	mock.ExpectQuery(`SELECT (.+) FROM clients WHERE deleted_at IS NULL ORDER BY id`).
+		WillReturnRows(lockedClient(1).AddRow(2, "other", 1, "algo/twap:1.0", "", "", 0, true, nil, time.Now(),
+			time.Now(), 4, "{}", false))
+
+	clients, err := store.GetClients(context.Background())
+	require.NoError(t, err)
+	require.Len(t, clients, 2)
+	assert.Equal(t, "algo/hft:1.0", clients[0].Image)
+	assert.True(t, clients[1].NeedRestart)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_GetDeletedAlgorithms(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &PGStore{cfg: &config.Config{}, logger: &loggers.Logger{}, db: db}
+
+	mock.ExpectQuery(`SELECT id FROM algorithm_status WHERE client_id IN \(SELECT id FROM clients WHERE deleted_at IS NOT NULL\) ORDER BY id`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2).AddRow(5))
+
+	ids, err := store.GetDeletedAlgorithms(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2, 5}, ids)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_SetClientsRestarted(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &PGStore{cfg: &config.Config{}, logger: &loggers.Logger{}, db: db}
+	restart := `UPDATE clients SET need_restart=FALSE
+				WHERE id=\$1 AND revision=\$2 AND need_restart AND deleted_at IS NULL`
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(restart).
+		WithArgs(int64(1), int64(6)).
+		WillReturnRows(clientRows().AddRow(1, "client", 1, "algo:1", "500m", "1Gi", 10, false, nil, time.Now(),
+			time.Now(), 6, "{}", false))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("syncer", model.AuditRestart, model.EntityClient, nullInt64(1), nullInt64(1), sqlmock.AnyArg(), nullString("")).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	// клиент 2 изменился после чтения синкером, флаг не снимается
+	mock.ExpectBegin()
+	mock.ExpectQuery(restart).
+		WithArgs(int64(2), int64(3)).
+		WillReturnRows(clientRows())
+	mock.ExpectCommit()
 
-	err = store.DeleteClient(context.Background(), client)
+	ctx := model.WithOrigin(context.Background(), model.Origin{Actor: "syncer"})
+	err = store.SetClientsRestarted(ctx, []model.Client{
+		{ID: 1, Image: "algo:1", NeedRestart: true, Revision: 6},
+		{ID: 2, NeedRestart: true, Revision: 3},
+	})
 	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
 
-	err = mock.ExpectationsWereMet()
-	assert.NoError(t, err)
+func TestPGStore_DeleteClient(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := &PGStore{
+		cfg:    &config.Config{},
+		logger: &loggers.Logger{},
+		db:     db,
+	}
+
+	t.Run("soft delete", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM clients WHERE id=\$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(int64(1)).
+			WillReturnRows(lockedClient(1))
+		mock.ExpectQuery(`UPDATE clients SET deleted_at=now\(\) WHERE id=\$1 RETURNING deleted_at`).
+			WithArgs(int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}).AddRow(time.Now()))
+		mock.ExpectExec("INSERT INTO audit_log").
+			WithArgs("system", model.AuditDelete, model.EntityClient, nullInt64(1), nullInt64(1), sqlmock.AnyArg(), nullString("")).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, store.DeleteClient(context.Background(), 1))
+	})
+
+	t.Run("protected", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM clients WHERE id=\$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(int64(2)).
+			WillReturnRows(clientRows().AddRow(2, "client", 2, "algo/hft:1.0", "500m", "1Gi", 10, false, nil, time.Now(),
+				time.Now(), 1, "{}", true))
+		mock.ExpectRollback()
+
+		err := store.DeleteClient(context.Background(), 2)
+		assert.ErrorIs(t, err, model.ErrorDeletionProtected)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_SetDeletionProtection(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &PGStore{cfg: &config.Config{}, logger: &loggers.Logger{}, db: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM clients WHERE id=\$1 AND deleted_at IS NULL FOR UPDATE`).
+		WithArgs(int64(1)).
+		WillReturnRows(lockedClient(1))
+	mock.ExpectQuery(`UPDATE clients SET deletion_protected=\$1, updated_at=\$2, revision=revision\+1 WHERE id=\$3`).
+		WithArgs(true, sqlmock.AnyArg(), int64(1)).
+		WillReturnRows(clientRows().AddRow(1, "client", 2, "algo/hft:1.0", "500m", "1Gi", 10, false, nil, time.Now(),
+			time.Now(), 2, "{}", true))
+	expectAudit(mock)
+	mock.ExpectQuery(`SELECT (.+) FROM algorithm_status WHERE client_id=\$1 FOR UPDATE`).
+		WithArgs(int64(1)).
+		WillReturnRows(algorithmRows().AddRow(7, 1, false, false, false))
+	expectRevision(mock, 2, 2)
+	mock.ExpectCommit()
+
+	c, err := store.SetDeletionProtection(context.Background(), 1, true)
+	require.NoError(t, err)
+	assert.True(t, c.DeletionProtected)
+	assert.Equal(t, int64(2), c.Revision)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_RestoreClient(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := &PGStore{
+		cfg:    &config.Config{},
+		logger: &loggers.Logger{},
+		db:     db,
+	}
+
+	t.Run("restored", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT deleted_at FROM clients WHERE id=\$1 AND deleted_at IS NOT NULL FOR UPDATE`).
+			WithArgs(int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}).AddRow(time.Now()))
+		mock.ExpectQuery(`UPDATE clients SET deleted_at=NULL WHERE id=\$1 RETURNING`).
+			WithArgs(int64(1)).
+			WillReturnRows(lockedClient(1))
+		mock.ExpectExec("INSERT INTO audit_log").
+			WithArgs("system", model.AuditRestore, model.EntityClient, nullInt64(1), nullInt64(1), sqlmock.AnyArg(), nullString("")).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		c, err := store.RestoreClient(context.Background(), 1)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), c.ID)
+	})
+
+	t.Run("not deleted", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT deleted_at FROM clients WHERE id=\$1 AND deleted_at IS NOT NULL FOR UPDATE`).
+			WithArgs(int64(2)).
+			WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}))
+		mock.ExpectRollback()
+
+		_, err := store.RestoreClient(context.Background(), 2)
+		assert.ErrorIs(t, err, model.ErrorClientNotFound)
+	})
+
+	t.Run("name taken", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT deleted_at FROM clients WHERE id=\$1 AND deleted_at IS NOT NULL FOR UPDATE`).
+			WithArgs(int64(3)).
+			WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}).AddRow(time.Now()))
+		mock.ExpectQuery(`UPDATE clients SET deleted_at=NULL WHERE id=\$1 RETURNING`).
+			WithArgs(int64(3)).
+			WillReturnError(&pq.Error{Code: "23505", Constraint: "clients_name_live"})
+		mock.ExpectRollback()
+
+		_, err := store.RestoreClient(context.Background(), 3)
+		assert.ErrorIs(t, err, model.ErrorClientConflict)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_PurgeDeletedClients(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := &PGStore{
+		cfg:    &config.Config{},
+		logger: &loggers.Logger{},
+		db:     db,
+	}
+	before := time.Date(2026, 9, 19, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM clients WHERE deleted_at < \$1 ORDER BY id FOR UPDATE`).
+		WithArgs(before).
+		WillReturnRows(lockedClient(3).AddRow(4, "client-4", 1, "algo/vwap:1.0", "500m", "1Gi", 1, false, nil,
+			time.Now(), time.Now(), 2, "{}", false))
+	for _, table := range []string{"algorithm_status", "schedules", "scheduled_changes", "clients"} {
+		mock.ExpectExec("DELETE FROM " + table + " WHERE (client_)?id = ANY").
+			WithArgs(pq.Int64Array{3, 4}).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+	}
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("system", model.AuditPurge, model.EntityClient, nullInt64(3), nullInt64(3), sqlmock.AnyArg(), nullString("")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("system", model.AuditPurge, model.EntityClient, nullInt64(4), nullInt64(4), sqlmock.AnyArg(), nullString("")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	n, err := store.PurgeDeletedClients(context.Background(), before)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestPGStore_UpdateAlgorithmStatus(t *testing.T) {
@@ -119,17 +418,84 @@ func TestPGStore_UpdateAlgorithmStatus(t *testing.T) {
 		HFT:      true,
 	}
 
-	mock.ExpectExec("UPDATE algorithm_status").
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM clients WHERE id=\$1 AND deleted_at IS NULL FOR UPDATE`).
+		WithArgs(as.ClientID).
+		WillReturnRows(lockedClient(1))
+	mock.ExpectQuery(`SELECT id, client_id, vwap, twap, hft FROM algorithm_status WHERE client_id=\$1 FOR UPDATE`).
+		WithArgs(as.ClientID).
+		WillReturnRows(algorithmRows().AddRow(7, 1, false, false, false))
+	mock.ExpectQuery("UPDATE algorithm_status").
 		WithArgs(as.VWAP, as.TWAP, as.HFT, as.ClientID).
+		WillReturnRows(algorithmRows().AddRow(7, 1, true, false, true))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("system", model.AuditUpdate, model.EntityAlgorithms, nullInt64(7), nullInt64(1),
+			[]byte(`{"hft":{"before":false,"after":true},"vwap":{"before":false,"after":true}}`), nullString("")).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRevision(mock, 2, 1)
+	mock.ExpectCommit()
 
 	err = store.UpdateAlgorithmStatus(context.Background(), as)
 	assert.NoError(t, err)
+	assert.Equal(t, int64(7), as.AlgorithmID)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM clients").
+		WillReturnRows(clientRows())
+	mock.ExpectRollback()
+	assert.ErrorIs(t, store.UpdateAlgorithmStatus(context.Background(), as), model.ErrorClientNotFound)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
+func TestPGStore_GetAlgorithmStatus(t *testing.T) {
+	tests := []struct {
+		name          string
+		rows          *sqlmock.Rows
+		expectedLen   int
+		expectedError bool
+	}{
+		{
+			name:        "ok",
+			rows:        algorithmRows().AddRow(1, 42, true, false, false).AddRow(2, 43, false, false, true),
+			expectedLen: 2,
+		},
+		{
+			name:          "scan error",
+			rows:          algorithmRows().AddRow(1, "x", true, false, false),
+			expectedError: true,
+		},
+		{
+			name:          "rows error",
+			rows:          algorithmRows().AddRow(1, 42, true, false, false).RowError(0, errors.New("connection reset")),
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := newMock()
+			require.NoError(t, err)
+			defer db.Close()
+			store := &PGStore{cfg: &config.Config{}, logger: &loggers.Logger{}, db: db}
+
+			mock.ExpectQuery("SELECT id, client_id, vwap, twap, hft FROM algorithm_status").
+				WillReturnRows(tt.rows)
+
+			algorithms, err := store.GetAlgorithmStatus(context.Background())
+
+			if tt.expectedError {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Len(t, algorithms, tt.expectedLen)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
 func TestPGStore_UpdateClient(t *testing.T) {
 	db, mock, err := newMock()
 	require.NoError(t, err)
@@ -157,13 +523,1097 @@ func TestPGStore_UpdateClient(t *testing.T) {
 		UpdatedAt:   time.Now(),
 	}
 
-	mock.ExpectExec("UPDATE clients").
-		WithArgs(client.ClientName, client.Version, client.Image, client.CPU, client.Memory, client.Priority, client.NeedRestart, client.SpawnedAt, sqlmock.AnyArg(), client.ID).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	created := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	updated := created.Add(time.Hour)
+
+	locked := func() *sqlmock.Rows {
+		return clientRows().AddRow(client.ID, "client", 1, "algo/hft:1.0", "1", "1Gi", 1, false, nil, created, created, 2, "{}", false)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM clients WHERE id=\$1 AND deleted_at IS NULL FOR UPDATE`).
+		WithArgs(client.ID).
+		WillReturnRows(locked())
+	mock.ExpectQuery("UPDATE clients").
+		WithArgs(client.ClientName, client.Version, client.Image, client.CPU, client.Memory, client.Priority, client.NeedRestart,
+			pq.StringArray{}, sqlmock.AnyArg(), client.ID).
+		WillReturnRows(clientRows().AddRow(client.ID, client.ClientName, client.Version, client.Image, client.CPU,
+			client.Memory, client.Priority, client.NeedRestart, client.SpawnedAt, created, updated, 3, "{}", false))
+	expectAudit(mock)
+	mock.ExpectQuery("SELECT (.+) FROM algorithm_status WHERE client_id=\\$1 FOR UPDATE").
+		WithArgs(client.ID).
+		WillReturnRows(algorithmRows().AddRow(7, 1, true, false, false))
+	expectRevision(mock, 3, 3)
+	mock.ExpectCommit()
 
 	err = store.UpdateClient(context.Background(), client)
 	assert.NoError(t, err)
+	assert.Equal(t, created, client.CreatedAt)
+	assert.Equal(t, updated, client.UpdatedAt)
+	assert.Equal(t, int64(3), client.Revision)
+
+	client.Revision = 1
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM clients").
+		WillReturnRows(locked())
+	mock.ExpectRollback()
+	assert.ErrorIs(t, store.UpdateClient(context.Background(), client), model.ErrorRevisionMismatch)
+
+	client.Revision = 0
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM clients").
+		WillReturnRows(clientRows())
+	mock.ExpectRollback()
+	assert.ErrorIs(t, store.UpdateClient(context.Background(), client), model.ErrorClientNotFound)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM clients").
+		WillReturnRows(locked())
+	mock.ExpectQuery("UPDATE clients").
+		WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectRollback()
+	assert.ErrorIs(t, store.UpdateClient(context.Background(), client), model.ErrorClientConflict)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
+
+func TestPGStore_PatchClient(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := &PGStore{
+		cfg:    &config.Config{},
+		logger: &loggers.Logger{},
+		db:     db,
+	}
+	created := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	memory := "2Gi"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM clients WHERE id=\$1 AND deleted_at IS NULL FOR UPDATE`).
+		WithArgs(int64(1)).
+		WillReturnRows(clientRows().AddRow(1, "client", 2, "algo/hft:1.0", "500m", "1Gi", 10, false, nil, created, created, 3, "{}", false))
+	mock.ExpectQuery(`UPDATE clients SET memory=\$1, updated_at=\$2, revision=revision\+1 WHERE id=\$3`).
+		WithArgs(memory, sqlmock.AnyArg(), int64(1)).
+		WillReturnRows(clientRows().AddRow(1, "client", 2, "algo/hft:1.0", "500m", memory, 10, false, nil, created, created, 4, "{}", false))
+	expectRestart(mock, 1)
+	expectAudit(mock)
+	mock.ExpectQuery("SELECT (.+) FROM algorithm_status").
+		WillReturnRows(algorithmRows().AddRow(7, 1, false, false, false))
+	expectRevision(mock, 4, 4)
+	mock.ExpectCommit()
+
+	c, err := store.PatchClient(context.Background(), 1, 3, &model.ClientPatch{Memory: &memory})
+	assert.NoError(t, err)
+	assert.Equal(t, memory, c.Memory)
+	assert.Equal(t, int64(4), c.Revision)
+	assert.True(t, c.NeedRestart)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_PatchAlgorithmStatus(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := &PGStore{
+		cfg:    &config.Config{},
+		logger: &loggers.Logger{},
+		db:     db,
+	}
+	hft := true
+	columns := []string{"id", "client_id", "vwap", "twap", "hft"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM clients WHERE id=\$1 AND deleted_at IS NULL FOR UPDATE`).
+		WithArgs(int64(1)).
+		WillReturnRows(lockedClient(1))
+	mock.ExpectQuery(`SELECT (.+) FROM algorithm_status WHERE client_id=\$1 FOR UPDATE`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(7, 1, true, false, false))
+	mock.ExpectQuery(`UPDATE algorithm_status SET hft=\$1 WHERE client_id=\$2`).
+		WithArgs(true, int64(1)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(7, 1, true, false, true))
+	expectAudit(mock)
+	expectRevision(mock, 2, 1)
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT id, client_id, vwap, twap, hft FROM algorithm_status WHERE client_id=\$1`).
+		WithArgs(int64(2)).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	as, err := store.PatchAlgorithmStatus(context.Background(), 1, &model.AlgorithmPatch{HFT: &hft})
+	assert.NoError(t, err)
+	assert.True(t, as.VWAP)
+	assert.True(t, as.HFT)
+
+	_, err = store.PatchAlgorithmStatus(context.Background(), 2, &model.AlgorithmPatch{})
+	assert.ErrorIs(t, err, model.ErrorClientNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_ApprovalGate(t *testing.T) {
+	hft := true
+	tests := []struct {
+		name          string
+		current       *sqlmock.Rows
+		expectedError error
+	}{
+		{name: "enables gated algorithm", current: algorithmRows().AddRow(7, 1, false, false, false),
+			expectedError: model.ErrorApprovalRequired},
+		{name: "gated algorithm already on", current: algorithmRows().AddRow(7, 1, false, false, true)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := newMock()
+			require.NoError(t, err)
+			defer db.Close()
+			cfg := &config.Config{}
+			cfg.Approval.Algorithms = []string{"hft"}
+			store := &PGStore{cfg: cfg, logger: &loggers.Logger{}, db: db}
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT (.+) FROM clients WHERE id=\$1 AND deleted_at IS NULL FOR UPDATE`).
+				WithArgs(int64(1)).
+				WillReturnRows(lockedClient(1))
+			mock.ExpectQuery(`SELECT (.+) FROM algorithm_status WHERE client_id=\$1 FOR UPDATE`).
+				WithArgs(int64(1)).
+				WillReturnRows(tt.current)
+			if tt.expectedError != nil {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectQuery(`UPDATE algorithm_status SET hft=\$1 WHERE client_id=\$2`).
+					WithArgs(true, int64(1)).
+					WillReturnRows(algorithmRows().AddRow(7, 1, false, false, true))
+				expectAudit(mock)
+				expectRevision(mock, 2, 1)
+				mock.ExpectCommit()
+			}
+
+			_, err = store.PatchAlgorithmStatus(context.Background(), 1, &model.AlgorithmPatch{HFT: &hft})
+
+			assert.ErrorIs(t, err, tt.expectedError)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestPGStore_ConfirmSyncGuard(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := &PGStore{
+		cfg:    &config.Config{},
+		logger: &loggers.Logger{},
+		db:     db,
+	}
+	columns := []string{"alert", "pods", "managed_pods", "reason", "raised_at", "confirmed", "confirmed_at"}
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM sync_guard WHERE id=1 FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(true, "{pod-1}", 2, "mass delete", now, false, nil))
+	mock.ExpectQuery("UPDATE sync_guard SET confirmed=TRUE").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(true, "{pod-1}", 2, "mass delete", now, true, now))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("system", model.AuditConfirm, model.EntitySyncGuard, nullInt64(0), nullInt64(0), sqlmock.AnyArg(), nullString("")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM sync_guard WHERE id=1 FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectRollback()
+
+	assert.NoError(t, store.ConfirmSyncGuard(context.Background()))
+	assert.ErrorIs(t, store.ConfirmSyncGuard(context.Background()), model.ErrorNoSyncAlert)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_AddHalt(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := &PGStore{
+		cfg:    &config.Config{},
+		logger: &loggers.Logger{},
+		db:     db,
+	}
+
+	halt := &model.Halt{
+		Scope:       model.HaltAlgorithm,
+		Algorithm:   model.AlgorithmHFT,
+		Reason:      "runaway",
+		TriggeredBy: "desk",
+	}
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO halts").
+		WithArgs(halt.Scope, nullInt64(0), nullString("hft"), halt.Reason, halt.TriggeredBy).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(3, now))
+	expectAudit(mock)
+	mock.ExpectCommit()
+
+	assert.NoError(t, store.AddHalt(context.Background(), halt))
+	assert.Equal(t, int64(3), halt.ID)
+	assert.Equal(t, now, halt.CreatedAt)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_ResumeHalt(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := &PGStore{
+		cfg:    &config.Config{},
+		logger: &loggers.Logger{},
+		db:     db,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE halts SET resumed_at").
+		WithArgs(sqlmock.AnyArg(), "desk", int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"client_id"}).AddRow(nil))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("desk", model.AuditResume, model.EntityHalt, nullInt64(2), nullInt64(0), sqlmock.AnyArg(), nullString("req-1")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE halts SET resumed_at").
+		WithArgs(sqlmock.AnyArg(), "desk", int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"client_id"}))
+	mock.ExpectRollback()
+
+	ctx := model.WithOrigin(context.Background(), model.Origin{Actor: "desk", RequestID: "req-1"})
+	assert.NoError(t, store.ResumeHalt(ctx, 2, "desk"))
+	assert.ErrorIs(t, store.ResumeHalt(context.Background(), 3, "desk"), model.ErrorHaltNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_SetClientSuspension(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := &PGStore{
+		cfg:    &config.Config{},
+		logger: &loggers.Logger{},
+		db:     db,
+	}
+
+	until := time.Now().Add(time.Hour)
+	s := &model.Suspension{ClientID: 1, Suspended: true, Until: &until, Reason: "upgrade"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT COALESCE\(suspended, FALSE\), suspended_until, suspend_reason FROM clients\s+WHERE id=\$1 AND deleted_at IS NULL FOR UPDATE`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"suspended", "suspended_until", "suspend_reason"}).AddRow(false, nil, nil))
+	mock.ExpectExec("UPDATE clients SET suspended").
+		WithArgs(true, s.Until, nullString("upgrade"), int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock)
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM clients").
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"suspended", "suspended_until", "suspend_reason"}))
+	mock.ExpectRollback()
+
+	assert.NoError(t, store.SetClientSuspension(context.Background(), s))
+	assert.ErrorIs(t, store.SetClientSuspension(context.Background(), s), model.ErrorClientNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_ApplyDueChanges(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+
+	cfg := &config.Config{}
+	cfg.Scheduler.MaxAttempts, cfg.Scheduler.RetryBackoff = 3, time.Minute
+	store := &PGStore{
+		cfg:    cfg,
+		logger: &loggers.Logger{},
+		db:     db,
+	}
+
+	now := time.Now()
+	columns := []string{"id", "client_id", "algorithm", "enabled", "apply_at", "status", "created_by", "created_at",
+		"applied_at", "error", "attempts", "retry_at"}
+
+	mock.ExpectQuery(`SELECT id FROM scheduled_changes WHERE status='pending' AND apply_at <= now\(\)
+			AND \(retry_at IS NULL OR retry_at <= now\(\)\) ORDER BY apply_at, id`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2).AddRow(3).AddRow(4).AddRow(5).AddRow(6))
+
+	// 1: применено
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM scheduled_changes WHERE id=\\$1 (.+) FOR UPDATE SKIP LOCKED").
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, 42, "hft", true, now, "pending", "desk", now, nil, nil, 0, nil))
+	mock.ExpectQuery("SELECT (.+) FROM clients WHERE id=\\$1 AND deleted_at IS NULL FOR UPDATE").
+		WithArgs(int64(42)).
+		WillReturnRows(lockedClient(42))
+	mock.ExpectQuery("SELECT (.+) FROM algorithm_status WHERE client_id=\\$1 FOR UPDATE").
+		WithArgs(int64(42)).
+		WillReturnRows(algorithmRows().AddRow(5, 42, false, false, false))
+	mock.ExpectQuery(`UPDATE algorithm_status SET hft=\$1 WHERE client_id=\$2`).
+		WithArgs(true, int64(42)).
+		WillReturnRows(algorithmRows().AddRow(5, 42, false, false, true))
+	expectAudit(mock)
+	expectRevision(mock, 3, 1)
+	mock.ExpectQuery("UPDATE scheduled_changes SET status").
+		WithArgs(model.ChangeApplied, nullString(""), int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"applied_at"}).AddRow(now))
+	expectAudit(mock)
+	mock.ExpectCommit()
+
+	// 2: клиента нет - отмечается failed в той же транзакции
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM scheduled_changes WHERE id=\\$1 (.+) FOR UPDATE SKIP LOCKED").
+		WithArgs(int64(2)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(2, 43, "twap", false, now, "pending", nil, now, nil, nil, 0, nil))
+	mock.ExpectQuery("SELECT (.+) FROM clients WHERE id=\\$1 AND deleted_at IS NULL FOR UPDATE").
+		WithArgs(int64(43)).
+		WillReturnRows(clientRows())
+	mock.ExpectQuery("UPDATE scheduled_changes SET status").
+		WithArgs(model.ChangeFailed, nullString("algorithm status not found"), int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"applied_at"}).AddRow(now))
+	expectAudit(mock)
+	mock.ExpectCommit()
+
+	// 3: временный сбой - транзакция откатывается, изменение остаётся pending до повтора
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM scheduled_changes WHERE id=\\$1 (.+) FOR UPDATE SKIP LOCKED").
+		WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(3, 44, "vwap", true, now, "pending", nil, now, nil, nil, 1, nil))
+	mock.ExpectQuery("SELECT (.+) FROM clients WHERE id=\\$1 AND deleted_at IS NULL FOR UPDATE").
+		WithArgs(int64(44)).
+		WillReturnError(errors.New("deadlock detected"))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM scheduled_changes WHERE id=\\$1 (.+) FOR UPDATE SKIP LOCKED").
+		WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(3, 44, "vwap", true, now, "pending", nil, now, nil, nil, 1, nil))
+	mock.ExpectQuery(`UPDATE scheduled_changes SET attempts=attempts\+1, retry_at=\$1, error=\$2 WHERE id=\$3`).
+		WithArgs(sqlmock.AnyArg(), "deadlock detected", int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"attempts", "retry_at"}).AddRow(2, now.Add(2*time.Minute)))
+	mock.ExpectCommit()
+
+	// 4: временный сбой на последней попытке - изменение отмечается failed
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM scheduled_changes WHERE id=\\$1 (.+) FOR UPDATE SKIP LOCKED").
+		WithArgs(int64(4)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(4, 45, "vwap", true, now, "pending", nil, now, nil, nil, 2, now))
+	mock.ExpectQuery("SELECT (.+) FROM clients WHERE id=\\$1 AND deleted_at IS NULL FOR UPDATE").
+		WithArgs(int64(45)).
+		WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM scheduled_changes WHERE id=\\$1 (.+) FOR UPDATE SKIP LOCKED").
+		WithArgs(int64(4)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(4, 45, "vwap", true, now, "pending", nil, now, nil, nil, 2, now))
+	mock.ExpectQuery("UPDATE scheduled_changes SET status").
+		WithArgs(model.ChangeFailed, nullString("connection reset"), int64(4)).
+		WillReturnRows(sqlmock.NewRows([]string{"applied_at"}).AddRow(now))
+	expectAudit(mock)
+	mock.ExpectCommit()
+
+	// 5: постоянная ошибка - изменение отмечается failed с первой попытки
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM scheduled_changes WHERE id=\\$1 (.+) FOR UPDATE SKIP LOCKED").
+		WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(5, 46, "vwap", true, now, "pending", nil, now, nil, nil, 0, nil))
+	mock.ExpectQuery("SELECT (.+) FROM clients WHERE id=\\$1 AND deleted_at IS NULL FOR UPDATE").
+		WithArgs(int64(46)).
+		WillReturnError(&pq.Error{Code: "23514", Message: "check constraint violated"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM scheduled_changes WHERE id=\\$1 (.+) FOR UPDATE SKIP LOCKED").
+		WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(5, 46, "vwap", true, now, "pending", nil, now, nil, nil, 0, nil))
+	mock.ExpectQuery("UPDATE scheduled_changes SET status").
+		WithArgs(model.ChangeFailed, sqlmock.AnyArg(), int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"applied_at"}).AddRow(now))
+	expectAudit(mock)
+	mock.ExpectCommit()
+
+	// 6: изменение уже забрала другая реплика
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM scheduled_changes WHERE id=\\$1 (.+) FOR UPDATE SKIP LOCKED").
+		WithArgs(int64(6)).
+		WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectCommit()
+
+	changes, err := store.ApplyDueChanges(context.Background())
+	require.NoError(t, err)
+	require.Len(t, changes, 5)
+	assert.Equal(t, model.ChangeApplied, changes[0].Status)
+	assert.Equal(t, "desk", changes[0].CreatedBy)
+	assert.Equal(t, model.ChangeFailed, changes[1].Status)
+	assert.Equal(t, model.ChangePending, changes[2].Status)
+	assert.Equal(t, "deadlock detected", changes[2].Error)
+	assert.Equal(t, 2, changes[2].Attempts)
+	assert.NotNil(t, changes[2].RetryAt)
+	assert.Equal(t, model.ChangeFailed, changes[3].Status)
+	assert.Equal(t, model.ChangeFailed, changes[4].Status)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_BulkUpdateAlgorithms(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := &PGStore{
+		cfg:    &config.Config{},
+		logger: &loggers.Logger{},
+		db:     db,
+	}
+	columns := []string{"id", "client_id", "vwap", "twap", "hft"}
+	changes := []model.AlgorithmChange{
+		{ClientID: 1, Algorithm: model.AlgorithmHFT, Enabled: true},
+		{ClientID: 2, Algorithm: model.AlgorithmVWAP},
+		{ClientID: 3, Algorithm: model.AlgorithmTWAP, Enabled: true},
+	}
+
+	// atomic: ошибка второго изменения откатывает первое, третье не выполняется
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM clients WHERE id=\$1 AND deleted_at IS NULL FOR UPDATE`).
+		WithArgs(int64(1)).
+		WillReturnRows(lockedClient(1))
+	mock.ExpectQuery(`SELECT (.+) FROM algorithm_status WHERE client_id=\$1 FOR UPDATE`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(7, 1, false, false, false))
+	mock.ExpectQuery(`UPDATE algorithm_status SET hft=\$1 WHERE client_id=\$2`).
+		WithArgs(true, int64(1)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(7, 1, false, false, true))
+	expectAudit(mock)
+	expectRevision(mock, 2, 1)
+	mock.ExpectQuery(`SELECT (.+) FROM clients WHERE id=\$1 AND deleted_at IS NULL FOR UPDATE`).
+		WithArgs(int64(2)).
+		WillReturnRows(clientRows())
+	mock.ExpectRollback()
+
+	res, err := store.BulkUpdateAlgorithms(context.Background(), model.BulkAtomic, changes)
+	assert.NoError(t, err)
+	assert.False(t, res.Applied)
+	assert.Equal(t, model.BulkRolledBack, res.Results[0].Status)
+	assert.Nil(t, res.Results[0].State)
+	assert.Equal(t, model.BulkFailed, res.Results[1].Status)
+	assert.Equal(t, "client_not_found", res.Results[1].Code)
+	assert.Equal(t, model.BulkSkipped, res.Results[2].Status)
+
+	// best_effort: ошибочное изменение пропускается, остальные фиксируются
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM clients`).
+		WillReturnRows(lockedClient(1))
+	mock.ExpectQuery(`SELECT (.+) FROM algorithm_status`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(7, 1, false, false, false))
+	mock.ExpectQuery(`UPDATE algorithm_status SET hft`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(7, 1, false, false, true))
+	expectAudit(mock)
+	expectRevision(mock, 2, 1)
+	mock.ExpectQuery(`SELECT (.+) FROM clients`).
+		WillReturnRows(clientRows())
+	mock.ExpectQuery(`SELECT (.+) FROM clients`).
+		WillReturnRows(lockedClient(3))
+	mock.ExpectQuery(`SELECT (.+) FROM algorithm_status`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(9, 3, false, false, false))
+	mock.ExpectQuery(`UPDATE algorithm_status SET twap`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(9, 3, false, true, false))
+	expectAudit(mock)
+	expectRevision(mock, 2, 1)
+	mock.ExpectCommit()
+
+	res, err = store.BulkUpdateAlgorithms(context.Background(), model.BulkBestEffort, changes)
+	assert.NoError(t, err)
+	assert.True(t, res.Applied)
+	assert.Equal(t, 1, res.Failed())
+	assert.Equal(t, model.BulkApplied, res.Results[2].Status)
+	assert.True(t, res.Results[2].State.TWAP)
+
+	// клиенты блокируются по возрастанию id независимо от порядка в запросе, изменения одного клиента - по порядку
+	unordered := []model.AlgorithmChange{
+		{ClientID: 3, Algorithm: model.AlgorithmTWAP, Enabled: true},
+		{ClientID: 1, Algorithm: model.AlgorithmHFT, Enabled: true},
+		{ClientID: 3, Algorithm: model.AlgorithmVWAP, Enabled: true},
+	}
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM clients WHERE id=\$1 AND deleted_at IS NULL FOR UPDATE`).
+		WithArgs(int64(1)).
+		WillReturnRows(lockedClient(1))
+	mock.ExpectQuery(`SELECT (.+) FROM algorithm_status`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(7, 1, false, false, false))
+	mock.ExpectQuery(`UPDATE algorithm_status SET hft`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(7, 1, false, false, true))
+	expectAudit(mock)
+	expectRevision(mock, 2, 1)
+	mock.ExpectQuery(`SELECT (.+) FROM clients WHERE id=\$1 AND deleted_at IS NULL FOR UPDATE`).
+		WithArgs(int64(3)).
+		WillReturnRows(lockedClient(3))
+	mock.ExpectQuery(`SELECT (.+) FROM algorithm_status`).
+		WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(9, 3, false, false, false))
+	mock.ExpectQuery(`UPDATE algorithm_status SET twap`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(9, 3, false, true, false))
+	expectAudit(mock)
+	expectRevision(mock, 2, 1)
+	mock.ExpectQuery(`SELECT (.+) FROM clients WHERE id=\$1 AND deleted_at IS NULL FOR UPDATE`).
+		WithArgs(int64(3)).
+		WillReturnRows(lockedClient(3))
+	mock.ExpectQuery(`SELECT (.+) FROM algorithm_status`).
+		WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(9, 3, false, true, false))
+	mock.ExpectQuery(`UPDATE algorithm_status SET vwap`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(9, 3, true, true, false))
+	expectAudit(mock)
+	expectRevision(mock, 2, 1)
+	mock.ExpectCommit()
+
+	res, err = store.BulkUpdateAlgorithms(context.Background(), model.BulkAtomic, unordered)
+	assert.NoError(t, err)
+	assert.True(t, res.Applied)
+	for i, item := range res.Results {
+		assert.Equal(t, i, item.Index)
+		assert.Equal(t, unordered[i], item.AlgorithmChange)
+	}
+	assert.True(t, res.Results[2].State.VWAP)
+	assert.True(t, res.Results[2].State.TWAP)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_ReserveIdempotencyKey(t *testing.T) {
+	expires := time.Date(2026, 10, 2, 12, 0, 0, 0, time.UTC)
+	rec := &model.IdempotencyRecord{Key: "k1", RequestHash: "abc", ExpiresAt: expires}
+
+	t.Run("reserved", func(t *testing.T) {
+		db, mock, err := newMock()
+		require.NoError(t, err)
+		defer db.Close()
+		store := &PGStore{cfg: &config.Config{}, logger: &loggers.Logger{}, db: db}
+
+		mock.ExpectQuery("INSERT INTO idempotency_keys").
+			WithArgs("k1", "abc", expires).
+			WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("k1"))
+
+		saved, err := store.ReserveIdempotencyKey(context.Background(), rec)
+		assert.NoError(t, err)
+		assert.Nil(t, saved)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("saved response", func(t *testing.T) {
+		db, mock, err := newMock()
+		require.NoError(t, err)
+		defer db.Close()
+		store := &PGStore{cfg: &config.Config{}, logger: &loggers.Logger{}, db: db}
+
+		mock.ExpectQuery("INSERT INTO idempotency_keys").
+			WithArgs("k1", "abc", expires).
+			WillReturnRows(sqlmock.NewRows([]string{"key"}))
+		mock.ExpectQuery("SELECT key, request_hash, status, headers, body, expires_at FROM idempotency_keys").
+			WithArgs("k1").
+			WillReturnRows(sqlmock.NewRows([]string{"key", "request_hash", "status", "headers", "body", "expires_at"}).
+				AddRow("k1", "abc", 201, []byte(`{"Location":"/api/client/7"}`), []byte(`{"id":7}`), expires))
+
+		saved, err := store.ReserveIdempotencyKey(context.Background(), rec)
+		assert.NoError(t, err)
+		assert.Equal(t, &model.IdempotencyRecord{
+			Key:         "k1",
+			RequestHash: "abc",
+			Status:      201,
+			Headers:     map[string]string{"Location": "/api/client/7"},
+			Body:        []byte(`{"id":7}`),
+			ExpiresAt:   expires,
+		}, saved)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPGStore_PurgeIdempotencyKeys(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &PGStore{cfg: &config.Config{}, logger: &loggers.Logger{}, db: db}
+
+	mock.ExpectExec("DELETE FROM idempotency_keys WHERE expires_at").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	n, err := store.PurgeIdempotencyKeys(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_AuthenticateAPIKey(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &PGStore{cfg: &config.Config{}, logger: &loggers.Logger{}, db: db}
+	created := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	used := time.Date(2026, 10, 2, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("UPDATE api_keys SET last_used_at=now\\(\\) WHERE key_hash=\\$1 AND revoked_at IS NULL").
+		WithArgs("hash1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "prefix", "role", "client_ids", "client_tags", "created_at",
+			"last_used_at", "revoked_at"}).
+			AddRow(1, "ops-bot", "ssk_abcdefgh", "operator", "{}", "{desk-a}", created, used, nil))
+	mock.ExpectQuery("UPDATE api_keys SET last_used_at").
+		WithArgs("hash2").
+		WillReturnError(sql.ErrNoRows)
+
+	k, err := store.AuthenticateAPIKey(context.Background(), "hash1")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.APIKey{ID: 1, Name: "ops-bot", Prefix: "ssk_abcdefgh", Role: model.RoleOperator,
+		Scope: model.Scope{Tags: []string{"desk-a"}}, CreatedAt: created, LastUsedAt: &used}, k)
+
+	_, err = store.AuthenticateAPIKey(context.Background(), "hash2")
+	assert.ErrorIs(t, err, model.ErrorAPIKeyNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_RevokeAPIKey(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &PGStore{cfg: &config.Config{}, logger: &loggers.Logger{}, db: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE api_keys SET revoked_at").WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"revoked_at"}).AddRow(time.Now()))
+	expectAudit(mock)
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE api_keys SET revoked_at").WithArgs(int64(2)).WillReturnRows(sqlmock.NewRows([]string{"revoked_at"}))
+	mock.ExpectRollback()
+
+	assert.NoError(t, store.RevokeAPIKey(context.Background(), 1))
+	assert.ErrorIs(t, store.RevokeAPIKey(context.Background(), 2), model.ErrorAPIKeyNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_DecideApproval(t *testing.T) {
+	columns := []string{"id", "client_id", "vwap", "twap", "hft", "status", "requested_by", "created_at", "expires_at",
+		"decided_by", "decided_at", "comment"}
+	now := time.Now()
+	row := func(status string) *sqlmock.Rows {
+		return sqlmock.NewRows(columns).AddRow(7, 42, nil, nil, true, status, "apikey:3:alice", now, now.Add(time.Hour), nil, nil,
+			nil)
+	}
+	newStore := func(t *testing.T) (*PGStore, sqlmock.Sqlmock) {
+		db, mock, err := newMock()
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		cfg := &config.Config{}
+		cfg.Approval.Algorithms = []string{"hft"}
+		return &PGStore{cfg: cfg, logger: &loggers.Logger{}, db: db}, mock
+	}
+
+	t.Run("approve applies change", func(t *testing.T) {
+		store, mock := newStore(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT .+ FROM approvals WHERE id=\$1 FOR UPDATE`).WithArgs(int64(7)).WillReturnRows(row("pending"))
+		mock.ExpectQuery(`SELECT (.+) FROM clients WHERE id=\$1 AND deleted_at IS NULL FOR UPDATE`).WithArgs(int64(42)).
+			WillReturnRows(lockedClient(42))
+		mock.ExpectQuery(`SELECT (.+) FROM algorithm_status WHERE client_id=\$1 FOR UPDATE`).WithArgs(int64(42)).
+			WillReturnRows(algorithmRows().AddRow(3, 42, false, false, false))
+		mock.ExpectQuery(`UPDATE algorithm_status SET hft=\$1 WHERE client_id=\$2`).WithArgs(true, int64(42)).
+			WillReturnRows(algorithmRows().AddRow(3, 42, false, false, true))
+		mock.ExpectExec("INSERT INTO audit_log").
+			WithArgs("system", model.AuditApprove, model.EntityAlgorithms, nullInt64(3), nullInt64(42), sqlmock.AnyArg(), nullString("")).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectRevision(mock, 5, 1)
+		mock.ExpectQuery(`UPDATE approvals SET status=\$1`).
+			WithArgs(model.ApprovalApproved, "jwt:bob", sql.NullString{}, int64(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"decided_at"}).AddRow(now))
+		mock.ExpectExec("INSERT INTO audit_log").
+			WithArgs("system", model.AuditApprove, model.EntityApproval, nullInt64(7), nullInt64(42), sqlmock.AnyArg(), nullString("")).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		a, err := store.DecideApproval(context.Background(), 7, model.ApprovalApproved, "jwt:bob", "")
+		assert.NoError(t, err)
+		assert.Equal(t, model.ApprovalApproved, a.Status)
+		assert.Equal(t, "jwt:bob", a.DecidedBy)
+		assert.NotNil(t, a.DecidedAt)
+		assert.Nil(t, a.Changes.VWAP)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reject does not apply change", func(t *testing.T) {
+		store, mock := newStore(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT .+ FROM approvals`).WillReturnRows(row("pending"))
+		mock.ExpectQuery(`UPDATE approvals SET status=\$1`).
+			WithArgs(model.ApprovalRejected, "apikey:3:alice", sql.NullString{String: "withdrawn", Valid: true}, int64(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"decided_at"}).AddRow(now))
+		expectAudit(mock)
+		mock.ExpectCommit()
+
+		_, err := store.DecideApproval(context.Background(), 7, model.ApprovalRejected, "apikey:3:alice", "withdrawn")
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	for name, decidedBy := range map[string]string{"self approval": "apikey:3:alice", "self approval with second key": "apikey:4:alice"} {
+		t.Run(name, func(t *testing.T) {
+			store, mock := newStore(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT .+ FROM approvals`).WillReturnRows(row("pending"))
+			mock.ExpectRollback()
+
+			_, err := store.DecideApproval(context.Background(), 7, model.ApprovalApproved, decidedBy, "")
+			assert.ErrorIs(t, err, model.ErrorSelfApproval)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	t.Run("expired", func(t *testing.T) {
+		store, mock := newStore(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT .+ FROM approvals`).WillReturnRows(row("expired"))
+		mock.ExpectRollback()
+
+		_, err := store.DecideApproval(context.Background(), 7, model.ApprovalApproved, "bob", "")
+		assert.ErrorIs(t, err, model.ErrorApprovalNotPending)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		store, mock := newStore(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT .+ FROM approvals`).WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectRollback()
+
+		_, err := store.DecideApproval(context.Background(), 7, model.ApprovalApproved, "bob", "")
+		assert.ErrorIs(t, err, model.ErrorApprovalNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPGStore_Scope(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &PGStore{cfg: &config.Config{}, logger: &loggers.Logger{}, db: db}
+	ctx := model.WithScope(context.Background(), model.Scope{ClientIDs: []int64{1}, Tags: []string{"desk-a"}})
+
+	t.Run("get out of scope", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT (.+) FROM clients WHERE id=\$1 AND deleted_at IS NULL AND id IN \(SELECT id FROM clients WHERE id = ANY\(\$2\) OR tags && \$3\)`).
+			WithArgs(int64(5), pq.Int64Array{1}, pq.StringArray{"desk-a"}).
+			WillReturnRows(clientRows())
+
+		_, err := store.GetClient(ctx, 5)
+		assert.ErrorIs(t, err, model.ErrorClientNotFound)
+	})
+
+	t.Run("update algorithms out of scope", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM clients WHERE id=\$1 AND deleted_at IS NULL AND id IN (.+) FOR UPDATE`).
+			WithArgs(int64(5), pq.Int64Array{1}, pq.StringArray{"desk-a"}).
+			WillReturnRows(clientRows())
+		mock.ExpectRollback()
+
+		err := store.UpdateAlgorithmStatus(ctx, &model.AlgorithmStatus{ClientID: 5, VWAP: true})
+		assert.ErrorIs(t, err, model.ErrorClientNotFound)
+	})
+
+	t.Run("delete out of scope", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM clients WHERE id=\$1 AND deleted_at IS NULL AND id IN (.+) FOR UPDATE`).
+			WithArgs(int64(5), pq.Int64Array{1}, pq.StringArray{"desk-a"}).
+			WillReturnRows(clientRows())
+		mock.ExpectRollback()
+
+		err := store.DeleteClient(ctx, 5)
+		assert.ErrorIs(t, err, model.ErrorClientNotFound)
+	})
+
+	t.Run("add client outside tags", func(t *testing.T) {
+		err := store.AddClient(ctx, &model.Client{ClientName: "other", Tags: []string{"desk-b"}})
+		assert.ErrorIs(t, err, model.ErrorOutOfScope)
+	})
+
+	t.Run("move client out of scope", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM clients WHERE id=\$1 AND deleted_at IS NULL AND id IN`).
+			WithArgs(int64(7), pq.Int64Array{1}, pq.StringArray{"desk-a"}).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+		err := store.UpdateClient(ctx, &model.Client{ID: 7, ClientName: "client", Tags: []string{"desk-b"}})
+		assert.ErrorIs(t, err, model.ErrorOutOfScope)
+	})
+
+	t.Run("global halt", func(t *testing.T) {
+		err := store.AddHalt(ctx, &model.Halt{Scope: model.HaltGlobal})
+		assert.ErrorIs(t, err, model.ErrorOutOfScope)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_GetAuditLog(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &PGStore{cfg: &config.Config{}, logger: &loggers.Logger{}, db: db}
+	columns := []string{"id", "actor", "action", "entity", "entity_id", "client_id", "diff", "request_id", "created_at"}
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT (.+) FROM audit_log WHERE client_id=\$1 AND actor=\$2 AND created_at >= \$3 AND id < \$4 `+
+		`ORDER BY id DESC LIMIT \$5`).
+		WithArgs(int64(42), "alice", from, int64(90), 20).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(89, "alice", "patch", "client", 42, 42, []byte(`{"memory":{"before":"1Gi","after":"2Gi"}}`), "req-1", from).
+			AddRow(88, "alice", "confirm", "sync_guard", nil, nil, []byte(`{}`), nil, from))
+
+	entries, err := store.GetAuditLog(context.Background(), model.AuditFilter{ClientID: 42, Actor: "alice", From: from,
+		BeforeID: 90, Limit: 20})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, model.AuditChange{Before: "1Gi", After: "2Gi"}, entries[0].Diff["memory"])
+	assert.Equal(t, "req-1", entries[0].RequestID)
+	assert.Equal(t, int64(0), entries[1].ClientID)
+
+	ctx := model.WithScope(context.Background(), model.Scope{ClientIDs: []int64{1}})
+	mock.ExpectQuery(`SELECT (.+) FROM audit_log WHERE client_id IN \(SELECT id FROM clients (.+)\) ORDER BY id DESC LIMIT \$3`).
+		WithArgs(pq.Int64Array{1}, pq.StringArray{}, 100).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	entries, err = store.GetAuditLog(ctx, model.AuditFilter{Limit: 100})
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_GetClientRevisions(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &PGStore{cfg: &config.Config{}, logger: &loggers.Logger{}, db: db}
+	columns := []string{"client_id", "revision", "client_revision", "snapshot", "created_by", "request_id", "created_at"}
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT (.+) FROM client_revisions WHERE client_id=\$1 ORDER BY revision`).
+		WithArgs(int64(42)).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(42, 1, nil, []byte(`{"client_name":"c","version":1,"image":"algo:1","vwap":true}`), "alice", "req-1", now).
+			AddRow(42, 2, 5, []byte(`{"client_name":"c","version":2,"image":"algo:2","vwap":true}`), "bob", nil, now))
+	mock.ExpectQuery(`SELECT (.+) FROM client_revisions`).
+		WithArgs(int64(43)).
+		WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectQuery(`SELECT (.+) FROM clients WHERE id=\$1`).
+		WithArgs(int64(43)).
+		WillReturnRows(clientRows())
+
+	revs, err := store.GetClientRevisions(context.Background(), 42)
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+	assert.Equal(t, int64(2), revs[0].Revision)
+	assert.Equal(t, int64(5), revs[0].ClientRevision)
+	assert.Zero(t, revs[1].ClientRevision)
+	assert.Equal(t, map[string]model.AuditChange{
+		"version": {Before: float64(1), After: float64(2)},
+		"image":   {Before: "algo:1", After: "algo:2"},
+	}, revs[0].Diff)
+	assert.Equal(t, "algo:1", revs[1].Diff["image"].After)
+	assert.Equal(t, "req-1", revs[1].RequestID)
+
+	_, err = store.GetClientRevisions(context.Background(), 43)
+	assert.ErrorIs(t, err, model.ErrorClientNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_RollbackClient(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &PGStore{cfg: &config.Config{}, logger: &loggers.Logger{}, db: db}
+	columns := []string{"client_id", "revision", "client_revision", "snapshot", "created_by", "request_id", "created_at"}
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM clients WHERE id=\$1 AND deleted_at IS NULL FOR UPDATE`).
+		WithArgs(int64(42)).
+		WillReturnRows(clientRows().AddRow(42, "c", 2, "algo:2", "500m", "1Gi", 10, false, nil, now, now, 5, "{}", false))
+	mock.ExpectQuery(`SELECT (.+) FROM client_revisions WHERE client_id=\$1 AND revision=\$2`).
+		WithArgs(int64(42), int64(1)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(42, 1, 1,
+			[]byte(`{"client_name":"c","version":1,"image":"algo:1","cpu":"500m","memory":"1Gi","priority":10,"vwap":true}`),
+			"alice", nil, now))
+	mock.ExpectQuery("UPDATE clients SET client_name").
+		WithArgs("c", 1, "algo:1", "500m", "1Gi", float64(10), false, pq.StringArray{}, sqlmock.AnyArg(), int64(42)).
+		WillReturnRows(clientRows().AddRow(42, "c", 1, "algo:1", "500m", "1Gi", 10, false, nil, now, now, 6, "{}", false))
+	expectRestart(mock, 42)
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("system", model.AuditRollback, model.EntityClient, nullInt64(42), nullInt64(42), sqlmock.AnyArg(), nullString("")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`SELECT (.+) FROM algorithm_status WHERE client_id=\$1 FOR UPDATE`).
+		WithArgs(int64(42)).
+		WillReturnRows(algorithmRows().AddRow(7, 42, false, false, false))
+	mock.ExpectQuery(`SELECT (.+) FROM algorithm_status WHERE client_id=\$1 FOR UPDATE`).
+		WithArgs(int64(42)).
+		WillReturnRows(algorithmRows().AddRow(7, 42, false, false, false))
+	mock.ExpectQuery(`UPDATE algorithm_status SET vwap=\$1, twap=\$2, hft=\$3 WHERE client_id=\$4`).
+		WithArgs(true, false, false, int64(42)).
+		WillReturnRows(algorithmRows().AddRow(7, 42, true, false, false))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("system", model.AuditRollback, model.EntityAlgorithms, nullInt64(7), nullInt64(42), sqlmock.AnyArg(), nullString("")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRevision(mock, 3, 6)
+	mock.ExpectCommit()
+
+	rev, err := store.RollbackClient(context.Background(), 42, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), rev.Revision)
+	assert.Equal(t, int64(6), rev.ClientRevision)
+	assert.Equal(t, "algo:1", rev.Snapshot.Image)
+	assert.True(t, rev.Snapshot.NeedRestart)
+	assert.Equal(t, map[string]model.AuditChange{
+		"version":     {Before: float64(2), After: float64(1)},
+		"image":       {Before: "algo:2", After: "algo:1"},
+		"needRestart": {Before: false, After: true},
+		"vwap":        {Before: false, After: true},
+	}, rev.Diff)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM clients WHERE id=\$1 AND deleted_at IS NULL FOR UPDATE`).
+		WithArgs(int64(42)).
+		WillReturnRows(clientRows().AddRow(42, "c", 1, "algo:1", "500m", "1Gi", 10, false, nil, now, now, 6, "{}", false))
+	mock.ExpectQuery(`SELECT (.+) FROM client_revisions`).
+		WithArgs(int64(42), int64(9)).
+		WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectRollback()
+
+	_, err = store.RollbackClient(context.Background(), 42, 9)
+	assert.ErrorIs(t, err, model.ErrorClientRevisionNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_SaveSyncStatus(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &PGStore{cfg: &config.Config{}, logger: &loggers.Logger{}, db: db}
+	finished := time.Now()
+
+	mock.ExpectQuery("INSERT INTO sync_status .* RETURNING last_success_at").
+		WillReturnRows(sqlmock.NewRows([]string{"last_success_at"}).AddRow(finished))
+
+	st := &model.SyncStatus{Result: model.SyncOK, FinishedAt: finished}
+	assert.NoError(t, store.SaveSyncStatus(context.Background(), st))
+	require.NotNil(t, st.LastSuccessAt)
+	assert.True(t, finished.Equal(*st.LastSuccessAt))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPGStore_CheckSchema(t *testing.T) {
+	tests := []struct {
+		name          string
+		columns       []string
+		expectedError string
+	}{
+		{name: "complete", columns: schemaColumns},
+		{name: "missing table", columns: schemaColumns[9:], expectedError: "missing columns: clients.id, clients.client_name, " +
+			"clients.revision, clients.suspended, clients.suspended_until, clients.suspend_reason, clients.tags, " +
+			"clients.deletion_protected, clients.deleted_at"},
+		{name: "missing migration", columns: schemaColumns[:len(schemaColumns)-1], expectedError: "missing columns: audit_log.id"},
+		{name: "missing column", columns: append(append([]string{}, schemaColumns[:8]...), schemaColumns[9:]...),
+			expectedError: "missing columns: clients.deleted_at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := newMock()
+			require.NoError(t, err)
+			defer db.Close()
+			store := &PGStore{cfg: &config.Config{}, logger: &loggers.Logger{}, db: db}
+			rows := sqlmock.NewRows([]string{"column"})
+			for _, name := range tt.columns {
+				rows.AddRow(name)
+			}
+			mock.ExpectQuery("SELECT (.+) FROM information_schema.columns").
+				WithArgs(pq.StringArray(schemaColumns)).
+				WillReturnRows(rows)
+
+			err = store.CheckSchema(context.Background())
+			if tt.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expectedError)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestPGStore_TryLockSync(t *testing.T) {
+	t.Run("follower", func(t *testing.T) {
+		db, mock, err := newMock()
+		require.NoError(t, err)
+		defer db.Close()
+		store := &PGStore{cfg: &config.Config{}, logger: &loggers.Logger{}, db: db}
+
+		mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).WithArgs(syncLockKey).
+			WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(false))
+
+		leader, err := store.TryLockSync(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, leader)
+		assert.Nil(t, store.leader)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("leader keeps the lock", func(t *testing.T) {
+		db, mock, err := newMock()
+		require.NoError(t, err)
+		defer db.Close()
+		store := &PGStore{cfg: &config.Config{}, logger: &loggers.Logger{}, db: db}
+
+		mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).WithArgs(syncLockKey).
+			WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+		mock.ExpectExec("SELECT 1").WillReturnResult(sqlmock.NewResult(0, 0))
+
+		for i := 0; i < 2; i++ {
+			leader, err := store.TryLockSync(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, leader)
+		}
+		assert.NotNil(t, store.leader)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPGStore_NotifySync(t *testing.T) {
+	db, mock, err := newMock()
+	require.NoError(t, err)
+	defer db.Close()
+	store := &PGStore{cfg: &config.Config{}, logger: &loggers.Logger{}, db: db}
+
+	mock.ExpectExec(`SELECT pg_notify\(\$1, \$2\)`).WithArgs(syncChannel, "halt").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SELECT pg_notify\(\$1, \$2\)`).WithArgs(syncChannel, "trigger").
+		WillReturnError(errors.New("connection refused"))
+
+	assert.NoError(t, store.NotifySync(context.Background(), model.SyncEventHalt))
+	assert.Error(t, store.NotifySync(context.Background(), model.SyncEventTrigger))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
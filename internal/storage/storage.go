@@ -3,13 +3,65 @@ package storage
 import (
 	"context"
 	"github.com/CyrilSbrodov/syncService/internal/model"
+	"time"
 )
 
 // Storage - интерфейс БД
 type Storage interface {
 	AddClient(ctx context.Context, client *model.Client) error
+	GetClient(ctx context.Context, id int64) (*model.Client, error)
 	UpdateClient(ctx context.Context, client *model.Client) error
-	DeleteClient(ctx context.Context, client *model.Client) error
+	PatchClient(ctx context.Context, id, revision int64, patch *model.ClientPatch) (*model.Client, error)
+	GetClients(ctx context.Context) ([]model.Client, error)
+	SetClientsSpawned(ctx context.Context, ids []int64) error
+	SetClientsRestarted(ctx context.Context, clients []model.Client) error
+	DeleteClient(ctx context.Context, id int64) error
+	RestoreClient(ctx context.Context, id int64) (*model.Client, error)
+	SetDeletionProtection(ctx context.Context, id int64, protected bool) (*model.Client, error)
+	PurgeDeletedClients(ctx context.Context, before time.Time) (int64, error)
 	UpdateAlgorithmStatus(ctx context.Context, as *model.AlgorithmStatus) error
+	PatchAlgorithmStatus(ctx context.Context, clientID int64, patch *model.AlgorithmPatch) (*model.AlgorithmStatus, error)
 	GetAlgorithmStatus(ctx context.Context) ([]model.AlgorithmStatus, error)
+	GetDeletedAlgorithms(ctx context.Context) ([]int64, error)
+	GetClientAlgorithms(ctx context.Context, clientID int64) (*model.AlgorithmStatus, error)
+	BulkUpdateAlgorithms(ctx context.Context, mode model.BulkMode, changes []model.AlgorithmChange) (*model.BulkResult, error)
+	GetSyncGuard(ctx context.Context) (*model.SyncGuard, error)
+	RaiseSyncGuard(ctx context.Context, guard *model.SyncGuard) error
+	ConfirmSyncGuard(ctx context.Context) error
+	ResetSyncGuard(ctx context.Context) error
+	AddHalt(ctx context.Context, halt *model.Halt) error
+	GetHalts(ctx context.Context, activeOnly bool) ([]model.Halt, error)
+	ResumeHalt(ctx context.Context, id int64, resumedBy string) error
+	SetClientSuspension(ctx context.Context, s *model.Suspension) error
+	GetSuspendedClients(ctx context.Context) ([]model.Suspension, error)
+	GetFreeze(ctx context.Context) (*model.Freeze, error)
+	SetFreeze(ctx context.Context, f *model.Freeze) error
+	GetSyncStatus(ctx context.Context) (*model.SyncStatus, error)
+	SaveSyncStatus(ctx context.Context, st *model.SyncStatus) error
+	TryLockSync(ctx context.Context) (bool, error)
+	NotifySync(ctx context.Context, event model.SyncEvent) error
+	ListenSync(ctx context.Context) (<-chan model.SyncEvent, error)
+	GetSchedules(ctx context.Context, clientID int64) ([]model.Schedule, error)
+	SetSchedule(ctx context.Context, s *model.Schedule) error
+	DeleteSchedule(ctx context.Context, clientID int64, algorithm model.AlgorithmType) error
+	AddScheduledChange(ctx context.Context, c *model.ScheduledChange) error
+	GetScheduledChanges(ctx context.Context, clientID int64, status model.ChangeStatus) ([]model.ScheduledChange, error)
+	CancelScheduledChange(ctx context.Context, id int64) error
+	ApplyDueChanges(ctx context.Context) ([]model.ScheduledChange, error)
+	ReserveIdempotencyKey(ctx context.Context, rec *model.IdempotencyRecord) (*model.IdempotencyRecord, error)
+	SaveIdempotencyResponse(ctx context.Context, rec *model.IdempotencyRecord) error
+	DeleteIdempotencyKey(ctx context.Context, key string) error
+	PurgeIdempotencyKeys(ctx context.Context) (int64, error)
+	AddAPIKey(ctx context.Context, key *model.APIKey, hash string) error
+	GetAPIKeys(ctx context.Context) ([]model.APIKey, error)
+	AuthenticateAPIKey(ctx context.Context, hash string) (*model.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) error
+	AddApproval(ctx context.Context, a *model.ApprovalRequest) error
+	GetApproval(ctx context.Context, id int64) (*model.ApprovalRequest, error)
+	GetApprovals(ctx context.Context, clientID int64, status model.ApprovalStatus) ([]model.ApprovalRequest, error)
+	DecideApproval(ctx context.Context, id int64, status model.ApprovalStatus, decidedBy, comment string) (*model.ApprovalRequest, error)
+	GetAuditLog(ctx context.Context, f model.AuditFilter) ([]model.AuditEntry, error)
+	GetClientRevisions(ctx context.Context, clientID int64) ([]model.ClientRevision, error)
+	GetClientRevision(ctx context.Context, clientID, revision int64) (*model.ClientRevision, error)
+	RollbackClient(ctx context.Context, clientID, revision int64) (*model.ClientRevision, error)
 }
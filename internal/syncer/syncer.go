@@ -2,22 +2,64 @@ package syncer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/CyrilSbrodov/syncService/cmd/loggers"
 	"github.com/CyrilSbrodov/syncService/internal/config"
 	"github.com/CyrilSbrodov/syncService/internal/deployer"
+	"github.com/CyrilSbrodov/syncService/internal/metrics"
+	"github.com/CyrilSbrodov/syncService/internal/model"
 	"github.com/CyrilSbrodov/syncService/internal/storage"
+	"github.com/CyrilSbrodov/syncService/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// podPrefixes - префиксы имён pod'ов по типам алгоритмов.
+// Префикс vwap исторически "vmap", менять его нельзя - уже запущенные pod'ы станут чужими.
+var podPrefixes = map[model.AlgorithmType]string{
+	model.AlgorithmVWAP: "vmap",
+	model.AlgorithmTWAP: "twap",
+	model.AlgorithmHFT:  "hft",
+}
+
 // Syncer - структура синкера, что своими методами проверяет запущенные pods
 type Syncer struct {
 	store    storage.Storage
 	deployer deployer.Deployer
 	logger   *loggers.Logger
 	cfg      config.Config
+	trigger  chan struct{}
+	// wakeAt - ближайшее открытие или закрытие торговой сессии, к нему проход запускается вне таймера
+	wakeAt time.Time
+	// mu - проходы синхронизации не выполняются одновременно. Kill его не берёт и не ждёт конца прохода:
+	// он отменяет идущий проход через cancelPass, а проход перед созданием pod'a перечитывает остановки
+	mu sync.Mutex
+	// cancelPass - отмена идущего на реплике прохода, nil - прохода нет
+	cancelPass context.CancelFunc
+	passMu     sync.Mutex
+	// links - span'ы запросов, запросивших внеплановый проход, связываются со span'ом прохода
+	links   []trace.Link
+	linksMu sync.Mutex
+	// leader - реплика держит блокировку ведущей и выполняет проходы синхронизации
+	leader atomic.Bool
+	// forward - Trigger вызван на реплике, а проход ещё не выполнен. Неведущая реплика пересылает его ведущей
+	forward atomic.Bool
 }
 
+// maxLinks - сколько запросивших проход span'ов связывается с одним проходом
+const maxLinks = 32
+
+// errPassCancelled - проход отменён kill switch, следующий проход запускается сразу
+var errPassCancelled = errors.New("sync pass cancelled by kill switch")
+
 // NewSyncer - конструктор синкера
 func NewSyncer(d deployer.Deployer, store storage.Storage, logger *loggers.Logger, cfg config.Config) *Syncer {
 	return &Syncer{
@@ -25,73 +67,714 @@ func NewSyncer(d deployer.Deployer, store storage.Storage, logger *loggers.Logge
 		deployer: d,
 		logger:   logger,
 		cfg:      cfg,
+		trigger:  make(chan struct{}, 1),
 	}
 }
 
-// Start - функция запуска синкера с таймером на 5 минут
+// Start - функция запуска синкера с таймером на 5 минут и на границы торговых сессий.
+// Кроме того, проход запускают события синхронизации от других реплик.
 func (s *Syncer) Start() {
+	events, err := s.store.ListenSync(context.Background())
+	if err != nil {
+		s.logger.Error("Error listening sync events, triggers from other replicas are not received", loggers.Err(err))
+	} else {
+		go s.listen(events)
+	}
+
 	ticker := time.NewTicker(s.cfg.SyncTimeout)
+	wake := time.NewTimer(0)
+	<-wake.C
 	for {
+		if d := time.Until(s.wakeAt); !s.wakeAt.IsZero() && d > 0 {
+			wake.Reset(d)
+		}
 		select {
 		case <-ticker.C:
-			s.syncAlgorithms()
+		case <-s.trigger:
+		case <-wake.C:
+		}
+		if !wake.Stop() {
+			select {
+			case <-wake.C:
+			default:
+			}
+		}
+		s.syncAlgorithms()
+	}
+}
+
+// Trigger - внеплановый запуск синхронизации, не дожидаясь таймера.
+// Повторные вызовы до начала прохода схлопываются в один, span каждого вызова из ctx связывается с проходом.
+// На неведущей реплике проход пересылается ведущей через NotifySync.
+func (s *Syncer) Trigger(ctx context.Context) {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		s.linksMu.Lock()
+		if len(s.links) < maxLinks {
+			s.links = append(s.links, trace.Link{SpanContext: sc})
+		}
+		s.linksMu.Unlock()
+	}
+	s.forward.Store(true)
+	s.wake()
+}
+
+// wake - запуск прохода на реплике без пересылки ведущей
+func (s *Syncer) wake() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// listen - обработка событий синхронизации от реплик. Событие будит проход на каждой реплике, но выполнит его
+// только держащая блокировку ведущей: так событие не теряется, пока флаг Leader ещё не обновился.
+// Сами события дальше не пересылаются.
+func (s *Syncer) listen(events <-chan model.SyncEvent) {
+	for event := range events {
+		switch event {
+		case model.SyncEventHalt:
+			s.interruptPass()
+		case model.SyncEventTrigger:
+		default:
+			s.logger.Warn("unknown sync event", slog.String("event", string(event)))
+			continue
+		}
+		s.wake()
+	}
+}
+
+// notify - пересылка события синхронизации ведущей реплике
+func (s *Syncer) notify(ctx context.Context, event model.SyncEvent) {
+	if err := s.store.NotifySync(ctx, event); err != nil {
+		s.logger.Error("Error forwarding sync event to leader", slog.String("event", string(event)), loggers.Err(err))
+	}
+}
+
+// Kill - немедленное удаление pod'ов, попадающих под остановку, без проверки порогов удаления.
+// Остановка уже должна быть сохранена в БД, чтобы следующие проходы не подняли pod'ы обратно.
+// Kill не ждёт идущего прохода, а отменяет его: проход, начатый до остановки, не создаёт pod'ы по старому состоянию.
+// Неведущая реплика отменяет проход ведущей через NotifySync.
+func (s *Syncer) Kill(ctx context.Context, halt *model.Halt) ([]string, []string, error) {
+	s.interruptPass()
+	if !s.Leader() {
+		s.notify(ctx, model.SyncEventHalt)
+	}
+	s.logger.Warn("kill switch activated", slog.Int64("halt", halt.ID), slog.String("scope", string(halt.Scope)),
+		loggers.ClientID(halt.ClientID), loggers.Algorithm(string(halt.Algorithm)),
+		slog.String("triggered_by", halt.TriggeredBy), slog.String("reason", halt.Reason))
+
+	algorithms, err := s.store.GetAlgorithmStatus(ctx)
+	if err != nil {
+		s.logger.Error("Error fetching clients", loggers.Err(err))
+		return nil, nil, err
+	}
+	pods, err := s.deployer.GetPodList(ctx)
+	if err != nil {
+		s.logger.Error("Error fetching pods", loggers.Err(err))
+		return nil, nil, err
+	}
+
+	clients := clientsByAlgorithm(algorithms)
+	var deleted, failed []string
+	for _, name := range pods {
+		if !halted([]model.Halt{*halt}, name, clients) {
+			continue
+		}
+		if err := s.apply(ctx, actionKill, name, s.deployer.DeletePod); err != nil {
+			s.logger.Error("Error delete pod", slog.String("pod", name), loggers.Err(err))
+			failed = append(failed, name)
+			continue
+		}
+		deleted = append(deleted, name)
+	}
+	return deleted, failed, nil
+}
+
+// plan - действия одного прохода синхронизации.
+// halted - pod'ы под kill switch, closed - pod'ы вне торговой сессии, removed - pod'ы удалённых клиентов,
+// удаляются без проверки порогов.
+// restart - запущенные pod'ы клиентов с need_restart: удаляются без проверки порогов и создаются заново (они же в create).
+// desired и running - число желаемых и запущенных pod'ов синкера по типам алгоритмов.
+type plan struct {
+	create  []string
+	delete  []string
+	halted  []string
+	closed  []string
+	removed []string
+	restart []string
+	managed int
+	desired map[model.AlgorithmType]int
+	running map[model.AlgorithmType]int
+}
+
+// scheduleKey - ключ расписания, пустой algorithm - расписание всего клиента
+type scheduleKey struct {
+	clientID  int64
+	algorithm model.AlgorithmType
+}
+
+// desiredState - состояние из БД, по которому строится план прохода. clients - спецификации клиентов по id,
+// deleted - id алгоритмов удалённых клиентов.
+type desiredState struct {
+	now        time.Time
+	algorithms []model.AlgorithmStatus
+	clients    map[int64]model.Client
+	deleted    map[int64]bool
+	halts      []model.Halt
+	suspended  map[int64]bool
+	schedules  map[scheduleKey]model.Schedule
+}
+
+// inSession - открыта ли торговая сессия алгоритма клиента.
+// Расписание алгоритма важнее расписания клиента, без расписания сессия открыта всегда.
+func (st desiredState) inSession(clientID int64, t model.AlgorithmType) bool {
+	if sc, ok := st.schedules[scheduleKey{clientID, t}]; ok {
+		return sc.InSession(st.now)
+	}
+	if sc, ok := st.schedules[scheduleKey{clientID, ""}]; ok {
+		return sc.InSession(st.now)
+	}
+	return true
+}
+
+// nextTransition - ближайшая граница торговой сессии среди всех расписаний
+func (st desiredState) nextTransition() time.Time {
+	var next time.Time
+	for _, sc := range st.schedules {
+		if t := sc.NextTransition(st.now); !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
 		}
 	}
+	return next
 }
 
-// syncAlgorithms - функция синхронизации алгоритмов с базой данных
+// Observe - наблюдаемое состояние pod'ов алгоритмов клиента
+func (s *Syncer) Observe(ctx context.Context, as model.AlgorithmStatus) ([]model.PodState, error) {
+	pods, err := s.deployer.GetPodList(ctx)
+	if err != nil {
+		s.logger.Error("Error fetching pods", loggers.Err(err))
+		return nil, err
+	}
+	running := make(map[string]bool, len(pods))
+	for _, name := range pods {
+		running[name] = true
+	}
+	states := make([]model.PodState, 0, len(model.AlgorithmTypes))
+	for _, t := range model.AlgorithmTypes {
+		name := podName(t, as.AlgorithmID)
+		states = append(states, model.PodState{Algorithm: t, Pod: name, Running: running[name]})
+	}
+	return states, nil
+}
+
+// Leader - является ли реплика ведущей по итогам последней попытки прохода
+func (s *Syncer) Leader() bool {
+	return s.leader.Load()
+}
+
+// syncAlgorithms - функция синхронизации алгоритмов с базой данных, итог прохода сохраняется в БД.
+// Проход выполняет только ведущая реплика, остальные реплики пропускают его и ждут освобождения блокировки,
+// а запрошенный на них через Trigger проход пересылают ведущей.
 func (s *Syncer) syncAlgorithms() {
-	algorithms, err := s.store.GetAlgorithmStatus(context.Background())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx := model.WithOrigin(context.Background(), model.Origin{Actor: "syncer"})
+	leader, err := s.store.TryLockSync(ctx)
+	if err != nil {
+		s.logger.Error("Error acquiring sync leader lock", loggers.Err(err))
+	}
+	if s.leader.Swap(leader) != leader {
+		s.logger.Info("sync leadership changed", slog.Bool("leader", leader))
+	}
+	if !leader {
+		s.takeLinks()
+		if s.forward.Swap(false) {
+			s.notify(ctx, model.SyncEventTrigger)
+		}
+		return
+	}
+	s.forward.Store(false)
+
+	ctx, span := tracing.Start(ctx, "sync.pass", trace.WithLinks(s.takeLinks()...))
+	defer tracing.End(span, &err)
+	passCtx, cancel := context.WithCancel(ctx)
+	s.setCancelPass(cancel)
+	defer cancel()
+
+	status := &model.SyncStatus{StartedAt: time.Now()}
+	err = s.runPass(passCtx, status)
+	s.setCancelPass(nil)
+	if passCtx.Err() != nil {
+		err = errPassCancelled
+		s.Trigger(ctx)
+	}
+	if err != nil {
+		status.Result = model.SyncFailed
+		status.Error = err.Error()
+	}
+	status.FinishedAt = time.Now()
+	span.SetAttributes(attribute.String("result", string(status.Result)),
+		attribute.Int("created", status.Created), attribute.Int("deleted", status.Deleted))
+	metrics.SyncDuration.WithLabelValues(string(status.Result)).Observe(status.FinishedAt.Sub(status.StartedAt).Seconds())
+	if err := s.store.SaveSyncStatus(ctx, status); err != nil {
+		s.logger.Error("Error saving sync status", loggers.Err(err))
+	}
+}
+
+// interruptPass - отмена идущего на реплике прохода, если он есть
+func (s *Syncer) interruptPass() {
+	s.passMu.Lock()
+	defer s.passMu.Unlock()
+	if s.cancelPass != nil {
+		s.logger.Warn("sync pass cancelled by kill switch")
+		s.cancelPass()
+	}
+}
+
+// setCancelPass - отмена идущего прохода для interruptPass, nil - проход закончен
+func (s *Syncer) setCancelPass(cancel context.CancelFunc) {
+	s.passMu.Lock()
+	defer s.passMu.Unlock()
+	s.cancelPass = cancel
+}
+
+// takeLinks - span'ы запросов, накопленные к началу прохода
+func (s *Syncer) takeLinks() []trace.Link {
+	s.linksMu.Lock()
+	defer s.linksMu.Unlock()
+	links := s.links
+	s.links = nil
+	return links
+}
+
+// runPass - один проход синхронизации
+func (s *Syncer) runPass(ctx context.Context, status *model.SyncStatus) error {
+	freeze, err := s.store.GetFreeze(ctx)
+	if err != nil {
+		s.logger.Error("Error fetching sync freeze", loggers.Err(err))
+		return err
+	}
+	if freeze.Active(time.Now()) {
+		s.logger.Info("sync is frozen, pass skipped", slog.String("reason", freeze.Reason))
+		status.Result = model.SyncFrozen
+		return nil
+	}
+
+	st, err := s.loadState(ctx)
+	if err != nil {
+		return err
+	}
+	s.wakeAt = st.nextTransition()
+	for id := range st.suspended {
+		status.SkippedClients = append(status.SkippedClients, id)
+	}
+	sort.Slice(status.SkippedClients, func(i, j int) bool { return status.SkippedClients[i] < status.SkippedClients[j] })
+	if len(status.SkippedClients) > 0 {
+		s.logger.Info("suspended clients skipped", slog.Any("clients", status.SkippedClients))
+	}
+
+	pods, err := s.deployer.GetPodList(ctx)
+	if err != nil {
+		s.logger.Error("Error fetching pods", loggers.Err(err))
+		return err
+	}
+
+	p := buildPlan(st, pods)
+	metrics.SetAlgorithms(p.desired, p.running)
+	status.Deleted += s.deletePods(ctx, actionHalt, p.halted)
+	status.Deleted += s.deletePods(ctx, actionSessionClose, p.closed)
+	status.Deleted += s.deletePods(ctx, actionClientDeleted, p.removed)
+	if !s.checkGuard(ctx, p) {
+		status.Result = model.SyncBlocked
+		return nil
+	}
+
+	clients := clientsByAlgorithm(st.algorithms)
+	failed := make(map[int64]bool)
+	kept := make(map[string]bool)
+	for _, name := range p.restart {
+		if err := s.apply(ctx, actionRestart, name, s.deployer.DeletePod); err != nil {
+			s.logger.Error("Error delete pod", slog.String("pod", name), loggers.Err(err))
+			_, algorithmID, _ := parsePodName(name)
+			failed[clients[algorithmID]] = true
+			kept[name] = true
+			continue
+		}
+		status.Deleted++
+	}
+	spawned := make(map[int64]bool)
+	for _, name := range p.create {
+		if kept[name] {
+			continue
+		}
+		_, algorithmID, _ := parsePodName(name)
+		c, ok := st.clients[clients[algorithmID]]
+		if !ok {
+			s.logger.Warn("client of pod not found, pod not created", slog.String("pod", name))
+			continue
+		}
+		if s.haltedNow(ctx, c.ID, name) {
+			continue
+		}
+		create := func(ctx context.Context, name string) error {
+			return s.deployer.CreatePod(ctx, model.NewPod(name, &c))
+		}
+		if err := s.apply(ctx, actionCreate, name, create); err != nil {
+			s.logger.Error("Error creating pod", slog.String("pod", name), loggers.Err(err))
+			continue
+		}
+		status.Created++
+		spawned[c.ID] = true
+	}
+	s.markSpawned(ctx, spawned)
+	s.markRestarted(ctx, st, failed)
+	status.Deleted += s.deletePods(ctx, actionDelete, p.delete)
+	status.Result = model.SyncOK
+	return nil
+}
+
+// haltedNow - попадает ли pod клиента под остановку, добавленную после чтения состояния прохода.
+// Остановки перечитываются из БД перед каждым созданием pod'a: kill switch на другой реплике этот проход не отменяет.
+// Если остановки прочитать не удалось, pod не создаётся - его создаст следующий проход.
+func (s *Syncer) haltedNow(ctx context.Context, clientID int64, name string) bool {
+	halts, err := s.store.GetHalts(ctx, true)
 	if err != nil {
-		s.logger.Error("Error fetching clients:", err)
+		s.logger.Error("Error fetching halts, pod not created", slog.String("pod", name), loggers.Err(err))
+		return true
+	}
+	t, _, _ := parsePodName(name)
+	if isHalted(halts, clientID, t) {
+		s.logger.Warn("algorithm halted during pass, pod not created", slog.String("pod", name))
+		return true
+	}
+	return false
+}
+
+// markRestarted - снятие need_restart с клиентов, у которых не осталось pod'ов старой спецификации:
+// pod'ы удалены, а созданы или будут созданы по текущей. failed - клиенты, pod'ы которых удалить не удалось,
+// с них флаг не снимается. Ошибка не прерывает проход - следующий проход пересоздаст pod'ы ещё раз.
+func (s *Syncer) markRestarted(ctx context.Context, st desiredState, failed map[int64]bool) {
+	var restarted []model.Client
+	for _, c := range st.clients {
+		if c.NeedRestart && !st.suspended[c.ID] && !failed[c.ID] {
+			restarted = append(restarted, c)
+		}
+	}
+	if len(restarted) == 0 {
 		return
 	}
+	sort.Slice(restarted, func(i, j int) bool { return restarted[i].ID < restarted[j].ID })
+	if err := s.store.SetClientsRestarted(ctx, restarted); err != nil {
+		s.logger.Error("Error saving clients restart", loggers.Err(err))
+	}
+}
+
+// markSpawned - запись времени запуска pod'ов клиентов. Ошибка не прерывает проход - pod'ы уже созданы.
+func (s *Syncer) markSpawned(ctx context.Context, clients map[int64]bool) {
+	if len(clients) == 0 {
+		return
+	}
+	ids := make([]int64, 0, len(clients))
+	for id := range clients {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if err := s.store.SetClientsSpawned(ctx, ids); err != nil {
+		s.logger.Error("Error saving clients spawn time", loggers.Err(err))
+	}
+}
+
+// loadState - чтение из БД алгоритмов, спецификаций клиентов, алгоритмов удалённых клиентов, остановок, приостановленных клиентов и расписаний.
+// Клиенты читаются после алгоритмов: клиент и его алгоритмы создаются в одной транзакции,
+// поэтому у каждого прочитанного алгоритма есть спецификация, если клиента не удалили между запросами.
+func (s *Syncer) loadState(ctx context.Context) (desiredState, error) {
+	st := desiredState{now: time.Now()}
+	algorithms, err := s.store.GetAlgorithmStatus(ctx)
+	if err != nil {
+		s.logger.Error("Error fetching clients", loggers.Err(err))
+		return st, err
+	}
+	clients, err := s.store.GetClients(ctx)
+	if err != nil {
+		s.logger.Error("Error fetching client specs", loggers.Err(err))
+		return st, err
+	}
+	deleted, err := s.store.GetDeletedAlgorithms(ctx)
+	if err != nil {
+		s.logger.Error("Error fetching deleted clients", loggers.Err(err))
+		return st, err
+	}
+	halts, err := s.store.GetHalts(ctx, true)
+	if err != nil {
+		s.logger.Error("Error fetching halts", loggers.Err(err))
+		return st, err
+	}
+	suspensions, err := s.store.GetSuspendedClients(ctx)
+	if err != nil {
+		s.logger.Error("Error fetching suspended clients", loggers.Err(err))
+		return st, err
+	}
+	schedules, err := s.store.GetSchedules(ctx, 0)
+	if err != nil {
+		s.logger.Error("Error fetching schedules", loggers.Err(err))
+		return st, err
+	}
+	st.algorithms = algorithms
+	st.clients = make(map[int64]model.Client, len(clients))
+	for _, c := range clients {
+		st.clients[c.ID] = c
+	}
+	st.deleted = make(map[int64]bool, len(deleted))
+	for _, id := range deleted {
+		st.deleted[id] = true
+	}
+	st.schedules = make(map[scheduleKey]model.Schedule, len(schedules))
+	for _, sc := range schedules {
+		st.schedules[scheduleKey{sc.ClientID, sc.Algorithm}] = sc
+	}
+	st.halts = halts
+	st.suspended = make(map[int64]bool, len(suspensions))
+	for _, sp := range suspensions {
+		st.suspended[sp.ClientID] = true
+	}
+	return st, nil
+}
 
-	list, _ := s.deployer.GetPodList()
-	fmt.Println(list)
+// Действия синкера с pod'ами в метриках
+const (
+	actionCreate        = "create"
+	actionDelete        = "delete"
+	actionRestart       = "restart"
+	actionHalt          = "halt"
+	actionSessionClose  = "session_close"
+	actionClientDeleted = "client_deleted"
+	actionKill          = "kill"
+)
 
-	if algorithms == nil {
+// countAction - действие синкера с pod'ом в метриках по типу алгоритма
+func countAction(action, pod string, err error) {
+	t, _, _ := parsePodName(pod)
+	if err != nil {
+		metrics.SyncActionFailures.WithLabelValues(action, string(t)).Inc()
 		return
 	}
-	for _, a := range algorithms {
-		if a.VWAP {
-			err := s.deployer.CreatePod(fmt.Sprintf("vmap-%d", a.AlgorithmID))
-			if err != nil {
-				s.logger.Error("Error creating pod:", err)
-				return
-			}
-		} else {
-			err := s.deployer.DeletePod(fmt.Sprintf("vmap-%d", a.AlgorithmID))
-			if err != nil {
-				s.logger.Error("Error delete pod:", err)
-				return
-			}
+	metrics.SyncActions.WithLabelValues(action, string(t)).Inc()
+}
+
+// apply - действие синкера с pod'ом в span'е sync.<действие> и в метриках
+func (s *Syncer) apply(ctx context.Context, action, name string, call func(context.Context, string) error) (err error) {
+	t, _, _ := parsePodName(name)
+	ctx, span := tracing.Start(ctx, "sync."+action,
+		trace.WithAttributes(attribute.String("pod", name), attribute.String("algorithm", string(t))))
+	defer tracing.End(span, &err)
+	err = call(ctx, name)
+	countAction(action, name, err)
+	return err
+}
+
+// deletePods - удаление pod'ов, возвращает число удалённых. action - причина удаления для метрик.
+func (s *Syncer) deletePods(ctx context.Context, action string, names []string) int {
+	var n int
+	for _, name := range names {
+		if err := s.apply(ctx, action, name, s.deployer.DeletePod); err != nil {
+			s.logger.Error("Error delete pod", slog.String("pod", name), loggers.Err(err))
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// buildPlan - сравнение желаемого состояния из БД с запущенными pod'ами.
+// Удаляются только pod'ы синкера, остальные pod'ы в namespace не трогаются.
+// Pod'ы приостановленных клиентов не создаются, не удаляются и не учитываются в порогах удаления.
+// Pod'ы включенных алгоритмов вне торговой сессии удаляются без проверки порогов - закрытие сессии ожидаемо.
+// Pod'ы удалённых клиентов удаляются без проверки порогов и не учитываются в managed - удаление подтверждено через API.
+// Желаемые запущенные pod'ы клиентов с need_restart пересоздаются по текущей спецификации клиента.
+func buildPlan(st desiredState, pods []string) plan {
+	desired := make(map[string]bool)
+	enabled := make(map[string]bool)
+	for _, a := range st.algorithms {
+		if st.suspended[a.ClientID] {
+			continue
 		}
-		if a.HFT {
-			err := s.deployer.CreatePod(fmt.Sprintf("hft-%d", a.AlgorithmID))
-			if err != nil {
-				s.logger.Error("Error creating pod:", err)
-				return
+		for _, t := range model.AlgorithmTypes {
+			enabled[podName(t, a.AlgorithmID)] = a.Enabled(t)
+			if a.Enabled(t) && !isHalted(st.halts, a.ClientID, t) && st.inSession(a.ClientID, t) {
+				desired[podName(t, a.AlgorithmID)] = true
 			}
-		} else {
-			err := s.deployer.DeletePod(fmt.Sprintf("hft-%d", a.AlgorithmID))
-			if err != nil {
-				s.logger.Error("Error delete pod:", err)
-				return
+		}
+	}
+
+	p := plan{desired: make(map[model.AlgorithmType]int), running: make(map[model.AlgorithmType]int)}
+	for name := range desired {
+		if t, _, ok := parsePodName(name); ok {
+			p.desired[t]++
+		}
+	}
+	clients := clientsByAlgorithm(st.algorithms)
+	running := make(map[string]bool)
+	restart := make(map[string]bool)
+	for _, name := range pods {
+		t, algorithmID, ok := parsePodName(name)
+		if !ok {
+			continue
+		}
+		running[name] = true
+		p.running[t]++
+		clientID, known := clients[algorithmID]
+		if known && st.suspended[clientID] {
+			continue
+		}
+		if st.deleted[algorithmID] {
+			p.removed = append(p.removed, name)
+			continue
+		}
+		p.managed++
+		switch {
+		case desired[name]:
+			if st.clients[clientID].NeedRestart {
+				p.restart = append(p.restart, name)
+				restart[name] = true
 			}
+		case halted(st.halts, name, clients):
+			p.halted = append(p.halted, name)
+		case known && enabled[name] && !st.inSession(clientID, t):
+			p.closed = append(p.closed, name)
+		default:
+			p.delete = append(p.delete, name)
 		}
-		if a.TWAP {
-			err := s.deployer.CreatePod(fmt.Sprintf("twap-%d", a.AlgorithmID))
-			if err != nil {
-				s.logger.Error("Error creating pod:", err)
-				return
+	}
+	for _, a := range st.algorithms {
+		for _, t := range model.AlgorithmTypes {
+			name := podName(t, a.AlgorithmID)
+			if desired[name] && (!running[name] || restart[name]) {
+				p.create = append(p.create, name)
 			}
-		} else {
-			err := s.deployer.DeletePod(fmt.Sprintf("twap-%d", a.AlgorithmID))
-			if err != nil {
-				s.logger.Error("Error delete pod:", err)
-				return
+		}
+	}
+	return p
+}
+
+// checkGuard - защита от массового удаления pod'ов.
+// Если план превышает пороги, проход отклоняется и поднимается тревога до подтверждения через API.
+// Подтверждение действует, только если удаляемые pod'ы входят в подтверждённый список.
+func (s *Syncer) checkGuard(ctx context.Context, p plan) bool {
+	guard, err := s.store.GetSyncGuard(ctx)
+	if err != nil {
+		s.logger.Error("Error fetching sync guard", loggers.Err(err))
+		return false
+	}
+
+	reason := s.exceeds(p)
+	if reason == "" {
+		if guard.Alert {
+			s.logger.Info("sync guard alert cleared, deletions are within limits")
+			if err := s.store.ResetSyncGuard(ctx); err != nil {
+				return false
 			}
 		}
+		return true
+	}
+
+	if guard.Alert && guard.Confirmed && containsAll(guard.Pods, p.delete) {
+		s.logger.Warn("mass deletion confirmed, proceeding",
+			slog.Int("deletions", len(p.delete)), slog.Int("managed", p.managed))
+		return s.store.ResetSyncGuard(ctx) == nil
+	}
+
+	s.logger.Error("mass deletion blocked, confirmation required",
+		slog.String("reason", reason), slog.Int("deletions", len(p.delete)), slog.Int("managed", p.managed))
+	if err := s.store.RaiseSyncGuard(ctx, &model.SyncGuard{
+		Pods:        p.delete,
+		ManagedPods: p.managed,
+		Reason:      reason,
+	}); err != nil {
+		s.logger.Error("Error raising sync guard", loggers.Err(err))
+	}
+	return false
+}
+
+// exceeds - причина превышения порогов удаления, пустая строка если пороги не превышены
+func (s *Syncer) exceeds(p plan) string {
+	g := s.cfg.SyncGuard
+	n := len(p.delete)
+	if g.MaxDeletions > 0 && n > g.MaxDeletions {
+		return fmt.Sprintf("%d deletions exceed limit of %d", n, g.MaxDeletions)
+	}
+	if g.MaxDeletionPercent > 0 && p.managed > 0 && p.managed >= g.MinManagedPods {
+		percent := float64(n) * 100 / float64(p.managed)
+		if percent > g.MaxDeletionPercent {
+			return fmt.Sprintf("%.1f%% of %d managed pods exceed limit of %.1f%%", percent, p.managed, g.MaxDeletionPercent)
+		}
+	}
+	return ""
+}
+
+// clientsByAlgorithm - соответствие id алгоритма и id клиента
+func clientsByAlgorithm(algorithms []model.AlgorithmStatus) map[int64]int64 {
+	clients := make(map[int64]int64, len(algorithms))
+	for _, a := range algorithms {
+		clients[a.AlgorithmID] = a.ClientID
+	}
+	return clients
+}
+
+// isHalted - попадает ли алгоритм клиента под одну из остановок
+func isHalted(halts []model.Halt, clientID int64, t model.AlgorithmType) bool {
+	for _, h := range halts {
+		if h.Matches(clientID, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// halted - попадает ли pod под одну из остановок.
+// Pod без записи в БД останавливается только глобально или по типу алгоритма.
+func halted(halts []model.Halt, name string, clients map[int64]int64) bool {
+	t, algorithmID, ok := parsePodName(name)
+	if !ok {
+		return false
+	}
+	return isHalted(halts, clients[algorithmID], t)
+}
+
+// podName - имя pod'a алгоритма
+func podName(t model.AlgorithmType, algorithmID int64) string {
+	return fmt.Sprintf("%s-%d", podPrefixes[t], algorithmID)
+}
+
+// parsePodName - разбор имени pod'a алгоритма, ok=false для чужих pod'ов
+func parsePodName(name string) (model.AlgorithmType, int64, bool) {
+	prefix, id, found := strings.Cut(name, "-")
+	if !found {
+		return "", 0, false
+	}
+	for t, p := range podPrefixes {
+		if p != prefix {
+			continue
+		}
+		algorithmID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return "", 0, false
+		}
+		return t, algorithmID, true
+	}
+	return "", 0, false
+}
+
+// containsAll - все ли элементы sub входят в set
+func containsAll(set, sub []string) bool {
+	in := make(map[string]bool, len(set))
+	for _, v := range set {
+		in[v] = true
+	}
+	for _, v := range sub {
+		if !in[v] {
+			return false
+		}
 	}
+	return true
 }
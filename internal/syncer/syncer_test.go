@@ -0,0 +1,636 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CyrilSbrodov/syncService/cmd/loggers"
+	"github.com/CyrilSbrodov/syncService/internal/config"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/CyrilSbrodov/syncService/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type mockStorage struct {
+	storage.Storage
+	algorithms []model.AlgorithmStatus
+	clients    []model.Client
+	deleted    []int64
+	restarted  []model.Client
+	guard      model.SyncGuard
+	halts      []model.Halt
+	suspended  []model.Suspension
+	freeze     model.Freeze
+	status     *model.SyncStatus
+	schedules  []model.Schedule
+	raised     int
+	reset      int
+	spawned    []int64
+	follower   bool
+	notified   []model.SyncEvent
+}
+
+func (m *mockStorage) TryLockSync(ctx context.Context) (bool, error) {
+	return !m.follower, nil
+}
+
+func (m *mockStorage) NotifySync(ctx context.Context, event model.SyncEvent) error {
+	m.notified = append(m.notified, event)
+	return nil
+}
+
+func (m *mockStorage) SetClientsSpawned(ctx context.Context, ids []int64) error {
+	m.spawned = append(m.spawned, ids...)
+	return nil
+}
+
+// GetClients - клиенты из clients, без них - клиенты алгоритмов с пустой спецификацией
+func (m *mockStorage) GetClients(ctx context.Context) ([]model.Client, error) {
+	if m.clients != nil {
+		return m.clients, nil
+	}
+	var clients []model.Client
+	for _, a := range m.algorithms {
+		clients = append(clients, model.Client{ID: a.ClientID})
+	}
+	return clients, nil
+}
+
+func (m *mockStorage) SetClientsRestarted(ctx context.Context, clients []model.Client) error {
+	m.restarted = append(m.restarted, clients...)
+	for _, r := range clients {
+		for i := range m.clients {
+			if m.clients[i].ID == r.ID && m.clients[i].Revision == r.Revision {
+				m.clients[i].NeedRestart = false
+			}
+		}
+	}
+	return nil
+}
+
+func (m *mockStorage) GetAlgorithmStatus(ctx context.Context) ([]model.AlgorithmStatus, error) {
+	return m.algorithms, nil
+}
+
+func (m *mockStorage) GetDeletedAlgorithms(ctx context.Context) ([]int64, error) {
+	return m.deleted, nil
+}
+
+func (m *mockStorage) GetHalts(ctx context.Context, activeOnly bool) ([]model.Halt, error) {
+	return m.halts, nil
+}
+
+func (m *mockStorage) GetSuspendedClients(ctx context.Context) ([]model.Suspension, error) {
+	return m.suspended, nil
+}
+
+func (m *mockStorage) GetFreeze(ctx context.Context) (*model.Freeze, error) {
+	f := m.freeze
+	return &f, nil
+}
+
+func (m *mockStorage) SaveSyncStatus(ctx context.Context, st *model.SyncStatus) error {
+	m.status = st
+	return nil
+}
+
+func (m *mockStorage) GetSchedules(ctx context.Context, clientID int64) ([]model.Schedule, error) {
+	return m.schedules, nil
+}
+
+func (m *mockStorage) GetSyncGuard(ctx context.Context) (*model.SyncGuard, error) {
+	g := m.guard
+	return &g, nil
+}
+
+func (m *mockStorage) RaiseSyncGuard(ctx context.Context, guard *model.SyncGuard) error {
+	m.raised++
+	m.guard = model.SyncGuard{Alert: true, Pods: guard.Pods, ManagedPods: guard.ManagedPods, Reason: guard.Reason}
+	return nil
+}
+
+func (m *mockStorage) ResetSyncGuard(ctx context.Context) error {
+	m.reset++
+	m.guard = model.SyncGuard{}
+	return nil
+}
+
+type mockDeployer struct {
+	pods    []string
+	created []string
+	deleted []string
+	specs   []model.Pod
+	// failDelete - pod, удаление которого завершается ошибкой
+	failDelete string
+	// onCreate - вызывается после создания pod'a, как действие другого запроса во время прохода
+	onCreate func(name string)
+}
+
+func (m *mockDeployer) CreatePod(ctx context.Context, pod model.Pod) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.created = append(m.created, pod.Name)
+	m.specs = append(m.specs, pod)
+	if m.onCreate != nil {
+		m.onCreate(pod.Name)
+	}
+	return nil
+}
+
+func (m *mockDeployer) DeletePod(ctx context.Context, name string) error {
+	if name == m.failDelete {
+		return errors.New("api down")
+	}
+	m.deleted = append(m.deleted, name)
+	return nil
+}
+
+func (m *mockDeployer) GetPodList(ctx context.Context) ([]string, error) {
+	return m.pods, nil
+}
+
+func newTestSyncer(store *mockStorage, d *mockDeployer) *Syncer {
+	var cfg config.Config
+	cfg.SyncGuard.MaxDeletions = 2
+	cfg.SyncGuard.MaxDeletionPercent = 50
+	cfg.SyncGuard.MinManagedPods = 4
+	return NewSyncer(d, store, loggers.SetupLogger("local"), cfg)
+}
+
+func TestBuildPlan(t *testing.T) {
+	algorithms := []model.AlgorithmStatus{
+		{AlgorithmID: 1, ClientID: 1, VWAP: true, HFT: true},
+		{AlgorithmID: 2, ClientID: 2, TWAP: true},
+	}
+	pods := []string{"vmap-1", "twap-1", "hft-3", "postgres-0", "other"}
+
+	p := buildPlan(desiredState{algorithms: algorithms}, pods)
+
+	assert.Equal(t, []string{"hft-1", "twap-2"}, p.create)
+	assert.Equal(t, []string{"twap-1", "hft-3"}, p.delete)
+	assert.Equal(t, 3, p.managed)
+	assert.Equal(t, map[model.AlgorithmType]int{model.AlgorithmVWAP: 1, model.AlgorithmTWAP: 1, model.AlgorithmHFT: 1}, p.desired)
+	assert.Equal(t, map[model.AlgorithmType]int{model.AlgorithmVWAP: 1, model.AlgorithmTWAP: 1, model.AlgorithmHFT: 1}, p.running)
+}
+
+func TestBuildPlanRestart(t *testing.T) {
+	algorithms := []model.AlgorithmStatus{
+		{AlgorithmID: 1, ClientID: 1, VWAP: true, HFT: true},
+		{AlgorithmID: 2, ClientID: 2, TWAP: true},
+	}
+	clients := map[int64]model.Client{1: {ID: 1, NeedRestart: true}, 2: {ID: 2}}
+	pods := []string{"vmap-1", "twap-2"}
+
+	p := buildPlan(desiredState{algorithms: algorithms, clients: clients}, pods)
+
+	assert.Equal(t, []string{"vmap-1"}, p.restart)
+	assert.Equal(t, []string{"vmap-1", "hft-1"}, p.create)
+	assert.Empty(t, p.delete)
+}
+
+func TestBuildPlanDeletedClient(t *testing.T) {
+	algorithms := []model.AlgorithmStatus{{AlgorithmID: 1, ClientID: 1, VWAP: true}}
+	pods := []string{"vmap-1", "vmap-2", "hft-2", "twap-3"}
+
+	p := buildPlan(desiredState{algorithms: algorithms, deleted: map[int64]bool{2: true}}, pods)
+
+	assert.Equal(t, []string{"vmap-2", "hft-2"}, p.removed)
+	assert.Equal(t, []string{"twap-3"}, p.delete)
+	assert.Equal(t, 2, p.managed)
+}
+
+func TestSyncer_DeletedClientBypassesGuard(t *testing.T) {
+	store := &mockStorage{
+		algorithms: []model.AlgorithmStatus{{AlgorithmID: 1, ClientID: 1, VWAP: true}},
+		deleted:    []int64{2, 3},
+	}
+	d := &mockDeployer{pods: []string{"vmap-1", "vmap-2", "hft-2", "vmap-3", "hft-3"}}
+	s := newTestSyncer(store, d)
+
+	s.syncAlgorithms()
+
+	assert.Equal(t, []string{"vmap-2", "hft-2", "vmap-3", "hft-3"}, d.deleted)
+	assert.Zero(t, store.raised)
+	assert.Equal(t, model.SyncOK, store.status.Result)
+}
+
+func TestSyncer_MassDeletionGuard(t *testing.T) {
+	store := &mockStorage{}
+	d := &mockDeployer{pods: []string{"vmap-1", "vmap-2", "vmap-3", "hft-1"}}
+	s := newTestSyncer(store, d)
+
+	s.syncAlgorithms()
+	assert.Empty(t, d.deleted)
+	assert.Equal(t, 1, store.raised)
+	assert.True(t, store.guard.Alert)
+	assert.ElementsMatch(t, d.pods, store.guard.Pods)
+
+	store.guard.Confirmed = true
+	s.syncAlgorithms()
+	assert.ElementsMatch(t, d.pods, d.deleted)
+	assert.Equal(t, 1, store.reset)
+	assert.False(t, store.guard.Alert)
+}
+
+func TestSyncer_MassDeletionGuardNewPods(t *testing.T) {
+	store := &mockStorage{}
+	d := &mockDeployer{pods: []string{"vmap-1", "vmap-2", "vmap-3"}}
+	s := newTestSyncer(store, d)
+
+	s.syncAlgorithms()
+	store.guard.Confirmed = true
+	d.pods = append(d.pods, "hft-4")
+	s.syncAlgorithms()
+
+	assert.Empty(t, d.deleted)
+	assert.Equal(t, 2, store.raised)
+	assert.False(t, store.guard.Confirmed)
+}
+
+func TestSyncer_PercentGuard(t *testing.T) {
+	store := &mockStorage{
+		algorithms: []model.AlgorithmStatus{
+			{AlgorithmID: 1, VWAP: true},
+			{AlgorithmID: 2, VWAP: true},
+		},
+	}
+	d := &mockDeployer{pods: []string{"vmap-1", "vmap-2", "vmap-3", "vmap-4", "vmap-5"}}
+	s := newTestSyncer(store, d)
+	s.cfg.SyncGuard.MaxDeletions = 0
+
+	s.syncAlgorithms()
+	assert.Empty(t, d.deleted)
+	assert.Equal(t, 1, store.raised)
+
+	store.algorithms = append(store.algorithms, model.AlgorithmStatus{AlgorithmID: 3, VWAP: true})
+	s.syncAlgorithms()
+	assert.Equal(t, []string{"vmap-4", "vmap-5"}, d.deleted)
+	assert.Equal(t, 1, store.reset)
+}
+
+func TestBuildPlanHalted(t *testing.T) {
+	algorithms := []model.AlgorithmStatus{
+		{AlgorithmID: 1, ClientID: 10, VWAP: true, HFT: true},
+		{AlgorithmID: 2, ClientID: 20, HFT: true},
+	}
+	halts := []model.Halt{
+		{Scope: model.HaltClient, ClientID: 10},
+		{Scope: model.HaltAlgorithm, Algorithm: model.AlgorithmHFT, ClientID: 20},
+	}
+	pods := []string{"vmap-1", "hft-2", "twap-3"}
+
+	p := buildPlan(desiredState{algorithms: algorithms, halts: halts}, pods)
+
+	assert.Empty(t, p.create)
+	assert.Equal(t, []string{"vmap-1", "hft-2"}, p.halted)
+	assert.Equal(t, []string{"twap-3"}, p.delete)
+}
+
+func TestSyncer_Kill(t *testing.T) {
+	store := &mockStorage{
+		algorithms: []model.AlgorithmStatus{
+			{AlgorithmID: 1, ClientID: 10, VWAP: true, HFT: true},
+			{AlgorithmID: 2, ClientID: 20, HFT: true},
+		},
+	}
+	d := &mockDeployer{pods: []string{"vmap-1", "hft-1", "hft-2", "hft-9", "postgres-0"}}
+	s := newTestSyncer(store, d)
+
+	deleted, failed, err := s.Kill(context.Background(), &model.Halt{Scope: model.HaltAlgorithm, Algorithm: model.AlgorithmHFT})
+	assert.NoError(t, err)
+	assert.Empty(t, failed)
+	assert.Equal(t, []string{"hft-1", "hft-2", "hft-9"}, deleted)
+
+	d.deleted = nil
+	deleted, _, err = s.Kill(context.Background(), &model.Halt{Scope: model.HaltGlobal})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vmap-1", "hft-1", "hft-2", "hft-9"}, deleted)
+}
+
+func TestSyncer_HaltDuringPass(t *testing.T) {
+	store := &mockStorage{
+		algorithms: []model.AlgorithmStatus{
+			{AlgorithmID: 1, ClientID: 10, VWAP: true, HFT: true},
+			{AlgorithmID: 2, ClientID: 20, HFT: true},
+		},
+	}
+	d := &mockDeployer{}
+	d.onCreate = func(name string) {
+		// остановка добавлена другой репликой после чтения состояния прохода
+		store.halts = []model.Halt{{Scope: model.HaltAlgorithm, Algorithm: model.AlgorithmHFT}}
+	}
+	s := newTestSyncer(store, d)
+
+	s.syncAlgorithms()
+
+	assert.Equal(t, []string{"vmap-1"}, d.created)
+	assert.Equal(t, model.SyncOK, store.status.Result)
+}
+
+func TestSyncer_KillCancelsPass(t *testing.T) {
+	store := &mockStorage{
+		algorithms: []model.AlgorithmStatus{
+			{AlgorithmID: 1, ClientID: 10, VWAP: true, HFT: true},
+			{AlgorithmID: 2, ClientID: 20, HFT: true},
+		},
+	}
+	d := &mockDeployer{}
+	s := newTestSyncer(store, d)
+	var killed bool
+	d.onCreate = func(name string) {
+		if killed {
+			return
+		}
+		killed = true
+		// Kill во время прохода не ждёт его окончания, а отменяет проход
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, _, err := s.Kill(context.Background(), &model.Halt{Scope: model.HaltClient, ClientID: 20})
+			assert.NoError(t, err)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("kill waits for the sync pass")
+		}
+	}
+
+	s.syncAlgorithms()
+
+	assert.Equal(t, []string{"vmap-1"}, d.created)
+	assert.Equal(t, model.SyncFailed, store.status.Result)
+	assert.Equal(t, errPassCancelled.Error(), store.status.Error)
+	assert.Len(t, s.trigger, 1)
+}
+
+func TestSyncer_Frozen(t *testing.T) {
+	store := &mockStorage{
+		algorithms: []model.AlgorithmStatus{{AlgorithmID: 1, VWAP: true}},
+		freeze:     model.Freeze{Frozen: true},
+	}
+	d := &mockDeployer{pods: []string{"hft-2"}}
+	s := newTestSyncer(store, d)
+
+	s.syncAlgorithms()
+	assert.Empty(t, d.created)
+	assert.Empty(t, d.deleted)
+	assert.Equal(t, model.SyncFrozen, store.status.Result)
+
+	past := time.Now().Add(-time.Minute)
+	store.freeze.Until = &past
+	s.syncAlgorithms()
+	assert.Equal(t, []string{"vmap-1"}, d.created)
+	assert.Equal(t, []string{"hft-2"}, d.deleted)
+	assert.Equal(t, model.SyncOK, store.status.Result)
+}
+
+func TestSyncer_SuspendedClient(t *testing.T) {
+	store := &mockStorage{
+		algorithms: []model.AlgorithmStatus{
+			{AlgorithmID: 1, ClientID: 10, VWAP: true},
+			{AlgorithmID: 2, ClientID: 20, TWAP: true},
+		},
+		suspended: []model.Suspension{{ClientID: 10, Suspended: true}},
+	}
+	d := &mockDeployer{pods: []string{"hft-1", "hft-2"}}
+	s := newTestSyncer(store, d)
+
+	s.syncAlgorithms()
+	assert.Equal(t, []string{"twap-2"}, d.created)
+	assert.Equal(t, []string{"hft-2"}, d.deleted)
+	assert.Equal(t, model.SyncOK, store.status.Result)
+	assert.Equal(t, []int64{10}, store.status.SkippedClients)
+	assert.Equal(t, 1, store.status.Created)
+	assert.Equal(t, 1, store.status.Deleted)
+}
+
+func TestSyncer_RestartAfterRollback(t *testing.T) {
+	// откат клиента 10 вернул образ algo:1 и выставил need_restart, pod'ы запущены из algo:2
+	clients := func() []model.Client {
+		return []model.Client{
+			{ID: 10, Image: "algo:1", Version: 1, CPU: "500m", Memory: "1Gi", NeedRestart: true, Revision: 6},
+			{ID: 20, Image: "algo:2", Version: 2},
+			{ID: 30, Image: "algo:1", NeedRestart: true, Revision: 2},
+		}
+	}
+	algorithms := []model.AlgorithmStatus{
+		{AlgorithmID: 1, ClientID: 10, VWAP: true, HFT: true},
+		{AlgorithmID: 2, ClientID: 20, TWAP: true},
+		{AlgorithmID: 3, ClientID: 30, HFT: true},
+	}
+	pods := []string{"vmap-1", "hft-1", "twap-2", "hft-3"}
+
+	t.Run("pods replaced from restored spec", func(t *testing.T) {
+		store := &mockStorage{algorithms: algorithms, clients: clients(),
+			suspended: []model.Suspension{{ClientID: 30, Suspended: true}}}
+		d := &mockDeployer{pods: pods}
+		s := newTestSyncer(store, d)
+
+		s.syncAlgorithms()
+		assert.Equal(t, []string{"vmap-1", "hft-1"}, d.deleted)
+		assert.Equal(t, []model.Pod{
+			{Name: "vmap-1", ClientID: 10, Image: "algo:1", Version: 1, CPU: "500m", Memory: "1Gi"},
+			{Name: "hft-1", ClientID: 10, Image: "algo:1", Version: 1, CPU: "500m", Memory: "1Gi"},
+		}, d.specs)
+		require.Len(t, store.restarted, 1)
+		assert.Equal(t, int64(10), store.restarted[0].ID)
+		assert.Equal(t, int64(6), store.restarted[0].Revision)
+		assert.Equal(t, model.SyncOK, store.status.Result)
+		assert.Equal(t, 2, store.status.Created)
+		assert.Equal(t, 2, store.status.Deleted)
+		assert.Equal(t, 0, store.raised)
+
+		d.deleted, d.created, d.specs = nil, nil, nil
+		s.syncAlgorithms()
+		assert.Empty(t, d.deleted)
+		assert.Empty(t, d.created)
+	})
+
+	t.Run("failed deletion keeps need_restart", func(t *testing.T) {
+		store := &mockStorage{algorithms: algorithms, clients: clients(),
+			suspended: []model.Suspension{{ClientID: 30, Suspended: true}}}
+		d := &mockDeployer{pods: pods, failDelete: "hft-1"}
+		s := newTestSyncer(store, d)
+
+		s.syncAlgorithms()
+		assert.Equal(t, []string{"vmap-1"}, d.deleted)
+		assert.Equal(t, []string{"vmap-1"}, d.created)
+		assert.Empty(t, store.restarted)
+		assert.True(t, store.clients[0].NeedRestart)
+	})
+}
+
+func TestSyncer_Schedules(t *testing.T) {
+	now := time.Now().UTC()
+	open := model.Schedule{
+		ClientID: 10,
+		Timezone: "UTC",
+		Open:     now.Add(-time.Hour).Format("15:04"),
+		Close:    now.Add(time.Hour).Format("15:04"),
+	}
+	closed := model.Schedule{
+		ClientID:  10,
+		Algorithm: model.AlgorithmHFT,
+		Timezone:  "UTC",
+		Open:      now.Add(time.Hour).Format("15:04"),
+		Close:     now.Add(2 * time.Hour).Format("15:04"),
+	}
+	store := &mockStorage{
+		algorithms: []model.AlgorithmStatus{
+			{AlgorithmID: 1, ClientID: 10, VWAP: true, HFT: true},
+			{AlgorithmID: 2, ClientID: 20, VWAP: true, HFT: true},
+			{AlgorithmID: 3, ClientID: 30, VWAP: true, HFT: true},
+		},
+		schedules: []model.Schedule{open, closed},
+	}
+	d := &mockDeployer{pods: []string{"vmap-2", "hft-2", "vmap-3", "hft-3", "hft-1"}}
+	s := newTestSyncer(store, d)
+	s.cfg.SyncGuard.MaxDeletions = 0
+
+	s.syncAlgorithms()
+	assert.Equal(t, []string{"vmap-1"}, d.created)
+	assert.Equal(t, []int64{10}, store.spawned)
+	assert.Equal(t, []string{"hft-1"}, d.deleted)
+	assert.Equal(t, 0, store.raised)
+	assert.False(t, s.wakeAt.IsZero())
+	assert.True(t, s.wakeAt.After(now))
+}
+
+func TestSyncer_Follower(t *testing.T) {
+	store := &mockStorage{
+		algorithms: []model.AlgorithmStatus{{AlgorithmID: 1, ClientID: 10, HFT: true}},
+		follower:   true,
+	}
+	d := &mockDeployer{pods: []string{"vmap-2"}}
+	s := newTestSyncer(store, d)
+
+	s.syncAlgorithms()
+	assert.False(t, s.Leader())
+	assert.Empty(t, d.created)
+	assert.Empty(t, d.deleted)
+	assert.Nil(t, store.status)
+
+	store.follower = false
+	s.syncAlgorithms()
+	assert.True(t, s.Leader())
+	assert.Equal(t, []string{"hft-1"}, d.created)
+	assert.Equal(t, model.SyncOK, store.status.Result)
+}
+
+func TestSyncer_FollowerForwardsTrigger(t *testing.T) {
+	store := &mockStorage{
+		algorithms: []model.AlgorithmStatus{{AlgorithmID: 1, ClientID: 10, HFT: true}},
+		follower:   true,
+	}
+	d := &mockDeployer{}
+	s := newTestSyncer(store, d)
+
+	// проход по таймеру не пересылается
+	s.syncAlgorithms()
+	assert.Empty(t, store.notified)
+
+	s.Trigger(context.Background())
+	<-s.trigger
+	s.syncAlgorithms()
+	assert.Empty(t, d.created)
+	assert.Equal(t, []model.SyncEvent{model.SyncEventTrigger}, store.notified)
+
+	// пересланный проход не пересылается повторно
+	s.syncAlgorithms()
+	assert.Len(t, store.notified, 1)
+}
+
+func TestSyncer_FollowerKillNotifiesLeader(t *testing.T) {
+	store := &mockStorage{
+		algorithms: []model.AlgorithmStatus{{AlgorithmID: 1, ClientID: 10, HFT: true}},
+		follower:   true,
+	}
+	d := &mockDeployer{pods: []string{"hft-1"}}
+	s := newTestSyncer(store, d)
+	s.syncAlgorithms()
+
+	deleted, _, err := s.Kill(context.Background(), &model.Halt{Scope: model.HaltGlobal})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"hft-1"}, deleted)
+	assert.Equal(t, []model.SyncEvent{model.SyncEventHalt}, store.notified)
+
+	// ведущая реплика отменяет свой проход сама и не уведомляет
+	store.follower, store.notified = false, nil
+	s.syncAlgorithms()
+	_, _, err = s.Kill(context.Background(), &model.Halt{Scope: model.HaltGlobal})
+	assert.NoError(t, err)
+	assert.Empty(t, store.notified)
+}
+
+func TestSyncer_Listen(t *testing.T) {
+	s := newTestSyncer(&mockStorage{}, &mockDeployer{})
+	ctx, cancel := context.WithCancel(context.Background())
+	s.setCancelPass(cancel)
+
+	events := make(chan model.SyncEvent, 3)
+	events <- "unknown"
+	events <- model.SyncEventTrigger
+	events <- model.SyncEventHalt
+	close(events)
+	s.listen(events)
+
+	// событие будит проход, но не пересылается дальше
+	assert.Len(t, s.trigger, 1)
+	assert.False(t, s.forward.Load())
+	assert.Error(t, ctx.Err())
+}
+
+func TestSyncer_Observe(t *testing.T) {
+	d := &mockDeployer{pods: []string{"vmap-3", "hft-4", "other"}}
+	s := newTestSyncer(&mockStorage{}, d)
+
+	pods, err := s.Observe(context.Background(), model.AlgorithmStatus{AlgorithmID: 3, ClientID: 1, VWAP: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.PodState{
+		{Algorithm: model.AlgorithmVWAP, Pod: "vmap-3", Running: true},
+		{Algorithm: model.AlgorithmTWAP, Pod: "twap-3"},
+		{Algorithm: model.AlgorithmHFT, Pod: "hft-3"},
+	}, pods)
+}
+
+func TestSyncer_Trace(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	store := &mockStorage{algorithms: []model.AlgorithmStatus{{AlgorithmID: 1, ClientID: 10, HFT: true}}}
+	d := &mockDeployer{pods: []string{"vmap-2"}}
+	s := newTestSyncer(store, d)
+	ctx, request := otel.Tracer("test").Start(context.Background(), "PUT /api/algorithm")
+	s.Trigger(ctx)
+	request.End()
+
+	s.syncAlgorithms()
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range sr.Ended() {
+		spans[span.Name()] = span
+	}
+	pass, ok := spans["sync.pass"]
+	require.True(t, ok)
+	require.Len(t, pass.Links(), 1)
+	assert.Equal(t, request.SpanContext().SpanID(), pass.Links()[0].SpanContext.SpanID())
+	assert.Contains(t, pass.Attributes(), attribute.String("result", string(model.SyncOK)))
+	for name, pod := range map[string]string{"sync.create": "hft-1", "sync.delete": "vmap-2"} {
+		span, ok := spans[name]
+		require.True(t, ok, name)
+		assert.Equal(t, pass.SpanContext().SpanID(), span.Parent().SpanID())
+		assert.Contains(t, span.Attributes(), attribute.String("pod", pod))
+	}
+	assert.Empty(t, s.takeLinks())
+}
@@ -0,0 +1,38 @@
+package tracing
+
+import (
+	"context"
+	"github.com/CyrilSbrodov/syncService/internal/deployer"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Deployer - деплоер, который оборачивает каждый вызов API кластера в span deployer.<операция>
+type Deployer struct {
+	next deployer.Deployer
+}
+
+// NewDeployer - конструктор деплоера с трассировкой поверх next
+func NewDeployer(next deployer.Deployer) *Deployer {
+	return &Deployer{next: next}
+}
+
+func (t *Deployer) CreatePod(ctx context.Context, pod model.Pod) (err error) {
+	ctx, span := Start(ctx, "deployer.create_pod", trace.WithAttributes(attribute.String("pod", pod.Name),
+		attribute.String("image", pod.Image)))
+	defer End(span, &err)
+	return t.next.CreatePod(ctx, pod)
+}
+
+func (t *Deployer) DeletePod(ctx context.Context, name string) (err error) {
+	ctx, span := Start(ctx, "deployer.delete_pod", trace.WithAttributes(attribute.String("pod", name)))
+	defer End(span, &err)
+	return t.next.DeletePod(ctx, name)
+}
+
+func (t *Deployer) GetPodList(ctx context.Context) (_ []string, err error) {
+	ctx, span := Start(ctx, "deployer.list_pods")
+	defer End(span, &err)
+	return t.next.GetPodList(ctx)
+}
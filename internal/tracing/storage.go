@@ -0,0 +1,362 @@
+package tracing
+
+import (
+	"context"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/CyrilSbrodov/syncService/internal/storage"
+	"time"
+)
+
+// Storage - хранилище, которое оборачивает каждый вызов storage.Storage в span storage.<метод>
+type Storage struct {
+	next storage.Storage
+}
+
+// NewStorage - конструктор хранилища с трассировкой поверх next
+func NewStorage(next storage.Storage) *Storage {
+	return &Storage{next: next}
+}
+
+func (t *Storage) AddClient(ctx context.Context, client *model.Client) (err error) {
+	ctx, span := Start(ctx, "storage.AddClient")
+	defer End(span, &err)
+	return t.next.AddClient(ctx, client)
+}
+
+func (t *Storage) GetClient(ctx context.Context, id int64) (_ *model.Client, err error) {
+	ctx, span := Start(ctx, "storage.GetClient")
+	defer End(span, &err)
+	return t.next.GetClient(ctx, id)
+}
+
+func (t *Storage) UpdateClient(ctx context.Context, client *model.Client) (err error) {
+	ctx, span := Start(ctx, "storage.UpdateClient")
+	defer End(span, &err)
+	return t.next.UpdateClient(ctx, client)
+}
+
+func (t *Storage) PatchClient(ctx context.Context, id, revision int64,
+	patch *model.ClientPatch) (_ *model.Client, err error) {
+	ctx, span := Start(ctx, "storage.PatchClient")
+	defer End(span, &err)
+	return t.next.PatchClient(ctx, id, revision, patch)
+}
+
+func (t *Storage) GetClients(ctx context.Context) (_ []model.Client, err error) {
+	ctx, span := Start(ctx, "storage.GetClients")
+	defer End(span, &err)
+	return t.next.GetClients(ctx)
+}
+
+func (t *Storage) SetClientsSpawned(ctx context.Context, ids []int64) (err error) {
+	ctx, span := Start(ctx, "storage.SetClientsSpawned")
+	defer End(span, &err)
+	return t.next.SetClientsSpawned(ctx, ids)
+}
+
+func (t *Storage) SetClientsRestarted(ctx context.Context, clients []model.Client) (err error) {
+	ctx, span := Start(ctx, "storage.SetClientsRestarted")
+	defer End(span, &err)
+	return t.next.SetClientsRestarted(ctx, clients)
+}
+
+func (t *Storage) DeleteClient(ctx context.Context, id int64) (err error) {
+	ctx, span := Start(ctx, "storage.DeleteClient")
+	defer End(span, &err)
+	return t.next.DeleteClient(ctx, id)
+}
+
+func (t *Storage) RestoreClient(ctx context.Context, id int64) (_ *model.Client, err error) {
+	ctx, span := Start(ctx, "storage.RestoreClient")
+	defer End(span, &err)
+	return t.next.RestoreClient(ctx, id)
+}
+
+func (t *Storage) SetDeletionProtection(ctx context.Context, id int64, protected bool) (_ *model.Client, err error) {
+	ctx, span := Start(ctx, "storage.SetDeletionProtection")
+	defer End(span, &err)
+	return t.next.SetDeletionProtection(ctx, id, protected)
+}
+
+func (t *Storage) PurgeDeletedClients(ctx context.Context, before time.Time) (_ int64, err error) {
+	ctx, span := Start(ctx, "storage.PurgeDeletedClients")
+	defer End(span, &err)
+	return t.next.PurgeDeletedClients(ctx, before)
+}
+
+func (t *Storage) UpdateAlgorithmStatus(ctx context.Context, as *model.AlgorithmStatus) (err error) {
+	ctx, span := Start(ctx, "storage.UpdateAlgorithmStatus")
+	defer End(span, &err)
+	return t.next.UpdateAlgorithmStatus(ctx, as)
+}
+
+func (t *Storage) PatchAlgorithmStatus(ctx context.Context, clientID int64,
+	patch *model.AlgorithmPatch) (_ *model.AlgorithmStatus, err error) {
+	ctx, span := Start(ctx, "storage.PatchAlgorithmStatus")
+	defer End(span, &err)
+	return t.next.PatchAlgorithmStatus(ctx, clientID, patch)
+}
+
+func (t *Storage) GetAlgorithmStatus(ctx context.Context) (_ []model.AlgorithmStatus, err error) {
+	ctx, span := Start(ctx, "storage.GetAlgorithmStatus")
+	defer End(span, &err)
+	return t.next.GetAlgorithmStatus(ctx)
+}
+
+func (t *Storage) GetDeletedAlgorithms(ctx context.Context) (_ []int64, err error) {
+	ctx, span := Start(ctx, "storage.GetDeletedAlgorithms")
+	defer End(span, &err)
+	return t.next.GetDeletedAlgorithms(ctx)
+}
+
+func (t *Storage) GetClientAlgorithms(ctx context.Context, clientID int64) (_ *model.AlgorithmStatus, err error) {
+	ctx, span := Start(ctx, "storage.GetClientAlgorithms")
+	defer End(span, &err)
+	return t.next.GetClientAlgorithms(ctx, clientID)
+}
+
+func (t *Storage) BulkUpdateAlgorithms(ctx context.Context, mode model.BulkMode,
+	changes []model.AlgorithmChange) (_ *model.BulkResult, err error) {
+	ctx, span := Start(ctx, "storage.BulkUpdateAlgorithms")
+	defer End(span, &err)
+	return t.next.BulkUpdateAlgorithms(ctx, mode, changes)
+}
+
+func (t *Storage) GetSyncGuard(ctx context.Context) (_ *model.SyncGuard, err error) {
+	ctx, span := Start(ctx, "storage.GetSyncGuard")
+	defer End(span, &err)
+	return t.next.GetSyncGuard(ctx)
+}
+
+func (t *Storage) RaiseSyncGuard(ctx context.Context, guard *model.SyncGuard) (err error) {
+	ctx, span := Start(ctx, "storage.RaiseSyncGuard")
+	defer End(span, &err)
+	return t.next.RaiseSyncGuard(ctx, guard)
+}
+
+func (t *Storage) ConfirmSyncGuard(ctx context.Context) (err error) {
+	ctx, span := Start(ctx, "storage.ConfirmSyncGuard")
+	defer End(span, &err)
+	return t.next.ConfirmSyncGuard(ctx)
+}
+
+func (t *Storage) ResetSyncGuard(ctx context.Context) (err error) {
+	ctx, span := Start(ctx, "storage.ResetSyncGuard")
+	defer End(span, &err)
+	return t.next.ResetSyncGuard(ctx)
+}
+
+func (t *Storage) AddHalt(ctx context.Context, halt *model.Halt) (err error) {
+	ctx, span := Start(ctx, "storage.AddHalt")
+	defer End(span, &err)
+	return t.next.AddHalt(ctx, halt)
+}
+
+func (t *Storage) GetHalts(ctx context.Context, activeOnly bool) (_ []model.Halt, err error) {
+	ctx, span := Start(ctx, "storage.GetHalts")
+	defer End(span, &err)
+	return t.next.GetHalts(ctx, activeOnly)
+}
+
+func (t *Storage) ResumeHalt(ctx context.Context, id int64, resumedBy string) (err error) {
+	ctx, span := Start(ctx, "storage.ResumeHalt")
+	defer End(span, &err)
+	return t.next.ResumeHalt(ctx, id, resumedBy)
+}
+
+func (t *Storage) SetClientSuspension(ctx context.Context, s *model.Suspension) (err error) {
+	ctx, span := Start(ctx, "storage.SetClientSuspension")
+	defer End(span, &err)
+	return t.next.SetClientSuspension(ctx, s)
+}
+
+func (t *Storage) GetSuspendedClients(ctx context.Context) (_ []model.Suspension, err error) {
+	ctx, span := Start(ctx, "storage.GetSuspendedClients")
+	defer End(span, &err)
+	return t.next.GetSuspendedClients(ctx)
+}
+
+func (t *Storage) GetFreeze(ctx context.Context) (_ *model.Freeze, err error) {
+	ctx, span := Start(ctx, "storage.GetFreeze")
+	defer End(span, &err)
+	return t.next.GetFreeze(ctx)
+}
+
+func (t *Storage) SetFreeze(ctx context.Context, f *model.Freeze) (err error) {
+	ctx, span := Start(ctx, "storage.SetFreeze")
+	defer End(span, &err)
+	return t.next.SetFreeze(ctx, f)
+}
+
+func (t *Storage) GetSyncStatus(ctx context.Context) (_ *model.SyncStatus, err error) {
+	ctx, span := Start(ctx, "storage.GetSyncStatus")
+	defer End(span, &err)
+	return t.next.GetSyncStatus(ctx)
+}
+
+func (t *Storage) SaveSyncStatus(ctx context.Context, st *model.SyncStatus) (err error) {
+	ctx, span := Start(ctx, "storage.SaveSyncStatus")
+	defer End(span, &err)
+	return t.next.SaveSyncStatus(ctx, st)
+}
+
+func (t *Storage) TryLockSync(ctx context.Context) (_ bool, err error) {
+	ctx, span := Start(ctx, "storage.TryLockSync")
+	defer End(span, &err)
+	return t.next.TryLockSync(ctx)
+}
+
+func (t *Storage) NotifySync(ctx context.Context, event model.SyncEvent) (err error) {
+	ctx, span := Start(ctx, "storage.NotifySync")
+	defer End(span, &err)
+	return t.next.NotifySync(ctx, event)
+}
+
+func (t *Storage) ListenSync(ctx context.Context) (_ <-chan model.SyncEvent, err error) {
+	ctx, span := Start(ctx, "storage.ListenSync")
+	defer End(span, &err)
+	return t.next.ListenSync(ctx)
+}
+
+func (t *Storage) GetSchedules(ctx context.Context, clientID int64) (_ []model.Schedule, err error) {
+	ctx, span := Start(ctx, "storage.GetSchedules")
+	defer End(span, &err)
+	return t.next.GetSchedules(ctx, clientID)
+}
+
+func (t *Storage) SetSchedule(ctx context.Context, s *model.Schedule) (err error) {
+	ctx, span := Start(ctx, "storage.SetSchedule")
+	defer End(span, &err)
+	return t.next.SetSchedule(ctx, s)
+}
+
+func (t *Storage) DeleteSchedule(ctx context.Context, clientID int64, algorithm model.AlgorithmType) (err error) {
+	ctx, span := Start(ctx, "storage.DeleteSchedule")
+	defer End(span, &err)
+	return t.next.DeleteSchedule(ctx, clientID, algorithm)
+}
+
+func (t *Storage) AddScheduledChange(ctx context.Context, c *model.ScheduledChange) (err error) {
+	ctx, span := Start(ctx, "storage.AddScheduledChange")
+	defer End(span, &err)
+	return t.next.AddScheduledChange(ctx, c)
+}
+
+func (t *Storage) GetScheduledChanges(ctx context.Context, clientID int64,
+	status model.ChangeStatus) (_ []model.ScheduledChange, err error) {
+	ctx, span := Start(ctx, "storage.GetScheduledChanges")
+	defer End(span, &err)
+	return t.next.GetScheduledChanges(ctx, clientID, status)
+}
+
+func (t *Storage) CancelScheduledChange(ctx context.Context, id int64) (err error) {
+	ctx, span := Start(ctx, "storage.CancelScheduledChange")
+	defer End(span, &err)
+	return t.next.CancelScheduledChange(ctx, id)
+}
+
+func (t *Storage) ApplyDueChanges(ctx context.Context) (_ []model.ScheduledChange, err error) {
+	ctx, span := Start(ctx, "storage.ApplyDueChanges")
+	defer End(span, &err)
+	return t.next.ApplyDueChanges(ctx)
+}
+
+func (t *Storage) ReserveIdempotencyKey(ctx context.Context,
+	rec *model.IdempotencyRecord) (_ *model.IdempotencyRecord, err error) {
+	ctx, span := Start(ctx, "storage.ReserveIdempotencyKey")
+	defer End(span, &err)
+	return t.next.ReserveIdempotencyKey(ctx, rec)
+}
+
+func (t *Storage) SaveIdempotencyResponse(ctx context.Context, rec *model.IdempotencyRecord) (err error) {
+	ctx, span := Start(ctx, "storage.SaveIdempotencyResponse")
+	defer End(span, &err)
+	return t.next.SaveIdempotencyResponse(ctx, rec)
+}
+
+func (t *Storage) DeleteIdempotencyKey(ctx context.Context, key string) (err error) {
+	ctx, span := Start(ctx, "storage.DeleteIdempotencyKey")
+	defer End(span, &err)
+	return t.next.DeleteIdempotencyKey(ctx, key)
+}
+
+func (t *Storage) PurgeIdempotencyKeys(ctx context.Context) (_ int64, err error) {
+	ctx, span := Start(ctx, "storage.PurgeIdempotencyKeys")
+	defer End(span, &err)
+	return t.next.PurgeIdempotencyKeys(ctx)
+}
+
+func (t *Storage) AddAPIKey(ctx context.Context, key *model.APIKey, hash string) (err error) {
+	ctx, span := Start(ctx, "storage.AddAPIKey")
+	defer End(span, &err)
+	return t.next.AddAPIKey(ctx, key, hash)
+}
+
+func (t *Storage) GetAPIKeys(ctx context.Context) (_ []model.APIKey, err error) {
+	ctx, span := Start(ctx, "storage.GetAPIKeys")
+	defer End(span, &err)
+	return t.next.GetAPIKeys(ctx)
+}
+
+func (t *Storage) AuthenticateAPIKey(ctx context.Context, hash string) (_ *model.APIKey, err error) {
+	ctx, span := Start(ctx, "storage.AuthenticateAPIKey")
+	defer End(span, &err)
+	return t.next.AuthenticateAPIKey(ctx, hash)
+}
+
+func (t *Storage) RevokeAPIKey(ctx context.Context, id int64) (err error) {
+	ctx, span := Start(ctx, "storage.RevokeAPIKey")
+	defer End(span, &err)
+	return t.next.RevokeAPIKey(ctx, id)
+}
+
+func (t *Storage) AddApproval(ctx context.Context, a *model.ApprovalRequest) (err error) {
+	ctx, span := Start(ctx, "storage.AddApproval")
+	defer End(span, &err)
+	return t.next.AddApproval(ctx, a)
+}
+
+func (t *Storage) GetApproval(ctx context.Context, id int64) (_ *model.ApprovalRequest, err error) {
+	ctx, span := Start(ctx, "storage.GetApproval")
+	defer End(span, &err)
+	return t.next.GetApproval(ctx, id)
+}
+
+func (t *Storage) GetApprovals(ctx context.Context, clientID int64,
+	status model.ApprovalStatus) (_ []model.ApprovalRequest, err error) {
+	ctx, span := Start(ctx, "storage.GetApprovals")
+	defer End(span, &err)
+	return t.next.GetApprovals(ctx, clientID, status)
+}
+
+func (t *Storage) DecideApproval(ctx context.Context, id int64, status model.ApprovalStatus, decidedBy,
+	comment string) (_ *model.ApprovalRequest, err error) {
+	ctx, span := Start(ctx, "storage.DecideApproval")
+	defer End(span, &err)
+	return t.next.DecideApproval(ctx, id, status, decidedBy, comment)
+}
+
+func (t *Storage) GetAuditLog(ctx context.Context, f model.AuditFilter) (_ []model.AuditEntry, err error) {
+	ctx, span := Start(ctx, "storage.GetAuditLog")
+	defer End(span, &err)
+	return t.next.GetAuditLog(ctx, f)
+}
+
+func (t *Storage) GetClientRevisions(ctx context.Context, clientID int64) (_ []model.ClientRevision, err error) {
+	ctx, span := Start(ctx, "storage.GetClientRevisions")
+	defer End(span, &err)
+	return t.next.GetClientRevisions(ctx, clientID)
+}
+
+func (t *Storage) GetClientRevision(ctx context.Context, clientID,
+	revision int64) (_ *model.ClientRevision, err error) {
+	ctx, span := Start(ctx, "storage.GetClientRevision")
+	defer End(span, &err)
+	return t.next.GetClientRevision(ctx, clientID, revision)
+}
+
+func (t *Storage) RollbackClient(ctx context.Context, clientID, revision int64) (_ *model.ClientRevision, err error) {
+	ctx, span := Start(ctx, "storage.RollbackClient")
+	defer End(span, &err)
+	return t.next.RollbackClient(ctx, clientID, revision)
+}
@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/CyrilSbrodov/syncService/internal/config"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation - имя библиотеки инструментирования в span'ах сервиса
+const instrumentation = "github.com/CyrilSbrodov/syncService"
+
+// Экспортёры span'ов
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Setup - глобальный провайдер трассировки и распространение контекста W3C Trace Context и Baggage.
+// С экспортёром none span'ы не создаются, но контекст вызывающего по-прежнему передаётся дальше.
+// Возвращает функцию остановки, которая отправляет накопленные span'ы.
+func Setup(ctx context.Context, cfg *config.Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Tracing.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{}
+		if cfg.Tracing.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Tracing.Endpoint))
+		}
+		if cfg.Tracing.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Tracing.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(cfg.Tracing.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Tracing.SampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Start - span сервиса, дочерний к span'у из ctx
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, opts...)
+}
+
+// End - завершение span'а с ошибкой вызова, вызывается через defer с адресом ошибки.
+// Ошибка записывается в span всегда, статусом Error отмечаются только сбои - не найден,
+// конфликт и ошибки валидации являются ответом на запрос, а не сбоем.
+func End(span trace.Span, err *error) {
+	if *err != nil {
+		span.RecordError(*err)
+		if Failure(*err) {
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+	}
+	span.End()
+}
+
+// Failure - является ли ошибка сбоем: внутренняя ошибка, недоступность зависимости или ошибка без категории
+func Failure(err error) bool {
+	var (
+		e *model.Error
+		v *model.ValidationError
+	)
+	switch {
+	case errors.As(err, &v):
+		return false
+	case errors.As(err, &e):
+		return e.Kind == model.KindInternal || e.Kind == model.KindUnavailable
+	}
+	return true
+}
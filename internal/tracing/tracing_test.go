@@ -0,0 +1,133 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/CyrilSbrodov/syncService/internal/config"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/CyrilSbrodov/syncService/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recorder - глобальный провайдер, который запоминает завершённые span'ы, на время теста
+func recorder(t *testing.T) *tracetest.SpanRecorder {
+	sr := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return sr
+}
+
+// clientStorage - хранилище, в котором реализовано только получение клиента
+type clientStorage struct {
+	storage.Storage
+	err error
+}
+
+func (s clientStorage) GetClient(ctx context.Context, id int64) (*model.Client, error) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return nil, errors.New("span is not propagated")
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &model.Client{ID: id}, nil
+}
+
+func TestStorage(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus codes.Code
+		expectedEvents int
+	}{
+		{name: "ok", expectedStatus: codes.Unset},
+		{name: "not found", err: model.ErrorClientNotFound, expectedStatus: codes.Unset, expectedEvents: 1},
+		{name: "validation", err: &model.ValidationError{}, expectedStatus: codes.Unset, expectedEvents: 1},
+		{name: "unavailable", err: model.ErrorUnavailable, expectedStatus: codes.Error, expectedEvents: 1},
+		{name: "unknown", err: fmt.Errorf("query: %w", errors.New("boom")), expectedStatus: codes.Error, expectedEvents: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sr := recorder(t)
+
+			_, err := NewStorage(clientStorage{err: tt.err}).GetClient(context.Background(), 42)
+
+			assert.ErrorIs(t, err, tt.err)
+			spans := sr.Ended()
+			require.Len(t, spans, 1)
+			assert.Equal(t, "storage.GetClient", spans[0].Name())
+			assert.Equal(t, tt.expectedStatus, spans[0].Status().Code)
+			assert.Len(t, spans[0].Events(), tt.expectedEvents)
+		})
+	}
+}
+
+// podList - деплоер, который только возвращает список pod'ов и создаёт pod'ы
+type podList struct{}
+
+func (d podList) CreatePod(ctx context.Context, pod model.Pod) error { return nil }
+func (d podList) DeletePod(ctx context.Context, name string) error   { return errors.New("api down") }
+func (d podList) GetPodList(ctx context.Context) ([]string, error)   { return []string{"hft-1"}, nil }
+
+func TestDeployer(t *testing.T) {
+	sr := recorder(t)
+	ctx, parent := Start(context.Background(), "sync.pass")
+
+	d := NewDeployer(podList{})
+	assert.NoError(t, d.CreatePod(ctx, model.Pod{Name: "hft-1", Image: "algo/hft:1.0"}))
+	assert.Error(t, d.DeletePod(ctx, "hft-2"))
+	parent.End()
+
+	spans := sr.Ended()
+	require.Len(t, spans, 3)
+	assert.Equal(t, "deployer.create_pod", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.String("pod", "hft-1"))
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, "deployer.delete_pod", spans[1].Name())
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+}
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name      string
+		exporter  string
+		expectErr bool
+	}{
+		{name: "none", exporter: ExporterNone},
+		{name: "empty", exporter: ""},
+		{name: "stdout", exporter: ExporterStdout},
+		{name: "unknown", exporter: "zipkin", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := otel.GetTracerProvider()
+			t.Cleanup(func() { otel.SetTracerProvider(prev) })
+			var cfg config.Config
+			cfg.Tracing.Exporter = tt.exporter
+			cfg.Tracing.SampleRatio = 1
+			cfg.Tracing.ServiceName = "syncservice"
+
+			shutdown, err := Setup(context.Background(), &cfg)
+
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NoError(t, shutdown(context.Background()))
+		})
+	}
+}
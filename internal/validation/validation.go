@@ -0,0 +1,115 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"k8s.io/apimachinery/pkg/api/resource"
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
+	"regexp"
+	"strings"
+)
+
+const (
+	// MinPriority, MaxPriority - допустимый диапазон приоритета клиента
+	MinPriority = 0
+	MaxPriority = 100
+	// maxImageLength - длина колонки clients.image
+	maxImageLength = 255
+)
+
+// imageReference - ссылка на образ [registry[:port]/]path[:tag][@digest] по грамматике docker distribution
+var imageReference = regexp.MustCompile(`^(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?` +
+	`(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*(?::[0-9]+)?/)?` +
+	`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*` +
+	`(?::[\w][\w.-]{0,127})?` +
+	`(?:@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,})?$`)
+
+// Client - проверка полей клиента перед сохранением в БД
+func Client(c *model.Client) error {
+	var v model.ValidationError
+	checkClient(&v, c)
+	return v.Err()
+}
+
+// ClientUpdate - проверка клиента перед обновлением, дополнительно требуется id
+func ClientUpdate(c *model.Client) error {
+	var v model.ValidationError
+	if c.ID <= 0 {
+		v.Add("id", "must be positive")
+	}
+	checkClient(&v, c)
+	return v.Err()
+}
+
+// checkClient - проверка полей клиента, общих для создания и обновления
+func checkClient(v *model.ValidationError, c *model.Client) {
+	if c.ClientName == "" {
+		v.Add("client_name", "is required")
+	} else if errs := k8svalidation.IsDNS1123Label(c.ClientName); len(errs) > 0 {
+		v.Add("client_name", strings.Join(errs, "; "))
+	}
+	if c.Version < 0 {
+		v.Add("version", "must be non-negative")
+	}
+	checkImage(v, "image", c.Image)
+	checkQuantity(v, "cpu", c.CPU)
+	checkQuantity(v, "memory", c.Memory)
+	checkTags(v, "tags", c.Tags)
+	if c.Priority < MinPriority || c.Priority > MaxPriority {
+		v.Add("priority", fmt.Sprintf("must be between %d and %d", MinPriority, MaxPriority))
+	}
+}
+
+// Scope - проверка области видимости ключа API
+func Scope(s *model.Scope) error {
+	var v model.ValidationError
+	for i, id := range s.ClientIDs {
+		if id <= 0 {
+			v.Add(fmt.Sprintf("scope.client_ids[%d]", i), "must be positive")
+		}
+	}
+	checkTags(&v, "scope.tags", s.Tags)
+	return v.Err()
+}
+
+// checkTags - проверка тегов: метки в формате DNS-1123 без повторов
+func checkTags(v *model.ValidationError, field string, tags []string) {
+	seen := make(map[string]bool, len(tags))
+	for i, t := range tags {
+		name := fmt.Sprintf("%s[%d]", field, i)
+		if errs := k8svalidation.IsDNS1123Label(t); len(errs) > 0 {
+			v.Add(name, strings.Join(errs, "; "))
+		} else if seen[t] {
+			v.Add(name, "is duplicated")
+		}
+		seen[t] = true
+	}
+}
+
+// checkImage - проверка ссылки на образ
+func checkImage(v *model.ValidationError, field, image string) {
+	switch {
+	case image == "":
+		v.Add(field, "is required")
+	case len(image) > maxImageLength:
+		v.Add(field, fmt.Sprintf("must be no more than %d characters", maxImageLength))
+	case !imageReference.MatchString(image):
+		v.Add(field, "must be a valid image reference")
+	}
+}
+
+// checkQuantity - проверка ресурса как положительного количества Kubernetes (500m, 1Gi)
+func checkQuantity(v *model.ValidationError, field, value string) {
+	if value == "" {
+		v.Add(field, "is required")
+		return
+	}
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		v.Add(field, "must be a Kubernetes quantity such as 500m or 1Gi")
+		return
+	}
+	if q.Sign() <= 0 {
+		v.Add(field, "must be positive")
+	}
+}
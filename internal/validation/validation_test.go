@@ -0,0 +1,120 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/CyrilSbrodov/syncService/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validClient() model.Client {
+	return model.Client{
+		ID:         1,
+		ClientName: "client-42",
+		Version:    1,
+		Image:      "registry.local:5000/algo/hft:1.2.3",
+		CPU:        "500m",
+		Memory:     "1Gi",
+		Priority:   10,
+	}
+}
+
+func TestClient(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(c *model.Client)
+		expected []model.FieldError
+	}{
+		{
+			name:   "valid",
+			modify: func(c *model.Client) {},
+		},
+		{
+			name:   "image with digest",
+			modify: func(c *model.Client) { c.Image = "algo@sha256:" + "0123456789abcdef0123456789abcdef" },
+		},
+		{
+			name:     "empty name",
+			modify:   func(c *model.Client) { c.ClientName = "" },
+			expected: []model.FieldError{{Field: "client_name", Reason: "is required"}},
+		},
+		{
+			name:   "tags",
+			modify: func(c *model.Client) { c.Tags = []string{"desk-a", "desk-b"} },
+		},
+		{
+			name:   "bad tags",
+			modify: func(c *model.Client) { c.Tags = []string{"desk-a", "Desk B", "desk-a"} },
+			expected: []model.FieldError{
+				{Field: "tags[1]", Reason: "a lowercase RFC 1123 label must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character (e.g. 'my-name',  or '123-abc', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?')"},
+				{Field: "tags[2]", Reason: "is duplicated"},
+			},
+		},
+		{
+			name: "everything wrong",
+			modify: func(c *model.Client) {
+				c.Version = -1
+				c.Image = "Bad Image"
+				c.CPU = "banana"
+				c.Memory = "-1Gi"
+				c.Priority = -5
+			},
+			expected: []model.FieldError{
+				{Field: "version", Reason: "must be non-negative"},
+				{Field: "image", Reason: "must be a valid image reference"},
+				{Field: "cpu", Reason: "must be a Kubernetes quantity such as 500m or 1Gi"},
+				{Field: "memory", Reason: "must be positive"},
+				{Field: "priority", Reason: "must be between 0 and 100"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validClient()
+			tt.modify(&c)
+			err := Client(&c)
+			if tt.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var v *model.ValidationError
+			require.True(t, errors.As(err, &v))
+			assert.Equal(t, tt.expected, v.Fields)
+		})
+	}
+}
+
+func TestClientUpdate(t *testing.T) {
+	c := validClient()
+	assert.NoError(t, ClientUpdate(&c))
+
+	c.ID = 0
+	var v *model.ValidationError
+	require.True(t, errors.As(ClientUpdate(&c), &v))
+	assert.Equal(t, []model.FieldError{{Field: "id", Reason: "must be positive"}}, v.Fields)
+}
+
+func TestClientName(t *testing.T) {
+	for _, name := range []string{"Client", "client_1", "-client", "client.1", string(make([]byte, 64))} {
+		c := validClient()
+		c.ClientName = name
+		var v *model.ValidationError
+		require.True(t, errors.As(Client(&c), &v), name)
+		assert.Equal(t, "client_name", v.Fields[0].Field)
+	}
+}
+
+func TestScope(t *testing.T) {
+	assert.NoError(t, Scope(&model.Scope{}))
+	assert.NoError(t, Scope(&model.Scope{ClientIDs: []int64{1, 2}, Tags: []string{"desk-a"}}))
+
+	var verr *model.ValidationError
+	require.True(t, errors.As(Scope(&model.Scope{ClientIDs: []int64{1, 0}, Tags: []string{"desk-a", "desk-a"}}), &verr))
+	assert.Equal(t, []model.FieldError{
+		{Field: "scope.client_ids[1]", Reason: "must be positive"},
+		{Field: "scope.tags[1]", Reason: "is duplicated"},
+	}, verr.Fields)
+}